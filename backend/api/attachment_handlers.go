@@ -0,0 +1,218 @@
+// api/attachment_handlers.go
+
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+
+	"github.com/ashuthe1/localmind/logger"
+	"github.com/ashuthe1/localmind/models"
+	"github.com/ashuthe1/localmind/services"
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// maxMessageFormSize bounds the non-file parts of a chat request.
+const maxMessageFormSize = 1 << 20
+
+// sendMessageRequest is the body of POST /api/chat. It is sent as JSON, with images
+// as base64 strings or data: URLs and text files as name and content pairs, or as a
+// multipart form with "images" and "files" file fields and "options" and "schema" as
+// JSON-encoded fields.
+type sendMessageRequest struct {
+	Message   string                    `json:"message"`
+	ChatID    string                    `json:"chatId,omitempty"`
+	Model     string                    `json:"model,omitempty"`     // Only used when a new chat is created
+	PersonaID string                    `json:"personaId,omitempty"` // Only used when a new chat is created
+	Options   *models.GenerationOptions `json:"options,omitempty"`
+	Schema    models.RawJSON            `json:"schema,omitempty"` // JSON schema the reply must match
+	Images    []string                  `json:"images,omitempty"`
+	Files     []textFile                `json:"files,omitempty"`
+
+	images []uploadedFile // Decoded from Images or the multipart files
+	files  []uploadedFile // Taken from Files or the multipart files
+}
+
+// textFile is a text file sent in a JSON chat request.
+type textFile struct {
+	Name    string `json:"name"`
+	Content string `json:"content"`
+}
+
+// uploadedFile is a file received with a chat request that is not stored yet.
+type uploadedFile struct {
+	Name string
+	Data []byte
+}
+
+// decodeSendMessageRequest reads a chat request and checks its images and files.
+func (h *Handler) decodeSendMessageRequest(w http.ResponseWriter, r *http.Request) (*sendMessageRequest, error) {
+	// Base64 makes JSON-encoded images a third larger, escaping can double JSON-encoded files
+	r.Body = http.MaxBytesReader(w, r.Body, h.Attachments.MaxImageSize*services.MaxImagesPerMessage*4/3+
+		h.Attachments.MaxFileSize*services.MaxFilesPerMessage*2+maxMessageFormSize)
+
+	var req sendMessageRequest
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			return nil, errors.New("invalid upload")
+		}
+		defer r.MultipartForm.RemoveAll()
+
+		req.Message = r.FormValue("message")
+		req.ChatID = r.FormValue("chatId")
+		req.Model = r.FormValue("model")
+		req.PersonaID = r.FormValue("personaId")
+		if value := r.FormValue("options"); value != "" {
+			if err := json.Unmarshal([]byte(value), &req.Options); err != nil {
+				return nil, errors.New("options must be a JSON object")
+			}
+		}
+		if value := r.FormValue("schema"); value != "" {
+			req.Schema = models.RawJSON(value)
+		}
+
+		var err error
+		if req.images, err = readFormFiles(r.MultipartForm, "images", "image"); err != nil {
+			return nil, err
+		}
+		if req.files, err = readFormFiles(r.MultipartForm, "files", "file"); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			logger.Log.Errorf("Invalid payload %v", err)
+			return nil, errors.New("Invalid payload")
+		}
+		for i, encoded := range req.Images {
+			data, err := decodeBase64Image(encoded)
+			if err != nil {
+				return nil, fmt.Errorf("image %d is not valid base64", i+1)
+			}
+			req.images = append(req.images, uploadedFile{Name: fmt.Sprintf("image-%d", i+1), Data: data})
+		}
+		for _, file := range req.Files {
+			req.files = append(req.files, uploadedFile{Name: file.Name, Data: []byte(file.Content)})
+		}
+	}
+
+	if len(req.images) > services.MaxImagesPerMessage {
+		return nil, fmt.Errorf("at most %d images can be attached to a message", services.MaxImagesPerMessage)
+	}
+	for _, image := range req.images {
+		if _, err := h.Attachments.CheckImage(image.Data); err != nil {
+			return nil, fmt.Errorf("%s: %v", image.Name, err)
+		}
+	}
+	if len(req.files) > services.MaxFilesPerMessage {
+		return nil, fmt.Errorf("at most %d files can be attached to a message", services.MaxFilesPerMessage)
+	}
+	for _, file := range req.files {
+		if _, err := h.Attachments.CheckFile(file.Name, file.Data); err != nil {
+			return nil, fmt.Errorf("%s: %v", file.Name, err)
+		}
+	}
+	return &req, nil
+}
+
+// readFormFiles reads the files uploaded under any of the given form fields.
+func readFormFiles(form *multipart.Form, fields ...string) ([]uploadedFile, error) {
+	var files []uploadedFile
+	for _, field := range fields {
+		for _, header := range form.File[field] {
+			file, err := header.Open()
+			if err != nil {
+				return nil, fmt.Errorf("could not read %s", header.Filename)
+			}
+			data, err := io.ReadAll(file)
+			file.Close()
+			if err != nil {
+				return nil, fmt.Errorf("could not read %s", header.Filename)
+			}
+			files = append(files, uploadedFile{Name: header.Filename, Data: data})
+		}
+	}
+	return files, nil
+}
+
+// decodeBase64Image decodes a base64 string, optionally given as a data: URL.
+func decodeBase64Image(encoded string) ([]byte, error) {
+	if strings.HasPrefix(encoded, "data:") {
+		if comma := strings.IndexByte(encoded, ','); comma >= 0 {
+			encoded = encoded[comma+1:]
+		}
+	}
+	encoded = strings.TrimSpace(encoded)
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return base64.RawStdEncoding.DecodeString(encoded)
+	}
+	return data, nil
+}
+
+// saveAttachments stores the images and files of a chat request and returns their attachments.
+func (h *Handler) saveAttachments(chatID primitive.ObjectID, req *sendMessageRequest) ([]models.Attachment, error) {
+	attachments := make([]models.Attachment, 0, len(req.images)+len(req.files))
+	for _, image := range req.images {
+		attachment, err := h.Attachments.SaveImage(chatID, image.Name, image.Data)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, *attachment)
+	}
+	for _, file := range req.files {
+		attachment, err := h.Attachments.SaveFile(chatID, file.Name, file.Data)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, *attachment)
+	}
+	return attachments, nil
+}
+
+// GetAttachmentHandler serves a file attached to a message.
+func (h *Handler) GetAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid attachment ID", http.StatusBadRequest)
+		return
+	}
+
+	attachment, err := h.Attachments.GetAttachment(id)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			http.Error(w, "Attachment not found", http.StatusNotFound)
+			return
+		}
+		logger.Log.Errorf("Error retrieving attachment: %v", err)
+		http.Error(w, "Failed to retrieve attachment", http.StatusInternalServerError)
+		return
+	}
+
+	file, err := h.Attachments.Open(attachment)
+	if err != nil {
+		logger.Log.Errorf("Error opening attachment %s: %v", id.Hex(), err)
+		http.Error(w, "Attachment not found", http.StatusNotFound)
+		return
+	}
+	defer file.Close()
+
+	contentType := attachment.ContentType
+	if attachment.Kind == models.AttachmentKindFile {
+		// Show text files as plain text whatever language they are in
+		contentType = "text/plain; charset=utf-8"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": attachment.Name}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, attachment.Name, attachment.CreatedAt, file)
+}
@@ -0,0 +1,326 @@
+// api/document_handlers.go
+
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ashuthe1/localmind/logger"
+	"github.com/ashuthe1/localmind/models"
+	"github.com/ashuthe1/localmind/services"
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// maxDocumentsPerUpload bounds the files accepted by one upload request.
+const maxDocumentsPerUpload = 20
+
+// maxSpanContext bounds the surrounding text returned with a citation span, in bytes.
+const maxSpanContext = 2000
+
+// ListCollectionsHandler lists the document collections.
+func (h *Handler) ListCollectionsHandler(w http.ResponseWriter, r *http.Request) {
+	collections, err := h.RAG.ListCollections()
+	if err != nil {
+		logger.Log.Errorf("Error listing collections: %v", err)
+		http.Error(w, "Failed to retrieve collections", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(collections)
+}
+
+// CreateCollectionHandler creates an empty document collection.
+func (h *Handler) CreateCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name        string `json:"name"`
+		Description string `json:"description,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Name) == "" {
+		http.Error(w, "Collection name is required", http.StatusBadRequest)
+		return
+	}
+
+	collection, err := h.RAG.CreateCollection(strings.TrimSpace(req.Name), req.Description)
+	if err != nil {
+		logger.Log.Errorf("Error creating collection: %v", err)
+		http.Error(w, "Failed to create collection", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(collection)
+}
+
+// GetCollectionHandler returns a collection with its documents.
+func (h *Handler) GetCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	collectionID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid collection ID", http.StatusBadRequest)
+		return
+	}
+
+	collection, err := h.RAG.GetCollection(collectionID)
+	if err != nil {
+		writeDocumentError(w, err, "Failed to retrieve collection")
+		return
+	}
+	documents, err := h.RAG.ListDocuments(collectionID)
+	if err != nil {
+		writeDocumentError(w, err, "Failed to retrieve documents")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"collection": collection,
+		"documents":  documents,
+	})
+}
+
+// DeleteCollectionHandler deletes a collection and all its documents.
+func (h *Handler) DeleteCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	collectionID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid collection ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.RAG.DeleteCollection(collectionID); err != nil {
+		writeDocumentError(w, err, "Failed to delete collection")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UploadDocumentsHandler ingests the text, markdown and source files of a multipart
+// upload ("files" fields) into a collection. The optional "chunkSize" and
+// "chunkOverlap" fields override the configured chunking. Files that fail are
+// reported next to the stored ones.
+func (h *Handler) UploadDocumentsHandler(w http.ResponseWriter, r *http.Request) {
+	collectionID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid collection ID", http.StatusBadRequest)
+		return
+	}
+	if _, err := h.RAG.GetCollection(collectionID); err != nil {
+		writeDocumentError(w, err, "Failed to retrieve collection")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.RAG.MaxDocumentSize*maxDocumentsPerUpload)
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		http.Error(w, "Invalid upload", http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	chunkSize, chunkOverlap := 0, 0
+	if value := r.FormValue("chunkSize"); value != "" {
+		chunkSize, _ = strconv.Atoi(value)
+		chunkOverlap, _ = strconv.Atoi(r.FormValue("chunkOverlap"))
+		if err := services.ValidateChunking(chunkSize, chunkOverlap); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	files := append(r.MultipartForm.File["files"], r.MultipartForm.File["file"]...)
+	if len(files) == 0 {
+		http.Error(w, "No files uploaded", http.StatusBadRequest)
+		return
+	}
+	if len(files) > maxDocumentsPerUpload {
+		http.Error(w, fmt.Sprintf("At most %d files can be uploaded at once", maxDocumentsPerUpload), http.StatusBadRequest)
+		return
+	}
+
+	type uploadError struct {
+		Name  string `json:"name"`
+		Error string `json:"error"`
+	}
+	documents := []models.Document{}
+	failures := []uploadError{}
+	for _, header := range files {
+		if header.Size > h.RAG.MaxDocumentSize {
+			failures = append(failures, uploadError{header.Filename, fmt.Sprintf("larger than %d bytes", h.RAG.MaxDocumentSize)})
+			continue
+		}
+		file, err := header.Open()
+		if err != nil {
+			failures = append(failures, uploadError{header.Filename, "could not read the file"})
+			continue
+		}
+		content, err := io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			failures = append(failures, uploadError{header.Filename, "could not read the file"})
+			continue
+		}
+
+		document, err := h.RAG.IngestDocument(r.Context(), collectionID, header.Filename, content, chunkSize, chunkOverlap)
+		if err != nil {
+			logger.Log.Errorf("Error ingesting %s: %v", header.Filename, err)
+			failures = append(failures, uploadError{header.Filename, ingestErrorMessage(err)})
+			continue
+		}
+		documents = append(documents, *document)
+	}
+
+	status := http.StatusCreated
+	if len(documents) == 0 {
+		status = http.StatusUnprocessableEntity
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"documents": documents,
+		"errors":    failures,
+	})
+}
+
+// DeleteDocumentHandler removes a document and its chunks from its collection.
+func (h *Handler) DeleteDocumentHandler(w http.ResponseWriter, r *http.Request) {
+	documentID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid document ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.RAG.DeleteDocument(documentID); err != nil {
+		writeDocumentError(w, err, "Failed to delete document")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetDocumentSpanHandler returns the original text of a citation: the bytes between
+// ?start= and ?end= of a document, plus ?context= bytes around them if asked for.
+func (h *Handler) GetDocumentSpanHandler(w http.ResponseWriter, r *http.Request) {
+	documentID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid document ID", http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	start, err := strconv.Atoi(query.Get("start"))
+	if err != nil {
+		http.Error(w, "Invalid start offset", http.StatusBadRequest)
+		return
+	}
+	end, err := strconv.Atoi(query.Get("end"))
+	if err != nil {
+		http.Error(w, "Invalid end offset", http.StatusBadRequest)
+		return
+	}
+	contextBytes := 0
+	if value := query.Get("context"); value != "" {
+		contextBytes, err = strconv.Atoi(value)
+		if err != nil || contextBytes < 0 || contextBytes > maxSpanContext {
+			http.Error(w, fmt.Sprintf("Context must be between 0 and %d", maxSpanContext), http.StatusBadRequest)
+			return
+		}
+	}
+
+	document, err := h.RAG.GetDocument(documentID)
+	if err != nil {
+		writeDocumentError(w, err, "Failed to retrieve document")
+		return
+	}
+
+	text, spanStart, spanEnd, err := services.DocumentSpan(document, start, end, contextBytes)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"documentId":   document.ID,
+		"documentName": document.Name,
+		"start":        spanStart,
+		"end":          spanEnd,
+		"text":         text,
+	})
+}
+
+// UpdateChatCollectionsHandler sets the document collections a chat retrieves from.
+func (h *Handler) UpdateChatCollectionsHandler(w http.ResponseWriter, r *http.Request) {
+	chatID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid chat ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		CollectionIDs []string `json:"collectionIds"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	collectionIDs := make([]primitive.ObjectID, 0, len(req.CollectionIDs))
+	for _, hex := range req.CollectionIDs {
+		id, err := primitive.ObjectIDFromHex(hex)
+		if err != nil {
+			http.Error(w, "Invalid collection ID", http.StatusBadRequest)
+			return
+		}
+		if _, err := h.RAG.GetCollection(id); err != nil {
+			writeDocumentError(w, err, "Failed to retrieve collection")
+			return
+		}
+		collectionIDs = append(collectionIDs, id)
+	}
+
+	if err := h.ChatService.SetChatCollections(chatID, collectionIDs); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			http.Error(w, "Chat not found", http.StatusNotFound)
+			return
+		}
+		logger.Log.Errorf("Error updating chat collections: %v", err)
+		http.Error(w, "Failed to update chat collections", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ingestErrorMessage describes an ingestion failure for the client.
+func ingestErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, services.ErrUnsupportedDocument):
+		return err.Error()
+	case errors.Is(err, services.ErrEmbeddingsUnsupported):
+		return err.Error()
+	case errors.Is(err, services.ErrModelNotFound):
+		return "embedding model not found"
+	case errors.Is(err, services.ErrProviderUnavailable):
+		return "LLM backend unavailable"
+	default:
+		return "failed to ingest the document"
+	}
+}
+
+// writeDocumentError maps collection and document errors onto HTTP status codes.
+func writeDocumentError(w http.ResponseWriter, err error, message string) {
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	logger.Log.Errorf("%s: %v", message, err)
+	http.Error(w, message, http.StatusInternalServerError)
+}
@@ -4,10 +4,9 @@ package api
 
 import (
 	"encoding/json"
-	"fmt"
+	"errors"
 	"log"
 	"net/http"
-	"sync"
 	"time"
 
 	"github.com/ashuthe1/localmind/config"
@@ -16,36 +15,74 @@ import (
 	"github.com/ashuthe1/localmind/services"
 	"github.com/gorilla/mux"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 var totalThreads = 0
 
 type Handler struct {
-	ChatService   *services.ChatService
-	OllamaService *services.OllamaService
-	UserService   *services.UserService
+	ChatService    *services.ChatService
+	LLM            services.LLMProvider
+	UserService    *services.UserService
+	ContextManager *services.ContextManager
+	Generations    *services.GenerationTracker
+	Titles         *services.TitleService
+	Events         *services.ChatEventBroker
+	Personas       *services.PersonaService
+	Templates      *services.TemplateService
+	RAG            *services.RAGService
+	Indexer        *services.MessageIndexer
+	Tools          *services.ToolService
+	Structured     *services.StructuredOutputService
+	Attachments    *services.AttachmentService
+	Scheduler      *services.GenerationScheduler
 }
 
 // NewHandler creates a new Handler instance.
-func NewHandler(chatService *services.ChatService, ollamaService *services.OllamaService, userService *services.UserService) *Handler {
+func NewHandler(chatService *services.ChatService, llm services.LLMProvider, userService *services.UserService, contextManager *services.ContextManager, generations *services.GenerationTracker, titles *services.TitleService, events *services.ChatEventBroker, personas *services.PersonaService, templates *services.TemplateService, rag *services.RAGService, indexer *services.MessageIndexer, tools *services.ToolService, structured *services.StructuredOutputService, attachments *services.AttachmentService, scheduler *services.GenerationScheduler) *Handler {
 	return &Handler{
-		ChatService:   chatService,
-		OllamaService: ollamaService,
-		UserService:   userService,
+		ChatService:    chatService,
+		LLM:            llm,
+		UserService:    userService,
+		ContextManager: contextManager,
+		Generations:    generations,
+		Titles:         titles,
+		Events:         events,
+		Personas:       personas,
+		Templates:      templates,
+		RAG:            rag,
+		Indexer:        indexer,
+		Tools:          tools,
+		Structured:     structured,
+		Attachments:    attachments,
+		Scheduler:      scheduler,
 	}
 }
 
 func (h *Handler) CreateDefaultMessage(w http.ResponseWriter, r *http.Request) {
 
 	username := config.UserName
-	chat, err := h.ChatService.CreateChat("Greet User")
+	persona, err := h.Personas.DefaultPersona()
+	if err != nil {
+		logger.Log.Errorf("Error loading default persona: %v", err)
+		http.Error(w, "Failed to load persona", http.StatusInternalServerError)
+		return
+	}
+	model := persona.Model
+	if model == "" {
+		model = h.LLM.DefaultModel()
+	}
+	chat, err := h.ChatService.CreateChat("Greet User", model, persona.ID)
 	if err != nil {
 		log.Println("Error creating new chat:", err)
 		http.Error(w, "Failed to create chat", http.StatusInternalServerError)
 		return
 	}
 	chatID := chat.ID
-	chatContent := fmt.Sprintf("Hi %v, it's a pleasure to meet you!\n I'm Smriti, an AI chatbot running completely locally on your system with no external dependencies.", username)
+	chatContent := services.Greeting(persona, username)
+	if chatContent == "" {
+		return // The persona has no greeting
+	}
 	userMessage := models.Message{
 		ID:        primitive.NewObjectID(),
 		Role:      "assistant",
@@ -68,42 +105,36 @@ func (h *Handler) CreateDefaultMessage(w http.ResponseWriter, r *http.Request) {
 	// })
 }
 
-func (h *Handler) GenerateTitleForChat(prompt string) string {
-	query := fmt.Sprintf("Give me less than 2 words title for this prompt message: %v", prompt)
-	title, err := h.OllamaService.GenerateResponse(query, config.ModelName)
-	if err != nil {
-		return "New Chat"
-	}
-	return title
-}
-
+// SendMessageHandler stores a user message, with any attached images and files, and streams
+// the reply. See sendMessageRequest for the accepted bodies.
 func (h *Handler) SendMessageHandler(w http.ResponseWriter, r *http.Request) {
-	var req struct {
-		Message string `json:"message"`
-		ChatID  string `json:"chatId,omitempty"`
-	}
-
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		logger.Log.Errorf("Invalid payload %v", err)
-		http.Error(w, "Invalid payload", http.StatusBadRequest)
+	req, err := h.decodeSendMessageRequest(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	if req.Message == "" {
+	if req.Message == "" && len(req.images) == 0 && len(req.files) == 0 {
 		logger.Log.Errorf("User Prompt is required")
 		http.Error(w, "User Prompt is required", http.StatusBadRequest)
 		return
 	}
 
+	if err := services.ValidateOptions(req.Options); err != nil {
+		http.Error(w, "Invalid options: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(req.Schema) > 0 {
+		if err := services.CheckSchema(req.Schema); err != nil {
+			http.Error(w, "Invalid schema: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
 	var chatID primitive.ObjectID
-	var err error
 	if req.ChatID == "" {
-		// title := h.GenerateTitleForChat(req.Message)
-		title := "New Chat"
-		chat, err := h.ChatService.CreateChat(title)
-		if err != nil {
-			logger.Log.Errorf("Error creating new chat: %v", err)
-			http.Error(w, "Failed to create chat", http.StatusInternalServerError)
+		chat, ok := h.newChat(w, r, req.PersonaID, req.Model)
+		if !ok {
 			return
 		}
 		chatID = chat.ID
@@ -116,112 +147,572 @@ func (h *Handler) SendMessageHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	attachments, err := h.saveAttachments(chatID, req)
+	if err != nil {
+		logger.Log.Errorf("Error storing attachments: %v", err)
+		http.Error(w, "Failed to store attachments", http.StatusInternalServerError)
+		return
+	}
+
+	h.sendUserMessage(w, r, chatID, userTurn{
+		Content:     req.Message,
+		Attachments: attachments,
+		Options:     req.Options,
+		Schema:      req.Schema,
+	})
+}
+
+// newChat creates a chat for the persona with the given hex ID, or the default persona,
+// using the requested model or else the persona's. It writes the HTTP error on failure.
+func (h *Handler) newChat(w http.ResponseWriter, r *http.Request, personaID string, model string) (*models.Chat, bool) {
+	persona, err := h.requestedPersona(personaID)
+	if err != nil {
+		http.Error(w, "Persona not found", http.StatusBadRequest)
+		return nil, false
+	}
+	chatPersonaID := primitive.NilObjectID
+	if persona != nil {
+		chatPersonaID = persona.ID
+		if model == "" {
+			model = persona.Model
+		}
+	}
+	resolved, err := services.ResolveModel(r.Context(), h.LLM, model)
+	if err != nil {
+		logger.Log.Errorf("Error resolving model %q: %v", model, err)
+		http.Error(w, "Model not available", http.StatusBadRequest)
+		return nil, false
+	}
+
+	// The title is generated in the background after the first exchange
+	chat, err := h.ChatService.CreateChat(services.DefaultChatTitle, resolved, chatPersonaID)
+	if err != nil {
+		logger.Log.Errorf("Error creating new chat: %v", err)
+		http.Error(w, "Failed to create chat", http.StatusInternalServerError)
+		return nil, false
+	}
+	return chat, true
+}
+
+// userTurn is a user message to store and reply to.
+type userTurn struct {
+	Content     string
+	Attachments []models.Attachment
+	Options     *models.GenerationOptions
+	Schema      models.RawJSON
+}
+
+// branchWriter appends the messages of a reply to a chat, each after the previous one.
+type branchWriter struct {
+	h        *Handler
+	chatID   primitive.ObjectID
+	parentID primitive.ObjectID // Last message of the branch
+}
+
+// Append stores msg after the last message of the branch.
+func (b *branchWriter) Append(msg models.Message) error {
+	msg.ParentID = b.parentID
+	if err := b.h.ChatService.AddMessage(b.chatID, msg); err != nil {
+		return err
+	}
+	b.parentID = msg.ID
+	return nil
+}
+
+// sendUserMessage stores a user message on the active branch of a chat and streams the reply.
+func (h *Handler) sendUserMessage(w http.ResponseWriter, r *http.Request, chatID primitive.ObjectID, turn userTurn) {
 	// Store the user message
 	userMessage := models.Message{
-		ID:        primitive.NewObjectID(),
-		Role:      "user",
-		Content:   req.Message,
-		Timestamp: time.Now(),
+		ID:          primitive.NewObjectID(),
+		Role:        "user",
+		Content:     turn.Content,
+		Attachments: turn.Attachments,
+		Schema:      turn.Schema,
+		Timestamp:   time.Now(),
 	}
 	if err := h.ChatService.AddMessage(chatID, userMessage); err != nil {
+		if errors.Is(err, services.ErrChatBusy) {
+			http.Error(w, "Chat is busy, try again", http.StatusConflict)
+			return
+		}
 		logger.Log.Errorf("Error adding user message: %v", err)
 		http.Error(w, "Failed to add user message", http.StatusInternalServerError)
 		return
 	}
 
-	// Setup SSE headers
-	w.Header().Set("Content-Type", "text/event-stream")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("Keep-Alive", "timeout=600, max=100")
+	chat, err := h.ChatService.GetChatByID(chatID)
+	if err != nil {
+		logger.Log.Errorf("Error loading chat history: %v", err)
+		http.Error(w, "Failed to load chat", http.StatusInternalServerError)
+		return
+	}
+	// The history ends at this message even if another one was sent meanwhile
+	chat = services.BranchTo(chat, userMessage.ID)
+
+	// Stream the reply and append it to the chat, after the tools it called
+	branch := &branchWriter{h: h, chatID: chatID, parentID: userMessage.ID}
+	h.streamReply(w, r, replyParams{Chat: chat, Options: turn.Options, Schema: turn.Schema, SaveTool: branch.Append}, func(reply models.Message) error {
+		if err := branch.Append(reply); err != nil {
+			return err
+		}
+		if services.NeedsTitle(chat) {
+			h.Titles.GenerateTitleAsync(chatID)
+		}
+		return nil
+	})
+}
+
+// RegenerateTitleHandler generates a new title for a chat from its first exchange.
+func (h *Handler) RegenerateTitleHandler(w http.ResponseWriter, r *http.Request) {
+	chatID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid chat ID", http.StatusBadRequest)
+		return
+	}
 
-	flusher, ok := w.(http.Flusher)
+	title, err := h.Titles.RegenerateTitle(r.Context(), chatID)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			http.Error(w, "Chat not found", http.StatusNotFound)
+			return
+		}
+		logger.Log.Errorf("Error regenerating title: %v", err)
+		http.Error(w, "Failed to generate title", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "title": title})
+}
+
+// ChatEventsHandler streams background changes to a chat, such as generated titles, as SSE.
+func (h *Handler) ChatEventsHandler(w http.ResponseWriter, r *http.Request) {
+	chatID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid chat ID", http.StatusBadRequest)
+		return
+	}
+
+	sse, ok := newSSEWriter(w)
 	if !ok {
-		logger.Log.Error("Streaming unsupported")
 		http.Error(w, "Streaming unsupported!", http.StatusInternalServerError)
 		return
 	}
 
-	ctx := r.Context()
-	doneCh := make(chan struct{})
-	var once sync.Once // Ensure doneCh is only closed once
-
-	// Goroutine to detect client disconnection
-	go func() {
-		<-ctx.Done()
-		once.Do(func() {
-			logger.Log.Println("Client disconnected, stopping SSE stream...")
-			close(doneCh)
-		})
-	}()
+	events, unsubscribe := h.Events.Subscribe(chatID)
+	defer unsubscribe()
 
-	// Start heartbeat to keep connection alive
-	heartbeatTicker := time.NewTicker(1 * time.Second) // More frequent heartbeats
+	heartbeatTicker := time.NewTicker(15 * time.Second)
 	defer heartbeatTicker.Stop()
 
-	assistantResponse := ""
-
-	// Goroutine to send heartbeats
-	go func() {
-		for {
-			select {
-			case <-heartbeatTicker.C:
-				if _, err := w.Write([]byte("data: \n\n")); err != nil {
-					logger.Log.Printf("Error sending heartbeat: %v", err)
-					once.Do(func() { close(doneCh) }) // Ensure doneCh is closed only once
-					return
-				}
-				flusher.Flush()
-			case <-doneCh:
+	for {
+		select {
+		case event := <-events:
+			data, err := json.Marshal(event.Data)
+			if err != nil {
+				logger.Log.Errorf("Error encoding chat event: %v", err)
+				continue
+			}
+			if err := sse.Send(event.Type, string(data)); err != nil {
+				return
+			}
+		case <-heartbeatTicker.C:
+			if err := sse.Ping(); err != nil {
 				return
 			}
+		case <-r.Context().Done():
+			return
 		}
-	}()
+	}
+}
 
-	// Callback function to send streamed data
-	sendChunk := func(chunk string) error {
-		select {
-		case <-doneCh:
-			return fmt.Errorf("client disconnected")
+// UpdateChatModelHandler changes the model used for new replies in a chat.
+func (h *Handler) UpdateChatModelHandler(w http.ResponseWriter, r *http.Request) {
+	chatID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid chat ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Model string `json:"model"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Model == "" {
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	model, err := services.ResolveModel(r.Context(), h.LLM, req.Model)
+	if err != nil {
+		http.Error(w, "Model not available", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.ChatService.SetChatModel(chatID, model); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			http.Error(w, "Chat not found", http.StatusNotFound)
+			return
+		}
+		logger.Log.Errorf("Error updating chat model: %v", err)
+		http.Error(w, "Failed to update chat model", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "model": model})
+}
+
+// UpdateChatOptionsHandler replaces the default generation options of a chat.
+// Sending null clears them.
+func (h *Handler) UpdateChatOptionsHandler(w http.ResponseWriter, r *http.Request) {
+	chatID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid chat ID", http.StatusBadRequest)
+		return
+	}
+
+	var options *models.GenerationOptions
+	if err := json.NewDecoder(r.Body).Decode(&options); err != nil {
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
+		return
+	}
+	if err := services.ValidateOptions(options); err != nil {
+		http.Error(w, "Invalid options: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.ChatService.SetChatOptions(chatID, options); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			http.Error(w, "Chat not found", http.StatusNotFound)
+			return
+		}
+		logger.Log.Errorf("Error updating chat options: %v", err)
+		http.Error(w, "Failed to update chat options", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": "success", "options": options})
+}
+
+// userOptions returns the current user's default generation options, if any.
+func (h *Handler) userOptions() *models.GenerationOptions {
+	user, err := h.UserService.UserRepo.GetUserByUsername(config.UserName)
+	if err != nil {
+		return nil
+	}
+	return user.Options
+}
+
+// PinMessageHandler pins or unpins a message for the "pinned" context strategy.
+func (h *Handler) PinMessageHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	chatID, err := primitive.ObjectIDFromHex(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid chat ID", http.StatusBadRequest)
+		return
+	}
+	messageID, err := primitive.ObjectIDFromHex(vars["messageId"])
+	if err != nil {
+		http.Error(w, "Invalid message ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Pinned bool `json:"pinned"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.ChatService.SetMessagePinned(chatID, messageID, req.Pinned); err != nil {
+		if errors.Is(err, services.ErrMessageNotFound) {
+			http.Error(w, "Message not found", http.StatusNotFound)
+			return
+		}
+		logger.Log.Errorf("Error pinning message: %v", err)
+		http.Error(w, "Failed to update message", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// EditMessageHandler edits a user message by starting a new branch from it, then
+// streams the reply on that branch. The original branch is kept.
+func (h *Handler) EditMessageHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	chatID, err := primitive.ObjectIDFromHex(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid chat ID", http.StatusBadRequest)
+		return
+	}
+	messageID, err := primitive.ObjectIDFromHex(vars["messageId"])
+	if err != nil {
+		http.Error(w, "Invalid message ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Message string                    `json:"message"`
+		Options *models.GenerationOptions `json:"options,omitempty"`
+		Schema  models.RawJSON            `json:"schema,omitempty"` // Defaults to the edited message's schema
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
+		return
+	}
+	if req.Message == "" {
+		http.Error(w, "User Prompt is required", http.StatusBadRequest)
+		return
+	}
+	if err := services.ValidateOptions(req.Options); err != nil {
+		http.Error(w, "Invalid options: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(req.Schema) > 0 {
+		if err := services.CheckSchema(req.Schema); err != nil {
+			http.Error(w, "Invalid schema: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	userMessage, err := h.ChatService.ForkMessage(chatID, messageID, models.Message{
+		ID:        primitive.NewObjectID(),
+		Role:      "user",
+		Content:   req.Message,
+		Schema:    req.Schema,
+		Timestamp: time.Now(),
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, mongo.ErrNoDocuments):
+			http.Error(w, "Chat not found", http.StatusNotFound)
+		case errors.Is(err, services.ErrMessageNotFound):
+			http.Error(w, "Message not found", http.StatusNotFound)
+		case errors.Is(err, services.ErrNotEditable):
+			http.Error(w, "Only user messages can be edited", http.StatusBadRequest)
 		default:
-			_, err := w.Write([]byte("data: " + chunk + "\n\n"))
-			if err != nil {
-				logger.Log.Println("Error sending chunk:", err)
-				once.Do(func() { close(doneCh) }) // Ensure doneCh is closed only once
-				return err
-			}
-			flusher.Flush()
-			assistantResponse += chunk
-			return nil
+			logger.Log.Errorf("Error editing message: %v", err)
+			http.Error(w, "Failed to edit message", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	chat, err := h.ChatService.GetChatByID(chatID)
+	if err != nil {
+		logger.Log.Errorf("Error loading chat history: %v", err)
+		http.Error(w, "Failed to load chat", http.StatusInternalServerError)
+		return
+	}
+
+	branch := &branchWriter{h: h, chatID: chatID, parentID: userMessage.ID}
+	h.streamReply(w, r, replyParams{Chat: services.BranchTo(chat, userMessage.ID), Options: req.Options, Schema: userMessage.Schema, SaveTool: branch.Append}, branch.Append)
+}
+
+// GetBranchesHandler lists the branches of a chat.
+func (h *Handler) GetBranchesHandler(w http.ResponseWriter, r *http.Request) {
+	chatID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid chat ID", http.StatusBadRequest)
+		return
+	}
+
+	chat, err := h.ChatService.GetChatByID(chatID)
+	if err != nil {
+		http.Error(w, "Chat not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"activeLeafId": chat.ActiveLeafID,
+		"branches":     services.Branches(chat),
+	})
+}
+
+// SwitchBranchHandler makes the branch through a message active and returns the chat
+// with that branch's messages.
+func (h *Handler) SwitchBranchHandler(w http.ResponseWriter, r *http.Request) {
+	chatID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid chat ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		MessageID string `json:"messageId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
+		return
+	}
+	messageID, err := primitive.ObjectIDFromHex(req.MessageID)
+	if err != nil {
+		http.Error(w, "Invalid message ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.ChatService.SwitchBranch(chatID, messageID); err != nil {
+		switch {
+		case errors.Is(err, mongo.ErrNoDocuments):
+			http.Error(w, "Chat not found", http.StatusNotFound)
+		case errors.Is(err, services.ErrMessageNotFound):
+			http.Error(w, "Message not found", http.StatusNotFound)
+		default:
+			logger.Log.Errorf("Error switching branch: %v", err)
+			http.Error(w, "Failed to switch branch", http.StatusInternalServerError)
 		}
+		return
 	}
 
-	// Update: Generate User Aware Prompt
-	finalPrompt := h.UserService.UserRepo.GenerateUserAwarePrompt(req.Message)
+	chat, err := h.ChatService.GetChatByID(chatID)
+	if err != nil {
+		http.Error(w, "Failed to load chat", http.StatusInternalServerError)
+		return
+	}
 
-	// Stream response from Ollama
-	err = h.OllamaService.StreamResponse(finalPrompt, config.ModelName, sendChunk)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(services.ActiveBranch(chat))
+}
 
+// RegenerateMessageHandler streams a new reply to the user turn before an assistant
+// message and stores it as a variant of that message.
+func (h *Handler) RegenerateMessageHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	chatID, err := primitive.ObjectIDFromHex(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid chat ID", http.StatusBadRequest)
+		return
+	}
+	messageID, err := primitive.ObjectIDFromHex(vars["messageId"])
 	if err != nil {
-		logger.Log.Errorf("Error streaming response from LLM: %v", err)
-		sendChunk("[ERROR] Failed to complete response.")
+		http.Error(w, "Invalid message ID", http.StatusBadRequest)
+		return
+	}
+
+	// The body is optional
+	var req struct {
+		Model   string                    `json:"model,omitempty"` // Regenerate with another model
+		Options *models.GenerationOptions `json:"options,omitempty"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid payload", http.StatusBadRequest)
+			return
+		}
+	}
+	if err := services.ValidateOptions(req.Options); err != nil {
+		http.Error(w, "Invalid options: "+err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	// Save assistant's response only if streaming was successful
-	if assistantResponse != "" {
-		assistantMessage := models.Message{
-			ID:        primitive.NewObjectID(),
-			Role:      "assistant",
-			Content:   assistantResponse,
-			Timestamp: time.Now(),
+	model := ""
+	if req.Model != "" {
+		model, err = services.ResolveModel(r.Context(), h.LLM, req.Model)
+		if err != nil {
+			logger.Log.Errorf("Error resolving model %q: %v", req.Model, err)
+			http.Error(w, "Model not available", http.StatusBadRequest)
+			return
 		}
-		if err := h.ChatService.AddMessage(chatID, assistantMessage); err != nil {
-			logger.Log.Errorf("Error adding assistant message: %v", err)
+	}
+
+	chat, err := h.ChatService.GetChatByID(chatID)
+	if err != nil {
+		http.Error(w, "Chat not found", http.StatusNotFound)
+		return
+	}
+	history, err := h.ChatService.RegenerationHistory(chat, messageID)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrMessageNotFound):
+			http.Error(w, "Message not found", http.StatusNotFound)
+		case errors.Is(err, services.ErrNotRegenerable):
+			http.Error(w, "Only assistant replies can be regenerated", http.StatusBadRequest)
+		default:
+			http.Error(w, "Failed to regenerate message", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// The new reply is a variant of the old one and keeps the tools it called, so
+	// selecting a variant also switches between their tool calls.
+	var tools []models.Message
+	saveTool := func(msg models.Message) error {
+		tools = append(tools, msg)
+		return nil
+	}
+	params := replyParams{Chat: history, Model: model, Options: req.Options, Schema: services.TurnSchema(history), SaveTool: saveTool}
+	h.streamReply(w, r, params, func(reply models.Message) error {
+		reply.Tools = tools
+		return h.ChatService.AddVariant(chatID, messageID, reply)
+	})
+}
+
+// SelectVariantHandler chooses which regenerated variant of a message is active.
+func (h *Handler) SelectVariantHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	chatID, err := primitive.ObjectIDFromHex(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid chat ID", http.StatusBadRequest)
+		return
+	}
+	messageID, err := primitive.ObjectIDFromHex(vars["messageId"])
+	if err != nil {
+		http.Error(w, "Invalid message ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Index *int `json:"index"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Index == nil {
+		http.Error(w, "Variant index is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.ChatService.SelectVariant(chatID, messageID, *req.Index); err != nil {
+		switch {
+		case errors.Is(err, services.ErrMessageNotFound):
+			http.Error(w, "Message not found", http.StatusNotFound)
+		case errors.Is(err, services.ErrVariantNotFound):
+			http.Error(w, "Variant not found", http.StatusNotFound)
+		default:
+			logger.Log.Errorf("Error selecting variant: %v", err)
+			http.Error(w, "Failed to update message", http.StatusInternalServerError)
 		}
+		return
 	}
 
-	_, _ = w.Write([]byte("event: complete\ndata: done\n\n"))
-	flusher.Flush()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CancelGenerationHandler stops a running generation by the ID sent in its "generation" event.
+func (h *Handler) CancelGenerationHandler(w http.ResponseWriter, r *http.Request) {
+	if !h.Generations.Cancel(mux.Vars(r)["id"]) {
+		http.Error(w, "Generation not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HealthHandler reports whether the configured LLM provider is reachable.
+func (h *Handler) HealthHandler(w http.ResponseWriter, r *http.Request) {
+	status := map[string]string{
+		"status":   "ok",
+		"provider": h.LLM.Name(),
+		"model":    h.LLM.DefaultModel(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := h.LLM.Health(r.Context()); err != nil {
+		logger.Log.Errorf("LLM provider health check failed: %v", err)
+		status["status"] = "unavailable"
+		status["error"] = err.Error()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(status)
 }
 
 // GetChatsHandler retrieves all chats.
@@ -234,6 +725,11 @@ func (h *Handler) GetChatsHandler(w http.ResponseWriter, r *http.Request) {
 
 	totalThreads = len(chats)
 
+	// Only the active branch of each chat is shown, see GetBranchesHandler for the others
+	for i := range chats {
+		chats[i] = *services.ActiveBranch(&chats[i])
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(chats)
 }
@@ -253,6 +749,9 @@ func (h *Handler) DeleteChatHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Failed to delete chat", http.StatusInternalServerError)
 		return
 	}
+	if err := h.Attachments.DeleteChatAttachments(chatID); err != nil {
+		logger.Log.Errorf("Error deleting attachments of chat %s: %v", chatIDHex, err)
+	}
 
 	if totalThreads == 1 {
 		h.CreateDefaultMessage(w, r)
@@ -267,6 +766,9 @@ func (h *Handler) DeleteAllChatsHandler(w http.ResponseWriter, r *http.Request)
 		http.Error(w, "Failed to delete all chats", http.StatusInternalServerError)
 		return
 	}
+	if err := h.Attachments.DeleteAllAttachments(); err != nil {
+		logger.Log.Errorf("Error deleting attachments: %v", err)
+	}
 
 	h.CreateDefaultMessage(w, r)
 
@@ -343,9 +845,10 @@ func (h *Handler) CreateUserHandler(w http.ResponseWriter, r *http.Request) {
 
 func (h *Handler) UpdateUserSettingsHandler(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		UserName    string `json:"username"`
-		AboutMe     string `json:"aboutMe"`
-		Preferences string `json:"preferences"`
+		UserName    string                    `json:"username"`
+		AboutMe     string                    `json:"aboutMe"`
+		Preferences string                    `json:"preferences"`
+		Options     *models.GenerationOptions `json:"options,omitempty"` // Left unchanged when omitted
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -353,12 +856,17 @@ func (h *Handler) UpdateUserSettingsHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	if err := services.ValidateOptions(req.Options); err != nil {
+		http.Error(w, "Invalid options: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	if req.UserName == "" {
 		req.UserName = config.UserName
 	}
 
 	// Directly overwrite values instead of appending
-	err := h.UserService.UpdateUserSettings(req.UserName, req.AboutMe, req.Preferences)
+	err := h.UserService.UpdateUserSettings(req.UserName, req.AboutMe, req.Preferences, req.Options)
 	if err != nil {
 		http.Error(w, "Failed to update user settings", http.StatusInternalServerError)
 		return
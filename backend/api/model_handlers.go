@@ -0,0 +1,125 @@
+// api/model_handlers.go
+
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/ashuthe1/localmind/logger"
+	"github.com/ashuthe1/localmind/services"
+	"github.com/gorilla/mux"
+)
+
+// ListModelsHandler lists the models installed in the LLM backend.
+func (h *Handler) ListModelsHandler(w http.ResponseWriter, r *http.Request) {
+	models, err := h.LLM.ListModels(r.Context())
+	if err != nil {
+		logger.Log.Errorf("Error listing models: %v", err)
+		writeProviderError(w, err, "Failed to list models")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"defaultModel": h.LLM.DefaultModel(),
+		"models":       models,
+	})
+}
+
+// GetModelHandler returns the details of one installed model.
+func (h *Handler) GetModelHandler(w http.ResponseWriter, r *http.Request) {
+	manager, ok := h.modelManager(w)
+	if !ok {
+		return
+	}
+
+	details, err := manager.ShowModel(r.Context(), mux.Vars(r)["name"])
+	if err != nil {
+		logger.Log.Errorf("Error showing model: %v", err)
+		writeProviderError(w, err, "Failed to get model")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(details)
+}
+
+// PullModelHandler downloads a model and streams the progress as SSE "progress" events.
+func (h *Handler) PullModelHandler(w http.ResponseWriter, r *http.Request) {
+	manager, ok := h.modelManager(w)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Name == "" {
+		http.Error(w, "Model name is required", http.StatusBadRequest)
+		return
+	}
+
+	sse, ok := newSSEWriter(w)
+	if !ok {
+		http.Error(w, "Streaming unsupported!", http.StatusInternalServerError)
+		return
+	}
+
+	err := manager.PullModel(r.Context(), req.Name, func(progress services.PullProgress) error {
+		data, err := json.Marshal(progress)
+		if err != nil {
+			return err
+		}
+		return sse.Send("progress", string(data))
+	})
+	if err != nil {
+		logger.Log.Errorf("Error pulling model %s: %v", req.Name, err)
+		_ = sse.Send("error", err.Error())
+		return
+	}
+
+	logger.Log.Infof("Pulled model %s", req.Name)
+	_ = sse.Send("complete", "done")
+}
+
+// DeleteModelHandler removes an installed model.
+func (h *Handler) DeleteModelHandler(w http.ResponseWriter, r *http.Request) {
+	manager, ok := h.modelManager(w)
+	if !ok {
+		return
+	}
+
+	name := mux.Vars(r)["name"]
+	if err := manager.DeleteModel(r.Context(), name); err != nil {
+		logger.Log.Errorf("Error deleting model %s: %v", name, err)
+		writeProviderError(w, err, "Failed to delete model")
+		return
+	}
+
+	logger.Log.Infof("Deleted model %s", name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// modelManager returns the LLM provider as a ModelManager, or writes a 501 if it cannot manage models.
+func (h *Handler) modelManager(w http.ResponseWriter) (services.ModelManager, bool) {
+	manager, ok := h.LLM.(services.ModelManager)
+	if !ok {
+		http.Error(w, "Model management is not supported by the "+h.LLM.Name()+" provider", http.StatusNotImplemented)
+		return nil, false
+	}
+	return manager, true
+}
+
+// writeProviderError maps LLM provider errors onto HTTP status codes.
+func writeProviderError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrModelNotFound):
+		http.Error(w, "Model not found", http.StatusNotFound)
+	case errors.Is(err, services.ErrProviderUnavailable):
+		http.Error(w, "LLM backend unavailable", http.StatusServiceUnavailable)
+	default:
+		http.Error(w, message, http.StatusBadGateway)
+	}
+}
@@ -0,0 +1,193 @@
+// api/persona_handlers.go
+
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/ashuthe1/localmind/logger"
+	"github.com/ashuthe1/localmind/models"
+	"github.com/ashuthe1/localmind/services"
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ListPersonasHandler lists all personas.
+func (h *Handler) ListPersonasHandler(w http.ResponseWriter, r *http.Request) {
+	personas, err := h.Personas.ListPersonas()
+	if err != nil {
+		logger.Log.Errorf("Error listing personas: %v", err)
+		http.Error(w, "Failed to retrieve personas", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(personas)
+}
+
+// GetPersonaHandler returns one persona.
+func (h *Handler) GetPersonaHandler(w http.ResponseWriter, r *http.Request) {
+	personaID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid persona ID", http.StatusBadRequest)
+		return
+	}
+
+	persona, err := h.Personas.GetPersona(personaID)
+	if err != nil {
+		writePersonaError(w, err, "Failed to retrieve persona")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(persona)
+}
+
+// CreatePersonaHandler creates a persona.
+func (h *Handler) CreatePersonaHandler(w http.ResponseWriter, r *http.Request) {
+	var persona models.Persona
+	if err := json.NewDecoder(r.Body).Decode(&persona); err != nil {
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	if err := services.ValidatePersona(&persona); err != nil {
+		http.Error(w, "Invalid persona: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Personas.CreatePersona(&persona); err != nil {
+		writePersonaError(w, err, "Failed to create persona")
+		return
+	}
+
+	logger.Log.Infof("Created persona %q", persona.Name)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(persona)
+}
+
+// UpdatePersonaHandler replaces the editable fields of a persona.
+func (h *Handler) UpdatePersonaHandler(w http.ResponseWriter, r *http.Request) {
+	personaID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid persona ID", http.StatusBadRequest)
+		return
+	}
+
+	var persona models.Persona
+	if err := json.NewDecoder(r.Body).Decode(&persona); err != nil {
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
+		return
+	}
+	persona.ID = personaID
+
+	if err := services.ValidatePersona(&persona); err != nil {
+		http.Error(w, "Invalid persona: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Personas.UpdatePersona(&persona); err != nil {
+		writePersonaError(w, err, "Failed to update persona")
+		return
+	}
+
+	updated, err := h.Personas.GetPersona(personaID)
+	if err != nil {
+		writePersonaError(w, err, "Failed to retrieve persona")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// DeletePersonaHandler deletes a persona. Its chats fall back to the default persona.
+func (h *Handler) DeletePersonaHandler(w http.ResponseWriter, r *http.Request) {
+	personaID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid persona ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Personas.DeletePersona(personaID); err != nil {
+		writePersonaError(w, err, "Failed to delete persona")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UpdateChatPersonaHandler changes the persona applied to a chat. An empty ID switches
+// the chat to the default persona.
+func (h *Handler) UpdateChatPersonaHandler(w http.ResponseWriter, r *http.Request) {
+	chatID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid chat ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		PersonaID string `json:"personaId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	personaID := primitive.NilObjectID
+	if req.PersonaID != "" {
+		persona, err := h.requestedPersona(req.PersonaID)
+		if err != nil {
+			http.Error(w, "Persona not found", http.StatusBadRequest)
+			return
+		}
+		personaID = persona.ID
+	}
+
+	if err := h.ChatService.SetChatPersona(chatID, personaID); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			http.Error(w, "Chat not found", http.StatusNotFound)
+			return
+		}
+		logger.Log.Errorf("Error updating chat persona: %v", err)
+		http.Error(w, "Failed to update chat persona", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// requestedPersona loads the persona with the given hex ID, or the default persona
+// when id is empty. It returns nil without an error if there is no default persona.
+func (h *Handler) requestedPersona(id string) (*models.Persona, error) {
+	if id == "" {
+		persona, err := h.Personas.DefaultPersona()
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return persona, err
+	}
+
+	personaID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	return h.Personas.GetPersona(personaID)
+}
+
+// writePersonaError maps persona service errors onto HTTP status codes.
+func writePersonaError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+		http.Error(w, "Persona not found", http.StatusNotFound)
+	case errors.Is(err, services.ErrDefaultPersona):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		logger.Log.Errorf("%s: %v", message, err)
+		http.Error(w, message, http.StatusInternalServerError)
+	}
+}
@@ -18,10 +18,69 @@ func SetupRoutes(handler *Handler) *mux.Router {
 	apiRouter.HandleFunc("/chats", handler.GetChatsHandler).Methods(http.MethodGet)
 	apiRouter.HandleFunc("/chat/{id}", handler.DeleteChatHandler).Methods(http.MethodDelete)
 	apiRouter.HandleFunc("/chats", handler.DeleteAllChatsHandler).Methods(http.MethodDelete)
+	apiRouter.HandleFunc("/chat/{id}/events", handler.ChatEventsHandler).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/chat/{id}/title/regenerate", handler.RegenerateTitleHandler).Methods(http.MethodPost)
+	apiRouter.HandleFunc("/chat/{id}/model", handler.UpdateChatModelHandler).Methods(http.MethodPut)
+	apiRouter.HandleFunc("/chat/{id}/persona", handler.UpdateChatPersonaHandler).Methods(http.MethodPut)
+	apiRouter.HandleFunc("/chat/{id}/collections", handler.UpdateChatCollectionsHandler).Methods(http.MethodPut)
+	apiRouter.HandleFunc("/chat/{id}/options", handler.UpdateChatOptionsHandler).Methods(http.MethodPut)
+	apiRouter.HandleFunc("/chat/{id}/messages/{messageId}/pin", handler.PinMessageHandler).Methods(http.MethodPut)
+	apiRouter.HandleFunc("/chat/{id}/messages/{messageId}/regenerate", handler.RegenerateMessageHandler).Methods(http.MethodPost)
+	apiRouter.HandleFunc("/chat/{id}/messages/{messageId}/variant", handler.SelectVariantHandler).Methods(http.MethodPut)
+	apiRouter.HandleFunc("/chat/{id}/messages/{messageId}/edit", handler.EditMessageHandler).Methods(http.MethodPost)
+	apiRouter.HandleFunc("/chat/{id}/branches", handler.GetBranchesHandler).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/chat/{id}/branch", handler.SwitchBranchHandler).Methods(http.MethodPut)
+	apiRouter.HandleFunc("/generations/{id}/cancel", handler.CancelGenerationHandler).Methods(http.MethodPost)
+
+	// Persona routes
+	apiRouter.HandleFunc("/personas", handler.ListPersonasHandler).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/personas", handler.CreatePersonaHandler).Methods(http.MethodPost)
+	apiRouter.HandleFunc("/personas/{id}", handler.GetPersonaHandler).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/personas/{id}", handler.UpdatePersonaHandler).Methods(http.MethodPut)
+	apiRouter.HandleFunc("/personas/{id}", handler.DeletePersonaHandler).Methods(http.MethodDelete)
+
+	// Prompt template routes. Export and import come first so they are not taken for IDs.
+	apiRouter.HandleFunc("/templates/export", handler.ExportTemplatesHandler).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/templates/import", handler.ImportTemplatesHandler).Methods(http.MethodPost)
+	apiRouter.HandleFunc("/templates", handler.ListTemplatesHandler).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/templates", handler.CreateTemplateHandler).Methods(http.MethodPost)
+	apiRouter.HandleFunc("/templates/{id}", handler.GetTemplateHandler).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/templates/{id}", handler.UpdateTemplateHandler).Methods(http.MethodPut)
+	apiRouter.HandleFunc("/templates/{id}", handler.DeleteTemplateHandler).Methods(http.MethodDelete)
+	apiRouter.HandleFunc("/templates/{id}/render", handler.RenderTemplateHandler).Methods(http.MethodPost)
+	apiRouter.HandleFunc("/templates/{id}/chat", handler.StartTemplateChatHandler).Methods(http.MethodPost)
+
+	// Document collection routes
+	apiRouter.HandleFunc("/collections", handler.ListCollectionsHandler).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/collections", handler.CreateCollectionHandler).Methods(http.MethodPost)
+	apiRouter.HandleFunc("/collections/{id}", handler.GetCollectionHandler).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/collections/{id}", handler.DeleteCollectionHandler).Methods(http.MethodDelete)
+	apiRouter.HandleFunc("/collections/{id}/documents", handler.UploadDocumentsHandler).Methods(http.MethodPost)
+	apiRouter.HandleFunc("/documents/{id}", handler.DeleteDocumentHandler).Methods(http.MethodDelete)
+	apiRouter.HandleFunc("/documents/{id}/span", handler.GetDocumentSpanHandler).Methods(http.MethodGet)
+
+	// Attachment routes
+	apiRouter.HandleFunc("/attachments/{id}", handler.GetAttachmentHandler).Methods(http.MethodGet)
+
+	// Search routes
+	apiRouter.HandleFunc("/search", handler.SearchHandler).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/search/semantic", handler.SemanticSearchHandler).Methods(http.MethodGet)
 
 	// User settings routes
 	apiRouter.HandleFunc("/user", handler.GetUserSettingsHandler).Methods(http.MethodGet)
 	apiRouter.HandleFunc("/user", handler.UpdateUserSettingsHandler).Methods(http.MethodPut)
 	apiRouter.HandleFunc("/create-user", handler.CreateUserHandler).Methods(http.MethodPost)
+
+	// LLM provider routes
+	apiRouter.HandleFunc("/health", handler.HealthHandler).Methods(http.MethodGet)
+
+	// Tool routes
+	apiRouter.HandleFunc("/tools", handler.ListToolsHandler).Methods(http.MethodGet)
+
+	// Model management routes (model names may contain slashes, e.g. hf.co/org/model:tag)
+	apiRouter.HandleFunc("/models", handler.ListModelsHandler).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/models/pull", handler.PullModelHandler).Methods(http.MethodPost)
+	apiRouter.HandleFunc("/models/{name:.+}", handler.GetModelHandler).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/models/{name:.+}", handler.DeleteModelHandler).Methods(http.MethodDelete)
 	return router
 }
@@ -0,0 +1,139 @@
+// api/search_handlers.go
+
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ashuthe1/localmind/logger"
+	"github.com/ashuthe1/localmind/repository"
+	"github.com/ashuthe1/localmind/services"
+)
+
+const (
+	defaultSearchLimit = 10
+	maxSearchLimit     = 50
+
+	defaultSearchPageSize = 20
+	maxSearchPageSize     = 100
+)
+
+// SearchHandler runs a keyword search over chat titles and messages. Query parameters:
+// q (required, MongoDB text search syntax), from and to (RFC 3339 or YYYY-MM-DD, to is
+// inclusive for dates), role, model, page and pageSize.
+func (h *Handler) SearchHandler(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	query := repository.ChatSearchQuery{
+		Text:  strings.TrimSpace(params.Get("q")),
+		Role:  params.Get("role"),
+		Model: params.Get("model"),
+	}
+	if query.Text == "" {
+		http.Error(w, "Query is required", http.StatusBadRequest)
+		return
+	}
+	if query.Role != "" && query.Role != "user" && query.Role != "assistant" {
+		http.Error(w, "Role must be user or assistant", http.StatusBadRequest)
+		return
+	}
+
+	var err error
+	if query.From, err = parseSearchTime(params.Get("from"), false); err != nil {
+		http.Error(w, "Invalid from date", http.StatusBadRequest)
+		return
+	}
+	if query.To, err = parseSearchTime(params.Get("to"), true); err != nil {
+		http.Error(w, "Invalid to date", http.StatusBadRequest)
+		return
+	}
+
+	query.Page, query.PageSize = 1, defaultSearchPageSize
+	if value := params.Get("page"); value != "" {
+		if query.Page, err = strconv.Atoi(value); err != nil || query.Page < 1 {
+			http.Error(w, "Invalid page", http.StatusBadRequest)
+			return
+		}
+	}
+	if value := params.Get("pageSize"); value != "" {
+		if query.PageSize, err = strconv.Atoi(value); err != nil || query.PageSize < 1 || query.PageSize > maxSearchPageSize {
+			http.Error(w, "Page size must be between 1 and "+strconv.Itoa(maxSearchPageSize), http.StatusBadRequest)
+			return
+		}
+	}
+
+	results, err := h.ChatService.SearchChats(query)
+	if err != nil {
+		logger.Log.Errorf("Error searching chats: %v", err)
+		http.Error(w, "Failed to search chats", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
+// parseSearchTime parses an RFC 3339 time or a YYYY-MM-DD date. With endOfDay, a
+// date means the end of that day, so "to" includes it.
+func parseSearchTime(value string, endOfDay bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// SemanticSearchHandler finds the past messages closest in meaning to ?q=, best first.
+// ?limit= caps the number of results.
+func (h *Handler) SemanticSearchHandler(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		http.Error(w, "Query is required", http.StatusBadRequest)
+		return
+	}
+	limit, ok := searchLimit(w, r)
+	if !ok {
+		return
+	}
+
+	results, err := h.Indexer.Search(r.Context(), query, limit)
+	if err != nil {
+		logger.Log.Errorf("Error searching messages: %v", err)
+		if errors.Is(err, services.ErrEmbeddingsUnsupported) {
+			http.Error(w, err.Error(), http.StatusNotImplemented)
+			return
+		}
+		writeProviderError(w, err, "Failed to search messages")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
+// searchLimit parses ?limit=, writing a 400 if it is invalid.
+func searchLimit(w http.ResponseWriter, r *http.Request) (int, bool) {
+	value := r.URL.Query().Get("limit")
+	if value == "" {
+		return defaultSearchLimit, true
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 || limit > maxSearchLimit {
+		http.Error(w, "Limit must be between 1 and "+strconv.Itoa(maxSearchLimit), http.StatusBadRequest)
+		return 0, false
+	}
+	return limit, true
+}
@@ -0,0 +1,65 @@
+// api/sse.go
+
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// sseWriter serialises writes to a Server-Sent Events stream so the heartbeat
+// goroutine and the streaming callback never interleave.
+type sseWriter struct {
+	mu      sync.Mutex
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+func newSSEWriter(w http.ResponseWriter) (*sseWriter, bool) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, false
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("Keep-Alive", "timeout=600, max=100")
+
+	return &sseWriter{w: w, flusher: flusher}, true
+}
+
+// Send writes one event. Multi-line data is split into several data fields so
+// newlines inside model output survive the SSE framing. An empty event name
+// sends a default "message" event.
+func (s *sseWriter) Send(event string, data string) error {
+	var buf strings.Builder
+	if event != "" {
+		fmt.Fprintf(&buf, "event: %s\n", event)
+	}
+	for _, line := range strings.Split(data, "\n") {
+		fmt.Fprintf(&buf, "data: %s\n", line)
+	}
+	buf.WriteString("\n")
+
+	return s.write(buf.String())
+}
+
+// Ping writes an SSE comment that keeps the connection alive without
+// producing an event on the client.
+func (s *sseWriter) Ping() error {
+	return s.write(": ping\n\n")
+}
+
+func (s *sseWriter) write(payload string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.w.Write([]byte(payload)); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
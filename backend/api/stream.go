@@ -0,0 +1,394 @@
+// api/stream.go
+
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ashuthe1/localmind/config"
+	"github.com/ashuthe1/localmind/logger"
+	"github.com/ashuthe1/localmind/models"
+	"github.com/ashuthe1/localmind/services"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// replyParams describes the assistant reply streamReply generates.
+type replyParams struct {
+	Chat    *models.Chat              // The reply continues Chat.Messages
+	Model   string                    // Overrides the chat's model when set
+	Options *models.GenerationOptions // Request options, merged over the chat's and the user's
+	Schema  models.RawJSON            // JSON schema the reply must match; overrides the persona's
+	// SaveTool stores the tool_call and tool_result messages of the reply in order.
+	// The model is only offered tools when it is set.
+	SaveTool func(msg models.Message) error
+}
+
+// streamReply generates an assistant reply and streams it to the client as SSE:
+// a "generation" event with the ID to cancel it, "citations" when documents were
+// retrieved, "queued" with the queue position while it waits for the model,
+// "reasoning" and "answer" events while it runs, "tool_call" and
+// "tool_result" around every tool the model runs, then "complete". With a JSON
+// schema, "retry" reports a reply that did not match it and is asked for again, and
+// "structured" carries the valid result. The reply, including a partial one, is
+// passed to save before "complete" is sent.
+func (h *Handler) streamReply(w http.ResponseWriter, r *http.Request, params replyParams, save func(reply models.Message) error) {
+	chat := params.Chat
+
+	// Setup SSE headers
+	sse, ok := newSSEWriter(w)
+	if !ok {
+		logger.Log.Error("Streaming unsupported")
+		http.Error(w, "Streaming unsupported!", http.StatusInternalServerError)
+		return
+	}
+
+	// Cancelling ctx stops the generation. That happens when the client disconnects,
+	// when the server shuts down, when writing to the client fails and when the
+	// generation is cancelled through the API.
+	generationID, ctx, finishGeneration := h.Generations.Start(r.Context())
+	defer finishGeneration()
+	cancel := func() { h.Generations.Cancel(generationID) }
+
+	// Tell the client which generation to cancel before any text is streamed
+	generationInfo, _ := json.Marshal(map[string]string{"id": generationID, "chatId": chat.ID.Hex()})
+	if err := sse.Send("generation", string(generationInfo)); err != nil {
+		logger.Log.Printf("Error sending generation ID: %v", err)
+		return
+	}
+
+	// Start heartbeat to keep connection alive
+	heartbeatTicker := time.NewTicker(1 * time.Second) // More frequent heartbeats
+	defer heartbeatTicker.Stop()
+
+	assistantResponse := ""
+
+	// Goroutine to send heartbeats
+	go func() {
+		for {
+			select {
+			case <-heartbeatTicker.C:
+				if err := sse.Ping(); err != nil {
+					logger.Log.Printf("Error sending heartbeat: %v", err)
+					cancel()
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// Reasoning (<think>...</think>) and answer text are streamed as separate events
+	var parser services.ReasoningParser
+	// Set while the model calls tools through <tool_call> blocks in its answer
+	var toolParser *services.ToolCallParser
+	assistantReasoning := ""
+	emit := func(segments []services.Segment) error {
+		for _, segment := range segments {
+			if segment.Kind == services.SegmentAnswer && toolParser != nil {
+				if segment.Text = toolParser.Feed(segment.Text); segment.Text == "" {
+					continue
+				}
+			}
+			if err := sse.Send(segment.Kind, segment.Text); err != nil {
+				logger.Log.Println("Error sending chunk:", err)
+				cancel()
+				return err
+			}
+			if segment.Kind == services.SegmentReasoning {
+				assistantReasoning += segment.Text
+			} else {
+				assistantResponse += segment.Text
+			}
+		}
+		return nil
+	}
+
+	// Callback function to send streamed data
+	sendChunk := func(chunk string) error {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("client disconnected")
+		default:
+			return emit(parser.Feed(chunk))
+		}
+	}
+
+	// Replay the conversation within the model's context budget, preceded by the
+	// persona's system prompt and examples and the user's settings
+	persona := h.Personas.PersonaForChat(chat)
+	var personaOptions *models.GenerationOptions
+	schema := params.Schema
+	model := params.Model
+	if model == "" {
+		model = chat.Model
+	}
+	if persona != nil {
+		personaOptions = persona.Options
+		if len(schema) == 0 {
+			schema = persona.Schema
+		}
+		if model == "" {
+			model = persona.Model
+		}
+	}
+	if model == "" {
+		model = h.LLM.DefaultModel()
+	}
+	// Request options win over the chat's, then the persona's, then the user's defaults
+	options := services.MergeOptions(params.Options, chat.Options, personaOptions, h.userOptions())
+	numCtx := 0
+	if options != nil && options.NumCtx != nil {
+		numCtx = *options.NumCtx
+	}
+	preamble := services.PersonaPreamble(persona, h.UserService.UserRepo.GenerateUserContext())
+	// Tools are not offered when the reply has to be JSON
+	toolMode := services.ToolModeOff
+	if params.SaveTool != nil && len(schema) == 0 {
+		toolMode = h.Tools.ModeFor(ctx, model)
+	}
+	if toolMode == services.ToolModePrompt {
+		preamble = append(preamble, h.Tools.ToolPrompt())
+	}
+	if len(schema) > 0 {
+		preamble = append(preamble, h.Structured.SchemaPrompt(schema))
+	}
+	retrieved := h.retrieveForChat(ctx, chat)
+	var citations []models.Citation
+	if len(retrieved) > 0 {
+		preamble = append(preamble, services.ChatMessage{Role: "system", Content: services.FormatRetrievedContext(retrieved)})
+
+		// Tell the client which sources the answer is based on before it streams
+		citations = services.Citations(retrieved)
+		citationData, _ := json.Marshal(citations)
+		if err := sse.Send("citations", string(citationData)); err != nil {
+			logger.Log.Printf("Error sending citations: %v", err)
+			cancel()
+		}
+	}
+
+	// Wait for the model to be free. The heartbeat keeps the connection open meanwhile.
+	release, err := h.Scheduler.Acquire(ctx, services.GenerationRequest{
+		Model:    model,
+		User:     config.UserName,
+		Priority: services.PriorityInteractive,
+	}, func(position int) {
+		queueData, _ := json.Marshal(map[string]int{"position": position})
+		if err := sse.Send("queued", string(queueData)); err != nil {
+			logger.Log.Printf("Error sending queue position: %v", err)
+			cancel()
+		}
+	})
+	if err == nil {
+		defer release()
+	}
+
+	history := h.ContextManager.BuildContext(ctx, chat, preamble, model, numCtx)
+	h.Attachments.LoadAttachments(history)
+
+	// Stream the response from the configured LLM provider. Every round that calls
+	// tools is followed by another with their results, and every reply that does not
+	// match the schema by another with the validation errors, until the model answers.
+	var data models.RawJSON
+	invalid := false
+	retries := 0
+	for round := 0; err == nil; round++ {
+		// The last round offers no tools, so the model has to answer
+		useTools := toolMode != services.ToolModeOff && round < h.Tools.MaxRounds
+		req := services.ChatRequest{Model: model, Messages: history, Options: options, Schema: schema}
+		if useTools && toolMode == services.ToolModeNative {
+			req.Tools = h.Tools.Registry.Definitions()
+		} else {
+			req.Messages = services.PromptToolMessages(history)
+		}
+		if useTools && toolMode == services.ToolModePrompt {
+			toolParser = &services.ToolCallParser{}
+		}
+		parser = services.ReasoningParser{}
+		roundStart := len(assistantResponse)
+
+		var result *services.ChatResult
+		result, err = h.LLM.StreamChat(ctx, req, sendChunk)
+		_ = emit(parser.Flush())
+
+		var calls []models.ToolCall
+		if result != nil {
+			calls = result.ToolCalls
+		}
+		if toolParser != nil {
+			rest := toolParser.Flush()
+			calls = toolParser.Calls()
+			toolParser = nil
+			if rest != "" {
+				_ = emit([]services.Segment{{Kind: services.SegmentAnswer, Text: rest}})
+			}
+		}
+		if err != nil || ctx.Err() != nil {
+			break
+		}
+
+		if len(schema) > 0 {
+			var problems []string
+			if data, problems = h.Structured.Parse(schema, assistantResponse); problems == nil {
+				if err := sse.Send("structured", string(data)); err != nil {
+					logger.Log.Printf("Error sending structured output: %v", err)
+				}
+				break
+			}
+			if retries >= h.Structured.MaxRetries {
+				logger.Log.Warnf("Reply still does not match the schema after %d retries: %v", retries, problems)
+				invalid = true
+				break
+			}
+			retries++
+
+			// Ask again with the errors. The client discards the streamed attempt.
+			retryData, _ := json.Marshal(map[string]interface{}{"attempt": retries, "errors": problems})
+			if err := sse.Send("retry", string(retryData)); err != nil {
+				logger.Log.Printf("Error sending retry: %v", err)
+				cancel()
+				break
+			}
+			history = append(history, services.ChatMessage{Role: "assistant", Content: assistantResponse}, h.Structured.RepairPrompt(problems))
+			assistantResponse, assistantReasoning = "", ""
+			continue
+		}
+		if !useTools || len(calls) == 0 {
+			break
+		}
+
+		for i := range calls {
+			if calls[i].ID == "" {
+				calls[i].ID = "call_" + primitive.NewObjectID().Hex()
+			}
+		}
+		history = append(history, services.ChatMessage{Role: "assistant", Content: assistantResponse[roundStart:], ToolCalls: calls})
+		for _, call := range calls {
+			toolMessage, sendErr := h.runTool(ctx, sse, call, model, params.SaveTool)
+			history = append(history, toolMessage)
+			if sendErr != nil {
+				logger.Log.Printf("Error sending tool events: %v", sendErr)
+				cancel()
+			}
+			if ctx.Err() != nil {
+				break
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	status := ""
+	if ctx.Err() != nil {
+		status = models.MessageStatusAborted
+		if r.Context().Err() == nil {
+			// Cancelled through the API while the client is still listening
+			logger.Log.Printf("Generation %s cancelled", generationID)
+			_ = sse.Send("aborted", generationID)
+		} else {
+			logger.Log.Println("Client disconnected or server shutting down, generation stopped")
+		}
+	} else if err != nil {
+		status = models.MessageStatusError
+		logger.Log.Errorf("Error streaming response from LLM: %v", err)
+		_ = emit([]services.Segment{{Kind: services.SegmentAnswer, Text: "[ERROR] Failed to complete response."}})
+	} else if invalid {
+		status = models.MessageStatusInvalid
+	}
+
+	// Save the assistant's response, including partial replies, with their status
+	if assistantResponse != "" || assistantReasoning != "" {
+		reply := models.Message{
+			ID:        primitive.NewObjectID(),
+			Role:      "assistant",
+			Content:   assistantResponse,
+			Reasoning: strings.TrimSpace(assistantReasoning),
+			Model:     model,
+			Status:    status,
+			Citations: citations,
+			Data:      data,
+			Timestamp: time.Now(),
+		}
+		if err := save(reply); err != nil {
+			logger.Log.Errorf("Error saving assistant message: %v", err)
+		}
+	}
+
+	_ = sse.Send("complete", "done")
+}
+
+// runTool executes one tool call, streams it as "tool_call" and "tool_result" events,
+// stores both with save and returns the result message for the model. The error is
+// set when the events could not be sent.
+func (h *Handler) runTool(ctx context.Context, sse *sseWriter, call models.ToolCall, model string, save func(msg models.Message) error) (services.ChatMessage, error) {
+	callData, _ := json.Marshal(call)
+	sendErr := sse.Send("tool_call", string(callData))
+	if err := save(models.Message{
+		ID:        primitive.NewObjectID(),
+		Role:      models.MessageRoleToolCall,
+		Model:     model,
+		ToolCall:  &call,
+		Timestamp: time.Now(),
+	}); err != nil {
+		logger.Log.Errorf("Error saving tool call: %v", err)
+	}
+
+	status := ""
+	result, err := h.Tools.Registry.Execute(ctx, call.Name, call.Arguments)
+	if err != nil {
+		logger.Log.Warnf("Tool %s failed: %v", call.Name, err)
+		status = models.MessageStatusError
+		result = "Error: " + err.Error()
+	}
+
+	resultData, _ := json.Marshal(map[string]interface{}{
+		"id":     call.ID,
+		"name":   call.Name,
+		"result": result,
+		"error":  err != nil,
+	})
+	if err := sse.Send("tool_result", string(resultData)); err != nil && sendErr == nil {
+		sendErr = err
+	}
+	if err := save(models.Message{
+		ID:        primitive.NewObjectID(),
+		Role:      models.MessageRoleToolResult,
+		Content:   result,
+		Status:    status,
+		ToolCall:  &call,
+		Timestamp: time.Now(),
+	}); err != nil {
+		logger.Log.Errorf("Error saving tool result: %v", err)
+	}
+
+	return services.ChatMessage{Role: "tool", Content: result, ToolCallID: call.ID, ToolName: call.Name}, sendErr
+}
+
+// retrieveForChat returns the document chunks from the chat's collections that best
+// match its latest user message. Retrieval errors are logged and answered without documents.
+func (h *Handler) retrieveForChat(ctx context.Context, chat *models.Chat) []services.RetrievedChunk {
+	if len(chat.Collections) == 0 {
+		return nil
+	}
+
+	query := ""
+	for i := len(chat.Messages) - 1; i >= 0; i-- {
+		if chat.Messages[i].Role == "user" {
+			query = chat.Messages[i].Content
+			break
+		}
+	}
+
+	chunks, err := h.RAG.Retrieve(ctx, chat.Collections, query, 0)
+	if err != nil {
+		logger.Log.Errorf("Error retrieving documents for chat %s: %v", chat.ID.Hex(), err)
+		return nil
+	}
+	return chunks
+}
@@ -0,0 +1,247 @@
+// api/template_handlers.go
+
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/ashuthe1/localmind/logger"
+	"github.com/ashuthe1/localmind/models"
+	"github.com/ashuthe1/localmind/services"
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ListTemplatesHandler lists all prompt templates.
+func (h *Handler) ListTemplatesHandler(w http.ResponseWriter, r *http.Request) {
+	templates, err := h.Templates.ListTemplates()
+	if err != nil {
+		logger.Log.Errorf("Error listing templates: %v", err)
+		http.Error(w, "Failed to retrieve templates", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(templates)
+}
+
+// GetTemplateHandler returns one prompt template.
+func (h *Handler) GetTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	template, ok := h.templateFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(template)
+}
+
+// CreateTemplateHandler creates a prompt template. Placeholders that are not declared
+// in "variables" become required variables.
+func (h *Handler) CreateTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	var template models.PromptTemplate
+	if err := json.NewDecoder(r.Body).Decode(&template); err != nil {
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
+		return
+	}
+	if err := services.ValidateTemplate(&template); err != nil {
+		http.Error(w, "Invalid template: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Templates.CreateTemplate(&template); err != nil {
+		writeTemplateError(w, err, "Failed to create template")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(template)
+}
+
+// UpdateTemplateHandler replaces the editable fields of a prompt template.
+func (h *Handler) UpdateTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	templateID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid template ID", http.StatusBadRequest)
+		return
+	}
+
+	var template models.PromptTemplate
+	if err := json.NewDecoder(r.Body).Decode(&template); err != nil {
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
+		return
+	}
+	template.ID = templateID
+	if err := services.ValidateTemplate(&template); err != nil {
+		http.Error(w, "Invalid template: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Templates.UpdateTemplate(&template); err != nil {
+		writeTemplateError(w, err, "Failed to update template")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(template)
+}
+
+// DeleteTemplateHandler deletes a prompt template.
+func (h *Handler) DeleteTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	templateID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid template ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Templates.DeleteTemplate(templateID); err != nil {
+		writeTemplateError(w, err, "Failed to delete template")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RenderTemplateHandler returns a template rendered with the given variable values.
+func (h *Handler) RenderTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	template, ok := h.templateFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Values map[string]string `json:"values"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	content, err := services.RenderTemplate(template, req.Values)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"content": content})
+}
+
+// StartTemplateChatHandler renders a template and starts a new chat with the result as
+// the first user message. The reply is streamed like SendMessageHandler's.
+func (h *Handler) StartTemplateChatHandler(w http.ResponseWriter, r *http.Request) {
+	template, ok := h.templateFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Values    map[string]string         `json:"values"`
+		Model     string                    `json:"model,omitempty"`     // Overrides the template's model
+		PersonaID string                    `json:"personaId,omitempty"` // Overrides the template's persona
+		Options   *models.GenerationOptions `json:"options,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
+		return
+	}
+	if err := services.ValidateOptions(req.Options); err != nil {
+		http.Error(w, "Invalid options: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	content, err := services.RenderTemplate(template, req.Values)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	personaID := req.PersonaID
+	if personaID == "" && !template.PersonaID.IsZero() {
+		personaID = template.PersonaID.Hex()
+	}
+	model := req.Model
+	if model == "" {
+		model = template.Model
+	}
+
+	chat, ok := h.newChat(w, r, personaID, model)
+	if !ok {
+		return
+	}
+	h.sendUserMessage(w, r, chat.ID, userTurn{Content: content, Options: req.Options})
+}
+
+// ExportTemplatesHandler downloads every template as a JSON document for sharing.
+func (h *Handler) ExportTemplatesHandler(w http.ResponseWriter, r *http.Request) {
+	export, err := h.Templates.ExportTemplates()
+	if err != nil {
+		logger.Log.Errorf("Error exporting templates: %v", err)
+		http.Error(w, "Failed to export templates", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="templates.json"`)
+	json.NewEncoder(w).Encode(export)
+}
+
+// ImportTemplatesHandler stores the templates of an exported document. Templates
+// with an existing name are skipped unless ?overwrite=true.
+func (h *Handler) ImportTemplatesHandler(w http.ResponseWriter, r *http.Request) {
+	var export services.TemplateExport
+	if err := json.NewDecoder(r.Body).Decode(&export); err != nil {
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.Templates.ImportTemplates(&export, r.URL.Query().Get("overwrite") == "true")
+	if err != nil {
+		if result == nil {
+			// Nothing was stored, the document itself is invalid
+			http.Error(w, "Invalid templates: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		logger.Log.Errorf("Error importing templates: %v", err)
+		http.Error(w, "Failed to import templates", http.StatusInternalServerError)
+		return
+	}
+
+	logger.Log.Infof("Imported templates: %d created, %d updated, %d skipped", len(result.Created), len(result.Updated), len(result.Skipped))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// templateFromPath loads the template named by the {id} path variable. It writes the
+// HTTP error on failure.
+func (h *Handler) templateFromPath(w http.ResponseWriter, r *http.Request) (*models.PromptTemplate, bool) {
+	templateID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid template ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	template, err := h.Templates.GetTemplate(templateID)
+	if err != nil {
+		writeTemplateError(w, err, "Failed to retrieve template")
+		return nil, false
+	}
+	return template, true
+}
+
+// writeTemplateError maps template service errors onto HTTP status codes.
+func writeTemplateError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+		http.Error(w, "Template not found", http.StatusNotFound)
+	case errors.Is(err, services.ErrTemplateExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		logger.Log.Errorf("%s: %v", message, err)
+		http.Error(w, message, http.StatusInternalServerError)
+	}
+}
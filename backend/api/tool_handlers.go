@@ -0,0 +1,18 @@
+// api/tool_handlers.go
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ListToolsHandler lists the tools the model can call and how they are offered to
+// the default model.
+func (h *Handler) ListToolsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"mode":  h.Tools.ModeFor(r.Context(), h.LLM.DefaultModel()),
+		"tools": h.Tools.Registry.Definitions(),
+	})
+}
@@ -3,9 +3,11 @@ package main
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/ashuthe1/localmind/api"
@@ -37,20 +39,79 @@ func main() {
 	db := mongoClient.Database(cfg.DatabaseName)
 	chatRepo := repository.NewChatRepository(db)
 	userRepo := repository.NewUserRepository(db)
-	chatService := services.NewChatService(chatRepo)
-	ollamaService := services.NewOllamaService()
+	personaRepo := repository.NewPersonaRepository(db)
+	templateRepo := repository.NewTemplateRepository(db)
+	documentRepo := repository.NewDocumentRepository(db)
+	embeddingRepo := repository.NewEmbeddingRepository(db)
+	attachmentRepo := repository.NewAttachmentRepository(db)
+	if err := chatRepo.EnsureIndexes(); err != nil {
+		logger.Log.Errorf("Failed to create chat search index: %v", err)
+	}
 	userService := services.NewUserService(userRepo)
-	handler := api.NewHandler(chatService, ollamaService, userService)
+	personaService := services.NewPersonaService(personaRepo)
+	templateService := services.NewTemplateService(templateRepo)
+	if err := personaService.EnsureDefaultPersona(); err != nil {
+		logger.Log.Errorf("Failed to create the default persona: %v", err)
+	}
+
+	// Register the available LLM backends and pick the configured one
+	providers := services.NewProviderRegistry()
+	providers.Register(services.NewOllamaService(cfg.OllamaBaseURL, cfg.ModelName, cfg.OllamaCLIFallback))
+	providers.Register(services.NewOpenAIService(cfg.OpenAIBaseURL, cfg.OpenAIAPIKey, cfg.OpenAIModel))
+	llm, err := providers.Get(cfg.LLMProvider)
+	if err != nil {
+		logger.Log.Errorf("Failed to select LLM provider: %v", err)
+		os.Exit(1)
+	}
+	if openai, ok := llm.(*services.OpenAIService); ok {
+		detectCtx, cancelDetect := context.WithTimeout(context.Background(), 10*time.Second)
+		err := openai.DetectModel(detectCtx)
+		cancelDetect()
+		if err != nil {
+			logger.Log.Errorf("OPENAI_MODEL is not set and no model could be detected: %v", err)
+			os.Exit(1)
+		}
+	}
+	logger.Log.Infof("Using LLM provider %q with default model %q", llm.Name(), llm.DefaultModel())
+
+	indexer := services.NewMessageIndexer(chatRepo, embeddingRepo, llm, cfg.EmbeddingModel)
+	chatService := services.NewChatService(chatRepo, indexer)
+
+	contextManager := services.NewContextManager(chatRepo, llm, cfg.ContextStrategy, cfg.ContextTokenBudget, cfg.ModelContextBudgets, cfg.ContextReserveTokens)
+
+	rag := services.NewRAGService(documentRepo, llm, cfg.EmbeddingModel, cfg.RAGChunkSize, cfg.RAGChunkOverlap, cfg.RAGTopK, cfg.DocumentMaxBytes)
+	if err := services.ValidateChunking(cfg.RAGChunkSize, cfg.RAGChunkOverlap); err != nil {
+		logger.Log.Errorf("Invalid RAG_CHUNK_SIZE or RAG_CHUNK_OVERLAP: %v", err)
+		os.Exit(1)
+	}
+
+	tools := services.NewToolService(services.NewBuiltinToolRegistry(), llm, cfg.ToolCalling, cfg.ToolMaxRounds)
+	structured := services.NewStructuredOutputService(cfg.StructuredOutputRetries)
+	attachments := services.NewAttachmentService(attachmentRepo, cfg.UploadDir, cfg.ImageMaxBytes, cfg.FileMaxBytes)
+
+	generations := services.NewGenerationTracker()
+	scheduler := services.NewGenerationScheduler(cfg.GenerationQueue, cfg.GenerationConcurrency, cfg.ModelGenerationConcurrency)
+	events := services.NewChatEventBroker()
+	titles := services.NewTitleService(chatRepo, llm, events, scheduler)
+
+	handler := api.NewHandler(chatService, llm, userService, contextManager, generations, titles, events, personaService, templateService, rag, indexer, tools, structured, attachments, scheduler)
 	router := api.SetupRoutes(handler)
 
+	// Every request context derives from baseCtx, so cancelling it on shutdown
+	// stops in-flight generations right away.
+	baseCtx, cancelRequests := context.WithCancel(context.Background())
 	srv := &http.Server{
 		Addr:         cfg.ServerAddress,
 		WriteTimeout: 10 * time.Minute,
 		ReadTimeout:  10 * time.Minute,
 		IdleTimeout:  10 * time.Minute,
 		Handler:      router,
+		BaseContext:  func(net.Listener) context.Context { return baseCtx },
 	}
 
+	// Embed chat messages for semantic search in the background
+	indexer.Start(baseCtx)
+
 	// Start Server
 	go func() {
 		fmt.Printf("Server is running on %s\n", cfg.ServerAddress)
@@ -60,16 +121,19 @@ func main() {
 	}()
 
 	// Graceful Shutdown
-	waitForShutdown(srv)
+	waitForShutdown(srv, cancelRequests)
 }
 
-func waitForShutdown(srv *http.Server) {
+func waitForShutdown(srv *http.Server, cancelRequests context.CancelFunc) {
 	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, os.Interrupt)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
 	<-quit
 
 	logger.Log.Warn("Server is shutting down...")
 
+	// Stop running generations so streaming handlers save their partial replies and return
+	cancelRequests()
+
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
 
@@ -8,6 +8,8 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -26,6 +28,39 @@ type Config struct {
 	DatabaseName  string
 	ModelName     string
 	UserName      string
+
+	LLMProvider string
+
+	OllamaBaseURL     string
+	OllamaCLIFallback bool
+
+	OpenAIBaseURL string
+	OpenAIAPIKey  string
+	OpenAIModel   string
+
+	ContextStrategy      string
+	ContextTokenBudget   int
+	ContextReserveTokens int
+	ModelContextBudgets  map[string]int
+
+	EmbeddingModel   string
+	RAGChunkSize     int
+	RAGChunkOverlap  int
+	RAGTopK          int
+	DocumentMaxBytes int64
+
+	ToolCalling   string
+	ToolMaxRounds int
+
+	StructuredOutputRetries int
+
+	UploadDir     string
+	ImageMaxBytes int64
+	FileMaxBytes  int64
+
+	GenerationQueue            string
+	GenerationConcurrency      int
+	ModelGenerationConcurrency map[string]int
 }
 
 func LoadConfig() *Config {
@@ -51,6 +86,39 @@ func LoadConfig() *Config {
 		DatabaseName:  getEnv("DATABASE_NAME", "LocalMind"),
 		ModelName:     getEnv("MODEL_NAME", "deepseek-r1:8b"),
 		UserName:      getEnv("USERNAME", "ashuthe1"),
+
+		LLMProvider: getEnv("LLM_PROVIDER", "ollama"),
+
+		OllamaBaseURL:     getEnv("OLLAMA_BASE_URL", "http://localhost:11434"),
+		OllamaCLIFallback: getEnvBool("OLLAMA_CLI_FALLBACK", true),
+
+		OpenAIBaseURL: getEnv("OPENAI_BASE_URL", "http://localhost:1234/v1"),
+		OpenAIAPIKey:  getEnv("OPENAI_API_KEY", ""),
+		OpenAIModel:   getEnv("OPENAI_MODEL", ""),
+
+		ContextStrategy:      getEnv("CONTEXT_STRATEGY", "drop_oldest"),
+		ContextTokenBudget:   getEnvInt("CONTEXT_TOKEN_BUDGET", 4096),
+		ContextReserveTokens: getEnvInt("CONTEXT_RESERVE_TOKENS", 1024),
+		ModelContextBudgets:  getEnvIntMap("MODEL_CONTEXT_BUDGETS"),
+
+		EmbeddingModel:   getEnv("EMBEDDING_MODEL", "nomic-embed-text"),
+		RAGChunkSize:     getEnvInt("RAG_CHUNK_SIZE", 1000),
+		RAGChunkOverlap:  getEnvInt("RAG_CHUNK_OVERLAP", 200),
+		RAGTopK:          getEnvInt("RAG_TOP_K", 4),
+		DocumentMaxBytes: int64(getEnvInt("DOCUMENT_MAX_BYTES", 5<<20)),
+
+		ToolCalling:   getEnv("TOOL_CALLING", "auto"),
+		ToolMaxRounds: getEnvInt("TOOL_MAX_ROUNDS", 5),
+
+		StructuredOutputRetries: getEnvInt("STRUCTURED_OUTPUT_RETRIES", 2),
+
+		UploadDir:     getEnv("UPLOAD_DIR", "uploads"),
+		ImageMaxBytes: int64(getEnvInt("IMAGE_MAX_BYTES", 10<<20)),
+		FileMaxBytes:  int64(getEnvInt("FILE_MAX_BYTES", 256<<10)),
+
+		GenerationQueue:            getEnv("GENERATION_QUEUE", "fifo"),
+		GenerationConcurrency:      getEnvInt("GENERATION_CONCURRENCY", 1),
+		ModelGenerationConcurrency: getEnvIntMap("MODEL_GENERATION_CONCURRENCY"),
 	}
 }
 
@@ -62,6 +130,43 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+// getEnvBool parses a boolean environment variable, falling back to the default if unset or invalid.
+func getEnvBool(key string, defaultValue bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvInt parses an integer environment variable, falling back to the default if unset or invalid.
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvIntMap parses a comma-separated list of name=value pairs, e.g.
+// "deepseek-r1:8b=8192,llama3.2=4096". Invalid entries are skipped.
+func getEnvIntMap(key string) map[string]int {
+	values := make(map[string]int)
+	for _, pair := range strings.Split(os.Getenv(key), ",") {
+		idx := strings.LastIndex(pair, "=")
+		if idx <= 0 {
+			continue
+		}
+		value, err := strconv.Atoi(strings.TrimSpace(pair[idx+1:]))
+		if err != nil {
+			log.Printf("Ignoring invalid %s entry %q", key, pair)
+			continue
+		}
+		values[strings.TrimSpace(pair[:idx])] = value
+	}
+	return values
+}
+
 // ConnectMongoDB establishes a connection to the MongoDB database.
 func ConnectMongoDB(uri string) (*mongo.Client, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -0,0 +1,27 @@
+// models/attachment.go
+
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Kinds of files that can be attached to a user message.
+const (
+	AttachmentKindImage = "image" // Sent to vision models alongside the message
+	AttachmentKindFile  = "file"  // Text file included in the prompt with the message
+)
+
+// Attachment is a file sent with a user message. The file itself is kept on disk
+// under UPLOAD_DIR; messages hold a copy of this metadata.
+type Attachment struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ChatID      primitive.ObjectID `bson:"chatId" json:"chatId"`
+	Kind        string             `bson:"kind" json:"kind"`
+	Name        string             `bson:"name" json:"name"` // Original file name
+	ContentType string             `bson:"contentType" json:"contentType"`
+	Size        int64              `bson:"size" json:"size"`
+	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
+}
@@ -10,9 +10,15 @@ import (
 
 // Chat represents a chat session containing multiple messages.
 type Chat struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	Title     string             `bson:"title" json:"title"`         // Optional title for the chat
-	Messages  []Message          `bson:"messages" json:"messages"`   // Slice of messages
-	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"` // Chat creation time
-	UpdatedAt time.Time          `bson:"updatedAt" json:"updatedAt"` // Last update time
-}
\ No newline at end of file
+	ID           primitive.ObjectID   `bson:"_id,omitempty" json:"id"`
+	Title        string               `bson:"title" json:"title"`                                   // Optional title for the chat
+	Messages     []Message            `bson:"messages" json:"messages"`                             // Messages of every branch, in creation order
+	ActiveLeafID primitive.ObjectID   `bson:"activeLeafId,omitempty" json:"activeLeafId,omitempty"` // Last message of the branch being shown
+	Model        string               `bson:"model,omitempty" json:"model,omitempty"`               // Model used for new replies
+	PersonaID    primitive.ObjectID   `bson:"personaId,omitempty" json:"personaId,omitempty"`       // Persona applied on every turn; the default persona when zero
+	Options      *GenerationOptions   `bson:"options,omitempty" json:"options,omitempty"`           // Default generation options for the chat
+	Collections  []primitive.ObjectID `bson:"collections,omitempty" json:"collections,omitempty"`   // Document collections retrieved from on every turn
+	Summaries    map[string]string    `bson:"summaries,omitempty" json:"summaries,omitempty"`       // Rolling summaries, keyed by the hex ID of the last message each covers
+	CreatedAt    time.Time            `bson:"createdAt" json:"createdAt"`                           // Chat creation time
+	UpdatedAt    time.Time            `bson:"updatedAt" json:"updatedAt"`                           // Last update time
+}
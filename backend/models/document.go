@@ -0,0 +1,45 @@
+// models/document.go
+
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Collection groups uploaded documents that chats can retrieve from.
+type Collection struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name           string             `bson:"name" json:"name"`
+	Description    string             `bson:"description,omitempty" json:"description,omitempty"`
+	EmbeddingModel string             `bson:"embeddingModel" json:"embeddingModel"` // Model that embedded every chunk; queries must use it too
+	CreatedAt      time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt      time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
+
+// Document is an uploaded text file. Its content is kept so citations can be checked
+// against the source.
+type Document struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	CollectionID primitive.ObjectID `bson:"collectionId" json:"collectionId"`
+	Name         string             `bson:"name" json:"name"` // Original file name
+	ContentType  string             `bson:"contentType" json:"contentType"`
+	Size         int                `bson:"size" json:"size"` // Content length in bytes
+	Content      string             `bson:"content" json:"-"`
+	ChunkCount   int                `bson:"chunkCount" json:"chunkCount"`
+	CreatedAt    time.Time          `bson:"createdAt" json:"createdAt"`
+}
+
+// DocumentChunk is a piece of a document with its embedding. Start and End are byte
+// offsets into the document's content.
+type DocumentChunk struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	DocumentID   primitive.ObjectID `bson:"documentId" json:"documentId"`
+	CollectionID primitive.ObjectID `bson:"collectionId" json:"collectionId"`
+	Index        int                `bson:"index" json:"index"` // Position within the document
+	Start        int                `bson:"start" json:"start"`
+	End          int                `bson:"end" json:"end"`
+	Text         string             `bson:"text" json:"text"`
+	Embedding    []float64          `bson:"embedding" json:"-"`
+}
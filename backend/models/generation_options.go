@@ -0,0 +1,15 @@
+// models/generation_options.go
+
+package models
+
+// GenerationOptions holds the sampling parameters for a reply. Nil fields are unset
+// and fall back to the next level (request, then chat, then persona, then user) and finally to the
+// model's own defaults.
+type GenerationOptions struct {
+	Temperature *float64 `bson:"temperature,omitempty" json:"temperature,omitempty"`
+	TopP        *float64 `bson:"topP,omitempty" json:"topP,omitempty"`
+	NumCtx      *int     `bson:"numCtx,omitempty" json:"numCtx,omitempty"`       // Context window size in tokens
+	Seed        *int     `bson:"seed,omitempty" json:"seed,omitempty"`           // Fixed seed for reproducible runs
+	Stop        []string `bson:"stop,omitempty" json:"stop,omitempty"`           // Stop sequences
+	MaxTokens   *int     `bson:"maxTokens,omitempty" json:"maxTokens,omitempty"` // Maximum tokens to generate
+}
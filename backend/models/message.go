@@ -8,10 +8,74 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Statuses of an assistant message whose generation did not finish normally.
+const (
+	MessageStatusAborted = "aborted" // Stopped by the user or a disconnect; Content holds the partial reply
+	MessageStatusError   = "error"   // The model backend failed mid-reply
+	MessageStatusInvalid = "invalid" // The reply never matched the requested JSON schema
+)
+
+// Roles of the messages recording a tool the model called while answering.
+const (
+	MessageRoleToolCall   = "tool_call"   // ToolCall holds the tool and its arguments
+	MessageRoleToolResult = "tool_result" // Content holds the tool's output; Status is error if it failed
+)
+
 // Message represents an individual message in a chat.
 type Message struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	Role      string             `bson:"role" json:"role"`       // 'user' or 'assistant'
-	Content   string             `bson:"content" json:"content"` // Message text
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ParentID    primitive.ObjectID `bson:"parentId,omitempty" json:"parentId,omitempty"`       // Message this one follows; zero for the first message of a branch
+	Role        string             `bson:"role" json:"role"`                                   // 'user', 'assistant' or a MessageRoleTool*
+	Content     string             `bson:"content" json:"content"`                             // Message text
+	Reasoning   string             `bson:"reasoning,omitempty" json:"reasoning,omitempty"`     // Model's <think> output, kept out of Content
+	Model       string             `bson:"model,omitempty" json:"model,omitempty"`             // Model that produced an assistant message
+	Status      string             `bson:"status,omitempty" json:"status,omitempty"`           // Empty for complete replies, see MessageStatus*
+	Pinned      bool               `bson:"pinned,omitempty" json:"pinned,omitempty"`           // Never dropped by the pinned context strategy
+	Citations   []Citation         `bson:"citations,omitempty" json:"citations,omitempty"`     // Document chunks the reply was given
+	ToolCall    *ToolCall          `bson:"toolCall,omitempty" json:"toolCall,omitempty"`       // Set on tool_call and tool_result messages
+	Data        RawJSON            `bson:"data,omitempty" json:"data,omitempty"`               // Parsed reply when a JSON schema was requested
+	Attachments []Attachment       `bson:"attachments,omitempty" json:"attachments,omitempty"` // Files sent with a user message
+	Schema      RawJSON            `bson:"schema,omitempty" json:"schema,omitempty"`           // JSON schema requested for the reply to a user message
+	Timestamp   time.Time          `bson:"timestamp" json:"timestamp"`
+
+	// Regenerated assistant replies are kept as variants. Content, Reasoning, Model,
+	// Status, Citations, Data and Tools mirror Variants[ActiveVariant]; messages never
+	// regenerated have none.
+	Variants      []MessageVariant `bson:"variants,omitempty" json:"variants,omitempty"`
+	ActiveVariant int              `bson:"activeVariant,omitempty" json:"activeVariant"`
+	// Tools are the tool_call and tool_result messages of a regenerated reply, replayed
+	// before it. The tools of other replies are messages of the branch.
+	Tools []Message `bson:"tools,omitempty" json:"tools,omitempty"`
+}
+
+// MessageVariant is one of the alternative replies generated for the same user turn.
+type MessageVariant struct {
+	ID        primitive.ObjectID `bson:"_id" json:"id"`
+	Content   string             `bson:"content" json:"content"`
+	Reasoning string             `bson:"reasoning,omitempty" json:"reasoning,omitempty"`
+	Model     string             `bson:"model,omitempty" json:"model,omitempty"`
+	Status    string             `bson:"status,omitempty" json:"status,omitempty"`
+	Citations []Citation         `bson:"citations,omitempty" json:"citations,omitempty"`
+	Data      RawJSON            `bson:"data,omitempty" json:"data,omitempty"`
+	Tools     []Message          `bson:"tools,omitempty" json:"tools,omitempty"` // tool_call and tool_result messages, in order
 	Timestamp time.Time          `bson:"timestamp" json:"timestamp"`
-}
\ No newline at end of file
+}
+
+// Citation records a document chunk that was injected into the prompt of a reply.
+// Number is how the prompt referred to it, e.g. [1].
+type Citation struct {
+	Number       int                `bson:"number" json:"number"`
+	DocumentID   primitive.ObjectID `bson:"documentId" json:"documentId"`
+	DocumentName string             `bson:"documentName" json:"documentName"`
+	ChunkID      primitive.ObjectID `bson:"chunkId" json:"chunkId"`
+	Start        int                `bson:"start" json:"start"` // Byte offsets into the document's content
+	End          int                `bson:"end" json:"end"`
+	Score        float64            `bson:"score" json:"score"` // Similarity to the question
+}
+
+// ToolCall is a request from the model to run a tool.
+type ToolCall struct {
+	ID        string                 `bson:"id" json:"id"`
+	Name      string                 `bson:"name" json:"name"`
+	Arguments map[string]interface{} `bson:"arguments" json:"arguments"`
+}
@@ -0,0 +1,21 @@
+// models/message_embedding.go
+
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MessageEmbedding is the vector of a chat message, used for semantic search.
+type MessageEmbedding struct {
+	MessageID   primitive.ObjectID `bson:"_id" json:"messageId"`
+	ChatID      primitive.ObjectID `bson:"chatId" json:"chatId"`
+	Role        string             `bson:"role" json:"role"`
+	Snippet     string             `bson:"snippet" json:"snippet"`         // Start of the message, shown in results
+	ContentHash string             `bson:"contentHash" json:"contentHash"` // Detects edits, e.g. a newly selected variant
+	Model       string             `bson:"model" json:"model"`             // Embedding model
+	Embedding   []float64          `bson:"embedding" json:"-"`
+	Timestamp   time.Time          `bson:"timestamp" json:"timestamp"` // When the message was written
+}
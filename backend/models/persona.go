@@ -0,0 +1,31 @@
+// models/persona.go
+
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Persona is an assistant character: the system prompt and defaults applied to every
+// turn of the chats that use it.
+type Persona struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name         string             `bson:"name" json:"name"`                               // Name the assistant goes by
+	SystemPrompt string             `bson:"systemPrompt" json:"systemPrompt"`               // Sent as the system message on every turn
+	Greeting     string             `bson:"greeting,omitempty" json:"greeting,omitempty"`   // First message of a new chat; {{user}} is replaced with the user name
+	Model        string             `bson:"model,omitempty" json:"model,omitempty"`         // Default model for new chats
+	Options      *GenerationOptions `bson:"options,omitempty" json:"options,omitempty"`     // Default generation options, below the chat's
+	Examples     []FewShotExample   `bson:"examples,omitempty" json:"examples,omitempty"`   // Sample exchanges sent before the conversation
+	Schema       RawJSON            `bson:"schema,omitempty" json:"schema,omitempty"`       // JSON schema every reply must match
+	IsDefault    bool               `bson:"isDefault,omitempty" json:"isDefault,omitempty"` // Used by chats without a persona
+	CreatedAt    time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt    time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
+
+// FewShotExample is a sample exchange showing the model how the persona answers.
+type FewShotExample struct {
+	User      string `bson:"user" json:"user"`
+	Assistant string `bson:"assistant" json:"assistant"`
+}
@@ -0,0 +1,30 @@
+// models/prompt_template.go
+
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PromptTemplate is a reusable prompt with {{variable}} placeholders.
+type PromptTemplate struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name        string             `bson:"name" json:"name"`                                   // Unique name, used to match templates on import
+	Description string             `bson:"description,omitempty" json:"description,omitempty"` // What the template is for
+	Content     string             `bson:"content" json:"content"`                             // Prompt text with {{variable}} placeholders
+	Variables   []TemplateVariable `bson:"variables" json:"variables"`                         // Every placeholder used in Content
+	PersonaID   primitive.ObjectID `bson:"personaId,omitempty" json:"personaId,omitempty"`     // Persona for chats started from the template
+	Model       string             `bson:"model,omitempty" json:"model,omitempty"`             // Model for chats started from the template
+	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt   time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
+
+// TemplateVariable describes one placeholder of a PromptTemplate.
+type TemplateVariable struct {
+	Name        string `bson:"name" json:"name"`
+	Description string `bson:"description,omitempty" json:"description,omitempty"`
+	Required    bool   `bson:"required" json:"required"`                   // Rendering fails without a value
+	Default     string `bson:"default,omitempty" json:"default,omitempty"` // Used when an optional variable has no value
+}
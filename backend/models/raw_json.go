@@ -0,0 +1,47 @@
+// models/raw_json.go
+
+package models
+
+import (
+	"encoding/json"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// RawJSON is a JSON document kept verbatim. It is encoded as-is in API responses and
+// stored in MongoDB as a string, since JSON keys such as "$ref" are not valid field names.
+type RawJSON json.RawMessage
+
+func (j RawJSON) MarshalJSON() ([]byte, error) {
+	if len(j) == 0 {
+		return []byte("null"), nil
+	}
+	return j, nil
+}
+
+func (j *RawJSON) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*j = nil
+		return nil
+	}
+	*j = append((*j)[:0], data...)
+	return nil
+}
+
+func (j RawJSON) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	return bson.MarshalValue(string(j))
+}
+
+func (j *RawJSON) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	if t == bsontype.Null {
+		*j = nil
+		return nil
+	}
+	var s string
+	if err := bson.UnmarshalValue(t, data, &s); err != nil {
+		return err
+	}
+	*j = RawJSON(s)
+	return nil
+}
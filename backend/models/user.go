@@ -11,8 +11,9 @@ import (
 type User struct {
 	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	Username    string             `bson:"username" json:"username"`
-	AboutMe     string             `bson:"aboutMe" json:"aboutMe"`         // About Me text
-	Preferences string             `bson:"preferences" json:"preferences"` // User preferences
+	AboutMe     string             `bson:"aboutMe" json:"aboutMe"`                     // About Me text
+	Preferences string             `bson:"preferences" json:"preferences"`             // User preferences
+	Options     *GenerationOptions `bson:"options,omitempty" json:"options,omitempty"` // Default generation options for all chats
 	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
 	UpdatedAt   time.Time          `bson:"updatedAt" json:"updatedAt"`
 }
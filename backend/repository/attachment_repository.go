@@ -0,0 +1,80 @@
+// repository/attachment_repository.go
+
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/ashuthe1/localmind/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type AttachmentRepository struct {
+	collection *mongo.Collection
+}
+
+func NewAttachmentRepository(db *mongo.Database) *AttachmentRepository {
+	return &AttachmentRepository{
+		collection: db.Collection("attachments"),
+	}
+}
+
+// CreateAttachment inserts the metadata of a stored file.
+func (r *AttachmentRepository) CreateAttachment(attachment *models.Attachment) error {
+	if attachment.ID.IsZero() {
+		attachment.ID = primitive.NewObjectID()
+	}
+	attachment.CreatedAt = time.Now()
+
+	_, err := r.collection.InsertOne(context.Background(), attachment)
+	return err
+}
+
+// GetAttachmentByID retrieves the metadata of a stored file.
+func (r *AttachmentRepository) GetAttachmentByID(id primitive.ObjectID) (*models.Attachment, error) {
+	var attachment models.Attachment
+	err := r.collection.FindOne(context.Background(), bson.M{"_id": id}).Decode(&attachment)
+	if err != nil {
+		return nil, err
+	}
+	return &attachment, nil
+}
+
+// GetChatAttachments retrieves the metadata of all files attached in a chat.
+func (r *AttachmentRepository) GetChatAttachments(chatID primitive.ObjectID) ([]models.Attachment, error) {
+	cursor, err := r.collection.Find(context.Background(), bson.M{"chatId": chatID})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+
+	var attachments []models.Attachment
+	if err := cursor.All(context.Background(), &attachments); err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+// GetAllAttachments retrieves the metadata of every stored file.
+func (r *AttachmentRepository) GetAllAttachments() ([]models.Attachment, error) {
+	cursor, err := r.collection.Find(context.Background(), bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+
+	var attachments []models.Attachment
+	if err := cursor.All(context.Background(), &attachments); err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+// DeleteAttachment removes the metadata of a stored file.
+func (r *AttachmentRepository) DeleteAttachment(id primitive.ObjectID) error {
+	_, err := r.collection.DeleteOne(context.Background(), bson.M{"_id": id})
+	return err
+}
@@ -44,12 +44,42 @@ func (r *ChatRepository) GetChatByID(id primitive.ObjectID) (*models.Chat, error
 	return &chat, nil
 }
 
+// GetMessageChats maps every given message that still exists to the chat holding it.
+// Only the chats' IDs, titles and message IDs are loaded.
+func (r *ChatRepository) GetMessageChats(messageIDs []primitive.ObjectID) (map[primitive.ObjectID]*models.Chat, error) {
+	opts := options.Find().SetProjection(bson.M{"title": 1, "messages._id": 1})
+	cursor, err := r.collection.Find(context.Background(), bson.M{"messages._id": bson.M{"$in": messageIDs}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+
+	var chats []models.Chat
+	if err := cursor.All(context.Background(), &chats); err != nil {
+		return nil, err
+	}
+
+	wanted := make(map[primitive.ObjectID]bool, len(messageIDs))
+	for _, id := range messageIDs {
+		wanted[id] = true
+	}
+	found := make(map[primitive.ObjectID]*models.Chat, len(messageIDs))
+	for i := range chats {
+		for _, msg := range chats[i].Messages {
+			if wanted[msg.ID] {
+				found[msg.ID] = &chats[i]
+			}
+		}
+	}
+	return found, nil
+}
+
 // GetAllChats retrieves all chats from the database, sorted by recent updates.
 func (r *ChatRepository) GetAllChats() ([]models.Chat, error) {
 	var chats []models.Chat
 
 	// Define sorting: -1 for descending order (most recent first)
-	opts := options.Find().SetSort(bson.D{{"updatedAt", -1}})
+	opts := options.Find().SetSort(bson.D{{Key: "updatedAt", Value: -1}})
 
 	cursor, err := r.collection.Find(context.Background(), bson.M{}, opts)
 	if err != nil {
@@ -70,15 +100,196 @@ func (r *ChatRepository) GetAllChats() ([]models.Chat, error) {
 	return chats, nil
 }
 
-// UpdateChat updates an existing chat.
+// UpdateChat replaces the messages and active branch of a chat. Other fields, like
+// the title written by the background title job, are left alone.
 func (r *ChatRepository) UpdateChat(chat *models.Chat) error {
 	chat.UpdatedAt = time.Now()
 	filter := bson.M{"_id": chat.ID}
 	update := bson.M{
 		"$set": bson.M{
-			"title":     chat.Title,
-			"messages":  chat.Messages,
-			"updatedAt": chat.UpdatedAt,
+			"messages":     chat.Messages,
+			"activeLeafId": chat.ActiveLeafID,
+			"updatedAt":    chat.UpdatedAt,
+		},
+	}
+	_, err := r.collection.UpdateOne(context.Background(), filter, update)
+	return err
+}
+
+// AppendMessage adds a message to a chat and makes it the active leaf, without
+// rewriting the messages already stored.
+func (r *ChatRepository) AppendMessage(chatID primitive.ObjectID, message models.Message) error {
+	filter := bson.M{"_id": chatID}
+	update := bson.M{
+		"$push": bson.M{"messages": message},
+		"$set": bson.M{
+			"activeLeafId": message.ID,
+			"updatedAt":    time.Now(),
+		},
+	}
+	result, err := r.collection.UpdateOne(context.Background(), filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// AppendMessageAfterLeaf appends a message like AppendMessage, but only while leafID
+// is still the chat's active leaf; a zero leafID means the chat has no messages. It
+// reports false when the active leaf has changed, or the chat does not exist.
+func (r *ChatRepository) AppendMessageAfterLeaf(chatID primitive.ObjectID, leafID primitive.ObjectID, message models.Message) (bool, error) {
+	filter := bson.M{"_id": chatID, "activeLeafId": leafID}
+	if leafID.IsZero() {
+		filter["activeLeafId"] = nil // Matches a missing field
+	}
+	update := bson.M{
+		"$push": bson.M{"messages": message},
+		"$set": bson.M{
+			"activeLeafId": message.ID,
+			"updatedAt":    time.Now(),
+		},
+	}
+	result, err := r.collection.UpdateOne(context.Background(), filter, update)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// ReplaceMessage overwrites one stored message of a chat, matched by its ID.
+func (r *ChatRepository) ReplaceMessage(chatID primitive.ObjectID, message models.Message) error {
+	filter := bson.M{"_id": chatID, "messages._id": message.ID}
+	update := bson.M{
+		"$set": bson.M{
+			"messages.$": message,
+			"updatedAt":  time.Now(),
+		},
+	}
+	result, err := r.collection.UpdateOne(context.Background(), filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// UpdateChatPersona changes the persona applied to a chat.
+func (r *ChatRepository) UpdateChatPersona(id primitive.ObjectID, personaID primitive.ObjectID) error {
+	filter := bson.M{"_id": id}
+	update := bson.M{
+		"$set": bson.M{
+			"personaId": personaID,
+			"updatedAt": time.Now(),
+		},
+	}
+	result, err := r.collection.UpdateOne(context.Background(), filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// UpdateChatCollections replaces the document collections a chat retrieves from.
+func (r *ChatRepository) UpdateChatCollections(id primitive.ObjectID, collectionIDs []primitive.ObjectID) error {
+	filter := bson.M{"_id": id}
+	update := bson.M{
+		"$set": bson.M{
+			"collections": collectionIDs,
+			"updatedAt":   time.Now(),
+		},
+	}
+	result, err := r.collection.UpdateOne(context.Background(), filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// UpdateChatActiveLeaf switches the branch of a chat that is shown and continued.
+func (r *ChatRepository) UpdateChatActiveLeaf(id primitive.ObjectID, leafID primitive.ObjectID) error {
+	filter := bson.M{"_id": id}
+	update := bson.M{"$set": bson.M{"activeLeafId": leafID}}
+	result, err := r.collection.UpdateOne(context.Background(), filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// UpdateChatTitle renames a chat.
+func (r *ChatRepository) UpdateChatTitle(id primitive.ObjectID, title string) error {
+	filter := bson.M{"_id": id}
+	update := bson.M{"$set": bson.M{"title": title}}
+	result, err := r.collection.UpdateOne(context.Background(), filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// UpdateChatModel changes the model used for new replies in a chat.
+func (r *ChatRepository) UpdateChatModel(id primitive.ObjectID, model string) error {
+	filter := bson.M{"_id": id}
+	update := bson.M{
+		"$set": bson.M{
+			"model":     model,
+			"updatedAt": time.Now(),
+		},
+	}
+	result, err := r.collection.UpdateOne(context.Background(), filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// UpdateChatOptions replaces the default generation options of a chat.
+func (r *ChatRepository) UpdateChatOptions(id primitive.ObjectID, options *models.GenerationOptions) error {
+	filter := bson.M{"_id": id}
+	update := bson.M{
+		"$set": bson.M{
+			"options":   options,
+			"updatedAt": time.Now(),
+		},
+	}
+	result, err := r.collection.UpdateOne(context.Background(), filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// SaveChatSummary stores the rolling summary of a chat's messages up to and including
+// until, without touching its messages or its position in the recent chats list.
+// Summaries of other branches are kept.
+func (r *ChatRepository) SaveChatSummary(id primitive.ObjectID, until primitive.ObjectID, summary string) error {
+	filter := bson.M{"_id": id}
+	update := bson.M{
+		"$set": bson.M{
+			"summaries." + until.Hex(): summary,
 		},
 	}
 	_, err := r.collection.UpdateOne(context.Background(), filter, update)
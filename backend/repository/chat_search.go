@@ -0,0 +1,335 @@
+// repository/chat_search.go
+
+package repository
+
+import (
+	"context"
+	"html"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ashuthe1/localmind/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// maxSearchedChats bounds the chats whose messages are scanned for one search.
+	maxSearchedChats = 500
+	// snippetRadius is how many runes of context a snippet keeps around the first match.
+	snippetRadius = 80
+)
+
+// ChatSearchQuery is a keyword search over chat titles and messages. Zero fields do
+// not filter.
+type ChatSearchQuery struct {
+	Text     string
+	From     time.Time // Messages written at or after From
+	To       time.Time // Messages written before To
+	Role     string    // 'user' or 'assistant'
+	Model    string    // Model that wrote the message
+	Page     int       // 1-based
+	PageSize int
+}
+
+// hasMessageFilters reports whether the query filters on message fields, in which
+// case chats that only match by title are left out.
+func (q ChatSearchQuery) hasMessageFilters() bool {
+	return !q.From.IsZero() || !q.To.IsZero() || q.Role != "" || q.Model != ""
+}
+
+// ChatSearchHit is a message, or a chat title, that matched a keyword search.
+// Snippet is HTML-escaped with the matched terms wrapped in <mark> tags.
+type ChatSearchHit struct {
+	ChatID    primitive.ObjectID `json:"chatId"`
+	ChatTitle string             `json:"chatTitle"`
+	Field     string             `json:"field"` // "message" or "title"
+	MessageID primitive.ObjectID `json:"messageId,omitempty"`
+	Role      string             `json:"role,omitempty"`
+	Model     string             `json:"model,omitempty"`
+	Snippet   string             `json:"snippet"`
+	Timestamp time.Time          `json:"timestamp"`
+	Score     float64            `json:"score"` // Text score of the chat
+}
+
+// ChatSearchPage is one page of search hits. Only the maxSearchedChats best-scoring
+// chats are searched: Total counts their hits, and Truncated is set when more chats
+// matched, in which case refining the query finds the rest.
+type ChatSearchPage struct {
+	Hits      []ChatSearchHit `json:"hits"`
+	Total     int             `json:"total"`
+	Truncated bool            `json:"truncated"`
+	Page      int             `json:"page"`
+	PageSize  int             `json:"pageSize"`
+}
+
+// EnsureIndexes creates the text index used by SearchMessages.
+func (r *ChatRepository) EnsureIndexes() error {
+	index := mongo.IndexModel{
+		Keys: bson.D{{Key: "title", Value: "text"}, {Key: "messages.content", Value: "text"}},
+		Options: options.Index().
+			SetName("chat_text").
+			SetWeights(bson.D{{Key: "title", Value: 3}, {Key: "messages.content", Value: 1}}),
+	}
+	_, err := r.collection.Indexes().CreateOne(context.Background(), index)
+	return err
+}
+
+// SearchMessages finds the messages and chat titles matching a keyword query. Chats
+// are ranked by MongoDB's text score, and hits within a chat by recency.
+func (r *ChatRepository) SearchMessages(query ChatSearchQuery) (*ChatSearchPage, error) {
+	filter := bson.M{"$text": bson.M{"$search": query.Text}}
+	if query.hasMessageFilters() {
+		filter["messages"] = bson.M{"$elemMatch": messageFilter(query)}
+	}
+	opts := options.Find().
+		SetProjection(bson.M{"score": bson.M{"$meta": "textScore"}, "title": 1, "messages": 1, "updatedAt": 1}).
+		SetSort(bson.M{"score": bson.M{"$meta": "textScore"}}).
+		SetLimit(maxSearchedChats)
+
+	cursor, err := r.collection.Find(context.Background(), filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+
+	terms := searchTerms(query.Text)
+	hits := []ChatSearchHit{}
+	searched := 0
+	for cursor.Next(context.Background()) {
+		searched++
+		var chat struct {
+			models.Chat `bson:",inline"`
+			Score       float64 `bson:"score"`
+		}
+		if err := cursor.Decode(&chat); err != nil {
+			return nil, err
+		}
+		hits = append(hits, chatHits(&chat.Chat, chat.Score, terms, query)...)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].Timestamp.After(hits[j].Timestamp)
+	})
+
+	page := &ChatSearchPage{Total: len(hits), Page: query.Page, PageSize: query.PageSize, Hits: []ChatSearchHit{}}
+	if searched == maxSearchedChats {
+		matched, err := r.collection.CountDocuments(context.Background(), filter)
+		if err != nil {
+			return nil, err
+		}
+		page.Truncated = matched > maxSearchedChats
+	}
+	start := (query.Page - 1) * query.PageSize
+	if start < len(hits) {
+		end := start + query.PageSize
+		if end > len(hits) {
+			end = len(hits)
+		}
+		page.Hits = hits[start:end]
+	}
+	return page, nil
+}
+
+// messageFilter matches messages by the query's role, model and time range.
+func messageFilter(query ChatSearchQuery) bson.M {
+	filter := bson.M{}
+	if query.Role != "" {
+		filter["role"] = query.Role
+	}
+	if query.Model != "" {
+		filter["model"] = query.Model
+	}
+	timestamp := bson.M{}
+	if !query.From.IsZero() {
+		timestamp["$gte"] = query.From
+	}
+	if !query.To.IsZero() {
+		timestamp["$lt"] = query.To
+	}
+	if len(timestamp) > 0 {
+		filter["timestamp"] = timestamp
+	}
+	return filter
+}
+
+// chatHits returns the hits of one chat that matched the text index: every message
+// passing the filters that contains a search term, or else the title.
+func chatHits(chat *models.Chat, score float64, terms []string, query ChatSearchQuery) []ChatSearchHit {
+	var hits []ChatSearchHit
+	for _, msg := range chat.Messages {
+		if !matchesFilters(msg, query) {
+			continue
+		}
+		snippet, ok := highlight(msg.Content, terms)
+		if !ok {
+			continue
+		}
+		hits = append(hits, ChatSearchHit{
+			ChatID:    chat.ID,
+			ChatTitle: chat.Title,
+			Field:     "message",
+			MessageID: msg.ID,
+			Role:      msg.Role,
+			Model:     msg.Model,
+			Snippet:   snippet,
+			Timestamp: msg.Timestamp,
+			Score:     score,
+		})
+	}
+
+	if len(hits) == 0 && !query.hasMessageFilters() {
+		if snippet, ok := highlight(chat.Title, terms); ok {
+			hits = append(hits, ChatSearchHit{
+				ChatID:    chat.ID,
+				ChatTitle: chat.Title,
+				Field:     "title",
+				Snippet:   snippet,
+				Timestamp: chat.UpdatedAt,
+				Score:     score,
+			})
+		}
+	}
+	return hits
+}
+
+func matchesFilters(msg models.Message, query ChatSearchQuery) bool {
+	if query.Role != "" && msg.Role != query.Role {
+		return false
+	}
+	if query.Model != "" && msg.Model != query.Model {
+		return false
+	}
+	if !query.From.IsZero() && msg.Timestamp.Before(query.From) {
+		return false
+	}
+	if !query.To.IsZero() && !msg.Timestamp.Before(query.To) {
+		return false
+	}
+	return true
+}
+
+// searchTerms splits a MongoDB text query into the lower-case words and "quoted
+// phrases" to highlight. Negated terms are dropped. Trailing plural and verb endings
+// are removed, roughly like the text index's stemming, so "indexes" finds "index".
+func searchTerms(text string) []string {
+	var terms []string
+	for i, part := range strings.Split(text, `"`) {
+		if i%2 == 1 {
+			if phrase := strings.ToLower(strings.TrimSpace(part)); phrase != "" {
+				terms = append(terms, phrase)
+			}
+			continue
+		}
+		for _, word := range strings.FieldsFunc(part, func(r rune) bool { return unicode.IsSpace(r) }) {
+			if strings.HasPrefix(word, "-") {
+				continue
+			}
+			word = strings.ToLower(strings.TrimFunc(word, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsNumber(r) }))
+			if word = stem(word); word != "" {
+				terms = append(terms, word)
+			}
+		}
+	}
+	return terms
+}
+
+func stem(word string) string {
+	if utf8.RuneCountInString(word) <= 4 {
+		return word
+	}
+	for _, suffix := range []string{"ing", "es", "ed", "s"} {
+		if strings.HasSuffix(word, suffix) {
+			return strings.TrimSuffix(word, suffix)
+		}
+	}
+	return word
+}
+
+// highlight cuts a snippet around the first matched term and marks every match in
+// it. It reports false when no term occurs in text.
+func highlight(text string, terms []string) (string, bool) {
+	// Lower-case rune by rune and keep each rune's byte offset in text, since
+	// lower-casing can change the byte length (e.g. "İ" becomes "i")
+	var lower []rune
+	var offsets []int
+	for i, r := range text {
+		lower = append(lower, unicode.ToLower(r))
+		offsets = append(offsets, i)
+	}
+	offsets = append(offsets, len(text))
+
+	type span struct{ start, end int }
+	var spans []span
+	for _, term := range terms {
+		needle := []rune(term)
+		if len(needle) == 0 {
+			continue
+		}
+		for i := 0; i+len(needle) <= len(lower); {
+			if !runesEqual(lower[i:i+len(needle)], needle) {
+				i++
+				continue
+			}
+			spans = append(spans, span{offsets[i], offsets[i+len(needle)]})
+			i += len(needle)
+		}
+	}
+	if len(spans) == 0 {
+		return "", false
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+
+	// Window of snippetRadius runes on each side of the first match
+	from := spans[0].start
+	for n := 0; from > 0 && n < snippetRadius; n++ {
+		_, size := utf8.DecodeLastRuneInString(text[:from])
+		from -= size
+	}
+	to := spans[0].end
+	for n := 0; to < len(text) && n < snippetRadius; n++ {
+		_, size := utf8.DecodeRuneInString(text[to:])
+		to += size
+	}
+
+	var b strings.Builder
+	if from > 0 {
+		b.WriteString("…")
+	}
+	pos := from
+	for _, s := range spans {
+		if s.start < pos || s.end > to {
+			continue // Overlaps a previous match or falls outside the window
+		}
+		b.WriteString(html.EscapeString(text[pos:s.start]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(text[s.start:s.end]))
+		b.WriteString("</mark>")
+		pos = s.end
+	}
+	b.WriteString(html.EscapeString(text[pos:to]))
+	if to < len(text) {
+		b.WriteString("…")
+	}
+	return b.String(), true
+}
+
+func runesEqual(a, b []rune) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,87 @@
+// repository/chat_search_test.go
+
+package repository
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSearchTerms(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"words", "Mongo Index", []string{"mongo", "index"}},
+		{"stems plurals", "indexes Streams", []string{"index", "stream"}},
+		{"phrase kept whole", `how "Vector Searches" work`, []string{"how", "vector searches", "work"}},
+		{"negated dropped", "golang -java", []string{"golang"}},
+		{"punctuation trimmed", "(context), window!", []string{"context", "window"}},
+		{"only punctuation", "?? !!", nil},
+		{"empty phrase", `"  " go`, []string{"go"}},
+		{"non-ASCII", "İstanbul STRAẞE", []string{"istanbul", "straße"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := searchTerms(tt.query); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("searchTerms(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStem(t *testing.T) {
+	tests := []struct {
+		word string
+		want string
+	}{
+		{"runs", "runs"},
+		{"index", "index"},
+		{"streaming", "stream"},
+		{"indexes", "index"},
+		{"embedded", "embedd"},
+		{"models", "model"},
+		{"straße", "straße"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := stem(tt.word); got != tt.want {
+			t.Errorf("stem(%q) = %q, want %q", tt.word, got, tt.want)
+		}
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	long := strings.Repeat("a ", 60) + "needle" + strings.Repeat(" b", 60)
+	tests := []struct {
+		name   string
+		text   string
+		terms  []string
+		want   string
+		wantOK bool
+	}{
+		{"no match", "hello world", []string{"mongo"}, "", false},
+		{"case-insensitive", "Use MongoDB here", []string{"mongodb"}, "Use <mark>MongoDB</mark> here", true},
+		{"every match marked", "go and Go", []string{"go"}, "<mark>go</mark> and <mark>Go</mark>", true},
+		{"overlapping terms", "streaming", []string{"stream", "streaming"}, "<mark>stream</mark>ing", true},
+		{"html escaped", "<b>tag</b>", []string{"tag"}, "&lt;b&gt;<mark>tag</mark>&lt;/b&gt;", true},
+		{"dotted capital I", "Visit İstanbul soon", []string{"istanbul"}, "Visit <mark>İstanbul</mark> soon", true},
+		{"capital sharp s", "Die STRAẞE ist lang", []string{"straße"}, "Die <mark>STRAẞE</mark> ist lang", true},
+		{"after length change", "İ then Mongo", []string{"mongo"}, "İ then <mark>Mongo</mark>", true},
+		{"empty term", "text", []string{""}, "", false},
+		{
+			"window", long, []string{"needle"},
+			"…" + strings.Repeat("a ", 40) + "<mark>needle</mark>" + strings.Repeat(" b", 40) + "…", true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := highlight(tt.text, tt.terms)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("highlight(%q, %q) = %q, %v, want %q, %v", tt.text, tt.terms, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
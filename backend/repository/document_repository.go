@@ -0,0 +1,211 @@
+// repository/document_repository.go
+
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/ashuthe1/localmind/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DocumentRepository stores document collections, their documents and the embedded chunks.
+type DocumentRepository struct {
+	collections *mongo.Collection
+	documents   *mongo.Collection
+	chunks      *mongo.Collection
+}
+
+func NewDocumentRepository(db *mongo.Database) *DocumentRepository {
+	return &DocumentRepository{
+		collections: db.Collection("collections"),
+		documents:   db.Collection("documents"),
+		chunks:      db.Collection("document_chunks"),
+	}
+}
+
+// CreateCollection inserts a new document collection.
+func (r *DocumentRepository) CreateCollection(collection *models.Collection) error {
+	collection.ID = primitive.NewObjectID()
+	collection.CreatedAt = time.Now()
+	collection.UpdatedAt = time.Now()
+
+	_, err := r.collections.InsertOne(context.Background(), collection)
+	return err
+}
+
+// GetCollectionByID retrieves a document collection by its ID.
+func (r *DocumentRepository) GetCollectionByID(id primitive.ObjectID) (*models.Collection, error) {
+	var collection models.Collection
+	err := r.collections.FindOne(context.Background(), bson.M{"_id": id}).Decode(&collection)
+	if err != nil {
+		return nil, err
+	}
+	return &collection, nil
+}
+
+// GetAllCollections retrieves all document collections, sorted by name.
+func (r *DocumentRepository) GetAllCollections() ([]models.Collection, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}})
+
+	cursor, err := r.collections.Find(context.Background(), bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+
+	collections := []models.Collection{}
+	if err := cursor.All(context.Background(), &collections); err != nil {
+		return nil, err
+	}
+	return collections, nil
+}
+
+// TouchCollection bumps the update time of a collection after its documents changed.
+func (r *DocumentRepository) TouchCollection(id primitive.ObjectID) error {
+	_, err := r.collections.UpdateOne(context.Background(), bson.M{"_id": id}, bson.M{"$set": bson.M{"updatedAt": time.Now()}})
+	return err
+}
+
+// DeleteCollection deletes a document collection with all its documents and chunks.
+func (r *DocumentRepository) DeleteCollection(id primitive.ObjectID) error {
+	result, err := r.collections.DeleteOne(context.Background(), bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	if _, err := r.chunks.DeleteMany(context.Background(), bson.M{"collectionId": id}); err != nil {
+		return err
+	}
+	_, err = r.documents.DeleteMany(context.Background(), bson.M{"collectionId": id})
+	return err
+}
+
+// CreateDocument inserts a document together with its chunks.
+func (r *DocumentRepository) CreateDocument(document *models.Document, chunks []models.DocumentChunk) error {
+	document.ID = primitive.NewObjectID()
+	document.CreatedAt = time.Now()
+	document.ChunkCount = len(chunks)
+
+	if len(chunks) > 0 {
+		docs := make([]interface{}, len(chunks))
+		for i := range chunks {
+			chunks[i].ID = primitive.NewObjectID()
+			chunks[i].DocumentID = document.ID
+			chunks[i].CollectionID = document.CollectionID
+			docs[i] = chunks[i]
+		}
+		if _, err := r.chunks.InsertMany(context.Background(), docs); err != nil {
+			return err
+		}
+	}
+
+	_, err := r.documents.InsertOne(context.Background(), document)
+	return err
+}
+
+// GetDocumentByID retrieves a document, including its content, by its ID.
+func (r *DocumentRepository) GetDocumentByID(id primitive.ObjectID) (*models.Document, error) {
+	var document models.Document
+	err := r.documents.FindOne(context.Background(), bson.M{"_id": id}).Decode(&document)
+	if err != nil {
+		return nil, err
+	}
+	return &document, nil
+}
+
+// GetDocumentsByCollection lists the documents of a collection without their content.
+func (r *DocumentRepository) GetDocumentsByCollection(collectionID primitive.ObjectID) ([]models.Document, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: -1}}).
+		SetProjection(bson.M{"content": 0})
+
+	cursor, err := r.documents.Find(context.Background(), bson.M{"collectionId": collectionID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+
+	documents := []models.Document{}
+	if err := cursor.All(context.Background(), &documents); err != nil {
+		return nil, err
+	}
+	return documents, nil
+}
+
+// DeleteDocument deletes a document and its chunks.
+func (r *DocumentRepository) DeleteDocument(id primitive.ObjectID) error {
+	result, err := r.documents.DeleteOne(context.Background(), bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	_, err = r.chunks.DeleteMany(context.Background(), bson.M{"documentId": id})
+	return err
+}
+
+// EachChunkEmbedding calls fn with the ID and embedding of every chunk of a
+// collection, without loading the chunk texts.
+func (r *DocumentRepository) EachChunkEmbedding(ctx context.Context, collectionID primitive.ObjectID, fn func(id primitive.ObjectID, embedding []float64)) error {
+	opts := options.Find().SetProjection(bson.M{"_id": 1, "embedding": 1})
+	cursor, err := r.chunks.Find(ctx, bson.M{"collectionId": collectionID}, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var chunk models.DocumentChunk
+		if err := cursor.Decode(&chunk); err != nil {
+			return err
+		}
+		fn(chunk.ID, chunk.Embedding)
+	}
+	return cursor.Err()
+}
+
+// GetChunksByIDs retrieves chunks, without their embeddings, by ID.
+func (r *DocumentRepository) GetChunksByIDs(ids []primitive.ObjectID) ([]models.DocumentChunk, error) {
+	opts := options.Find().SetProjection(bson.M{"embedding": 0})
+	cursor, err := r.chunks.Find(context.Background(), bson.M{"_id": bson.M{"$in": ids}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+
+	chunks := []models.DocumentChunk{}
+	if err := cursor.All(context.Background(), &chunks); err != nil {
+		return nil, err
+	}
+	return chunks, nil
+}
+
+// GetDocumentNames returns the names of the given documents by ID.
+func (r *DocumentRepository) GetDocumentNames(ids []primitive.ObjectID) (map[primitive.ObjectID]string, error) {
+	opts := options.Find().SetProjection(bson.M{"name": 1})
+	cursor, err := r.documents.Find(context.Background(), bson.M{"_id": bson.M{"$in": ids}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+
+	names := make(map[primitive.ObjectID]string)
+	for cursor.Next(context.Background()) {
+		var document models.Document
+		if err := cursor.Decode(&document); err != nil {
+			return nil, err
+		}
+		names[document.ID] = document.Name
+	}
+	return names, cursor.Err()
+}
@@ -0,0 +1,106 @@
+// repository/embedding_repository.go
+
+package repository
+
+import (
+	"context"
+
+	"github.com/ashuthe1/localmind/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// EmbeddingRepository stores the embeddings of chat messages.
+type EmbeddingRepository struct {
+	collection *mongo.Collection
+}
+
+func NewEmbeddingRepository(db *mongo.Database) *EmbeddingRepository {
+	return &EmbeddingRepository{
+		collection: db.Collection("message_embeddings"),
+	}
+}
+
+// UpsertMessageEmbedding inserts or replaces the embedding of a message.
+func (r *EmbeddingRepository) UpsertMessageEmbedding(embedding *models.MessageEmbedding) error {
+	filter := bson.M{"_id": embedding.MessageID}
+	_, err := r.collection.ReplaceOne(context.Background(), filter, embedding, options.Replace().SetUpsert(true))
+	return err
+}
+
+// GetChatEmbeddingKeys returns the embedding model and content hash of every embedded
+// message of a chat, by message ID.
+func (r *EmbeddingRepository) GetChatEmbeddingKeys(chatID primitive.ObjectID) (map[primitive.ObjectID]models.MessageEmbedding, error) {
+	opts := options.Find().SetProjection(bson.M{"model": 1, "contentHash": 1})
+	cursor, err := r.collection.Find(context.Background(), bson.M{"chatId": chatID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+
+	keys := make(map[primitive.ObjectID]models.MessageEmbedding)
+	for cursor.Next(context.Background()) {
+		var embedding models.MessageEmbedding
+		if err := cursor.Decode(&embedding); err != nil {
+			return nil, err
+		}
+		keys[embedding.MessageID] = embedding
+	}
+	return keys, cursor.Err()
+}
+
+// EachEmbedding calls fn with the message ID and vector of every message embedding
+// made with the given model, without loading the rest of the embeddings.
+func (r *EmbeddingRepository) EachEmbedding(ctx context.Context, model string, fn func(messageID primitive.ObjectID, embedding []float64)) error {
+	opts := options.Find().SetProjection(bson.M{"_id": 1, "embedding": 1})
+	cursor, err := r.collection.Find(ctx, bson.M{"model": model}, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var embedding models.MessageEmbedding
+		if err := cursor.Decode(&embedding); err != nil {
+			return err
+		}
+		fn(embedding.MessageID, embedding.Embedding)
+	}
+	return cursor.Err()
+}
+
+// GetEmbeddingsByIDs retrieves message embeddings, without their vectors, by message ID.
+func (r *EmbeddingRepository) GetEmbeddingsByIDs(messageIDs []primitive.ObjectID) ([]models.MessageEmbedding, error) {
+	opts := options.Find().SetProjection(bson.M{"embedding": 0})
+	cursor, err := r.collection.Find(context.Background(), bson.M{"_id": bson.M{"$in": messageIDs}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+
+	embeddings := []models.MessageEmbedding{}
+	if err := cursor.All(context.Background(), &embeddings); err != nil {
+		return nil, err
+	}
+	return embeddings, nil
+}
+
+// DeleteMessageEmbeddings deletes the embeddings of the given messages.
+func (r *EmbeddingRepository) DeleteMessageEmbeddings(messageIDs []primitive.ObjectID) error {
+	_, err := r.collection.DeleteMany(context.Background(), bson.M{"_id": bson.M{"$in": messageIDs}})
+	return err
+}
+
+// DeleteChatEmbeddings deletes the embeddings of a chat's messages.
+func (r *EmbeddingRepository) DeleteChatEmbeddings(chatID primitive.ObjectID) error {
+	_, err := r.collection.DeleteMany(context.Background(), bson.M{"chatId": chatID})
+	return err
+}
+
+// DeleteAllEmbeddings deletes every message embedding.
+func (r *EmbeddingRepository) DeleteAllEmbeddings() error {
+	_, err := r.collection.DeleteMany(context.Background(), bson.M{})
+	return err
+}
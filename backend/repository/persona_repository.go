@@ -0,0 +1,116 @@
+// repository/persona_repository.go
+
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/ashuthe1/localmind/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type PersonaRepository struct {
+	collection *mongo.Collection
+}
+
+func NewPersonaRepository(db *mongo.Database) *PersonaRepository {
+	return &PersonaRepository{
+		collection: db.Collection("personas"),
+	}
+}
+
+// CreatePersona inserts a new persona into the database.
+func (r *PersonaRepository) CreatePersona(persona *models.Persona) error {
+	persona.ID = primitive.NewObjectID()
+	persona.CreatedAt = time.Now()
+	persona.UpdatedAt = time.Now()
+
+	_, err := r.collection.InsertOne(context.Background(), persona)
+	return err
+}
+
+// GetPersonaByID retrieves a persona by its ID.
+func (r *PersonaRepository) GetPersonaByID(id primitive.ObjectID) (*models.Persona, error) {
+	var persona models.Persona
+	err := r.collection.FindOne(context.Background(), bson.M{"_id": id}).Decode(&persona)
+	if err != nil {
+		return nil, err
+	}
+	return &persona, nil
+}
+
+// GetDefaultPersona retrieves the persona used by chats without one.
+func (r *PersonaRepository) GetDefaultPersona() (*models.Persona, error) {
+	var persona models.Persona
+	err := r.collection.FindOne(context.Background(), bson.M{"isDefault": true}).Decode(&persona)
+	if err != nil {
+		return nil, err
+	}
+	return &persona, nil
+}
+
+// GetAllPersonas retrieves all personas, sorted by name.
+func (r *PersonaRepository) GetAllPersonas() ([]models.Persona, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}})
+
+	cursor, err := r.collection.Find(context.Background(), bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+
+	personas := []models.Persona{}
+	if err := cursor.All(context.Background(), &personas); err != nil {
+		return nil, err
+	}
+	return personas, nil
+}
+
+// UpdatePersona replaces the editable fields of a persona.
+func (r *PersonaRepository) UpdatePersona(persona *models.Persona) error {
+	persona.UpdatedAt = time.Now()
+	filter := bson.M{"_id": persona.ID}
+	update := bson.M{
+		"$set": bson.M{
+			"name":         persona.Name,
+			"systemPrompt": persona.SystemPrompt,
+			"greeting":     persona.Greeting,
+			"model":        persona.Model,
+			"options":      persona.Options,
+			"examples":     persona.Examples,
+			"schema":       persona.Schema,
+			"isDefault":    persona.IsDefault,
+			"updatedAt":    persona.UpdatedAt,
+		},
+	}
+	result, err := r.collection.UpdateOne(context.Background(), filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// ClearDefaultPersona unmarks the current default persona, if any.
+func (r *PersonaRepository) ClearDefaultPersona() error {
+	_, err := r.collection.UpdateMany(context.Background(), bson.M{"isDefault": true}, bson.M{"$set": bson.M{"isDefault": false}})
+	return err
+}
+
+// DeletePersona deletes a persona by its ID.
+func (r *PersonaRepository) DeletePersona(id primitive.ObjectID) error {
+	result, err := r.collection.DeleteOne(context.Background(), bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+// repository/template_repository.go
+
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/ashuthe1/localmind/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type TemplateRepository struct {
+	collection *mongo.Collection
+}
+
+func NewTemplateRepository(db *mongo.Database) *TemplateRepository {
+	return &TemplateRepository{
+		collection: db.Collection("templates"),
+	}
+}
+
+// CreateTemplate inserts a new prompt template into the database.
+func (r *TemplateRepository) CreateTemplate(template *models.PromptTemplate) error {
+	template.ID = primitive.NewObjectID()
+	template.CreatedAt = time.Now()
+	template.UpdatedAt = time.Now()
+
+	_, err := r.collection.InsertOne(context.Background(), template)
+	return err
+}
+
+// GetTemplateByID retrieves a prompt template by its ID.
+func (r *TemplateRepository) GetTemplateByID(id primitive.ObjectID) (*models.PromptTemplate, error) {
+	var template models.PromptTemplate
+	err := r.collection.FindOne(context.Background(), bson.M{"_id": id}).Decode(&template)
+	if err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+// GetTemplateByName retrieves a prompt template by its name.
+func (r *TemplateRepository) GetTemplateByName(name string) (*models.PromptTemplate, error) {
+	var template models.PromptTemplate
+	err := r.collection.FindOne(context.Background(), bson.M{"name": name}).Decode(&template)
+	if err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+// GetAllTemplates retrieves all prompt templates, sorted by name.
+func (r *TemplateRepository) GetAllTemplates() ([]models.PromptTemplate, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}})
+
+	cursor, err := r.collection.Find(context.Background(), bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+
+	templates := []models.PromptTemplate{}
+	if err := cursor.All(context.Background(), &templates); err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+// UpdateTemplate replaces the editable fields of a prompt template.
+func (r *TemplateRepository) UpdateTemplate(template *models.PromptTemplate) error {
+	template.UpdatedAt = time.Now()
+	filter := bson.M{"_id": template.ID}
+	update := bson.M{
+		"$set": bson.M{
+			"name":        template.Name,
+			"description": template.Description,
+			"content":     template.Content,
+			"variables":   template.Variables,
+			"personaId":   template.PersonaID,
+			"model":       template.Model,
+			"updatedAt":   template.UpdatedAt,
+		},
+	}
+	result, err := r.collection.UpdateOne(context.Background(), filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// DeleteTemplate deletes a prompt template by its ID.
+func (r *TemplateRepository) DeleteTemplate(id primitive.ObjectID) error {
+	result, err := r.collection.DeleteOne(context.Background(), bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
@@ -45,7 +45,7 @@ func (r *UserRepository) GetUserByUsername(username string) (*models.User, error
 }
 
 // UpdateUser updates the user's settings.
-// In this example, we update only the AboutMe and Preferences fields.
+// Only the AboutMe, Preferences and Options fields are updated.
 func (r *UserRepository) UpdateUser(user *models.User) error {
 	user.UpdatedAt = time.Now()
 	filter := bson.M{"username": config.UserName}
@@ -53,6 +53,7 @@ func (r *UserRepository) UpdateUser(user *models.User) error {
 		"$set": bson.M{
 			"aboutMe":     user.AboutMe,
 			"preferences": user.Preferences,
+			"options":     user.Options,
 			"updatedAt":   user.UpdatedAt,
 		},
 	}
@@ -69,7 +70,9 @@ func (r *UserRepository) CreateUser(user *models.User) error {
 	return err
 }
 
-func (r *UserRepository) GenerateUserAwarePrompt(originalPrompt string) string {
+// GenerateUserContext returns a system prompt describing the current user, or an
+// empty string when the user has not filled in any settings.
+func (r *UserRepository) GenerateUserContext() string {
 	var user models.User
 	username := config.UserName
 
@@ -83,10 +86,8 @@ func (r *UserRepository) GenerateUserAwarePrompt(originalPrompt string) string {
 		userInfo = append(userInfo, fmt.Sprintf("Preference: %s", user.Preferences))
 	}
 
-	userInfoText := ""
-	if len(userInfo) > 0 {
-		userInfoText = fmt.Sprintf("User info: %s. If required, use this knowledge before answering the question.", strings.Join(userInfo, ", "))
+	if len(userInfo) == 0 {
+		return ""
 	}
-
-	return fmt.Sprintf("%s\n\n%s", userInfoText, originalPrompt)
+	return fmt.Sprintf("User info: %s. If required, use this knowledge before answering the question.", strings.Join(userInfo, ", "))
 }
@@ -0,0 +1,255 @@
+// services/attachment_service.go
+
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/ashuthe1/localmind/logger"
+	"github.com/ashuthe1/localmind/models"
+	"github.com/ashuthe1/localmind/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// MaxImagesPerMessage bounds the images attached to one user message.
+	MaxImagesPerMessage = 4
+	// MaxFilesPerMessage bounds the text files attached to one user message.
+	MaxFilesPerMessage = 5
+)
+
+var (
+	// ErrUnsupportedImage is returned for files that are not PNG, JPEG, GIF or WebP images.
+	ErrUnsupportedImage = errors.New("only PNG, JPEG, GIF and WebP images are supported")
+	// ErrImageTooLarge is returned for images above the configured size.
+	ErrImageTooLarge = errors.New("image is too large")
+	// ErrFileTooLarge is returned for text files above the configured size.
+	ErrFileTooLarge = errors.New("file is too large")
+)
+
+// imageExtensions are the image types vision models accept, with the extension
+// the file is stored under.
+var imageExtensions = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// AttachmentService stores the files attached to user messages on local disk.
+type AttachmentService struct {
+	AttachmentRepo *repository.AttachmentRepository
+	Dir            string
+	MaxImageSize   int64
+	MaxFileSize    int64
+}
+
+func NewAttachmentService(attachmentRepo *repository.AttachmentRepository, dir string, maxImageSize int64, maxFileSize int64) *AttachmentService {
+	return &AttachmentService{
+		AttachmentRepo: attachmentRepo,
+		Dir:            dir,
+		MaxImageSize:   maxImageSize,
+		MaxFileSize:    maxFileSize,
+	}
+}
+
+// CheckImage returns the content type of an image, or an error if it cannot be attached.
+func (s *AttachmentService) CheckImage(data []byte) (string, error) {
+	if int64(len(data)) > s.MaxImageSize {
+		return "", fmt.Errorf("%w: larger than %d bytes", ErrImageTooLarge, s.MaxImageSize)
+	}
+	contentType := http.DetectContentType(data)
+	if _, ok := imageExtensions[contentType]; !ok {
+		return "", ErrUnsupportedImage
+	}
+	return contentType, nil
+}
+
+// SaveImage writes an image attached in a chat to disk and records it.
+func (s *AttachmentService) SaveImage(chatID primitive.ObjectID, name string, data []byte) (*models.Attachment, error) {
+	contentType, err := s.CheckImage(data)
+	if err != nil {
+		return nil, err
+	}
+
+	attachment := &models.Attachment{
+		ID:          primitive.NewObjectID(),
+		ChatID:      chatID,
+		Kind:        models.AttachmentKindImage,
+		Name:        filepath.Base(name),
+		ContentType: contentType,
+		Size:        int64(len(data)),
+	}
+	if attachment.Name == "." || attachment.Name == string(filepath.Separator) {
+		attachment.Name = "image"
+	}
+	if filepath.Ext(attachment.Name) == "" {
+		attachment.Name += imageExtensions[contentType]
+	}
+	if err := s.save(attachment, data); err != nil {
+		return nil, err
+	}
+	return attachment, nil
+}
+
+// CheckFile returns the content type of a text file, or an error if it cannot be attached.
+func (s *AttachmentService) CheckFile(name string, data []byte) (string, error) {
+	if int64(len(data)) > s.MaxFileSize {
+		return "", fmt.Errorf("%w: larger than %d bytes", ErrFileTooLarge, s.MaxFileSize)
+	}
+	if len(data) == 0 {
+		return "", fmt.Errorf("%w: %s is empty", ErrUnsupportedDocument, name)
+	}
+	return DocumentContentType(name, data)
+}
+
+// SaveFile writes a text file attached in a chat to disk and records it.
+func (s *AttachmentService) SaveFile(chatID primitive.ObjectID, name string, data []byte) (*models.Attachment, error) {
+	contentType, err := s.CheckFile(name, data)
+	if err != nil {
+		return nil, err
+	}
+
+	attachment := &models.Attachment{
+		ID:          primitive.NewObjectID(),
+		ChatID:      chatID,
+		Kind:        models.AttachmentKindFile,
+		Name:        filepath.Base(name),
+		ContentType: contentType,
+		Size:        int64(len(data)),
+	}
+	if err := s.save(attachment, data); err != nil {
+		return nil, err
+	}
+	return attachment, nil
+}
+
+// save writes the file and records its metadata.
+func (s *AttachmentService) save(attachment *models.Attachment, data []byte) error {
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(s.path(attachment), data, 0o644); err != nil {
+		return err
+	}
+	if err := s.AttachmentRepo.CreateAttachment(attachment); err != nil {
+		os.Remove(s.path(attachment))
+		return err
+	}
+	return nil
+}
+
+// GetAttachment returns the metadata of a stored file.
+func (s *AttachmentService) GetAttachment(id primitive.ObjectID) (*models.Attachment, error) {
+	return s.AttachmentRepo.GetAttachmentByID(id)
+}
+
+// Open opens a stored file for reading. The caller must close it.
+func (s *AttachmentService) Open(attachment *models.Attachment) (*os.File, error) {
+	return os.Open(s.path(attachment))
+}
+
+// LoadAttachments reads the files referenced by the messages so they can be sent to
+// the model: images are loaded for vision models and text files are put in front of
+// the message text. Files that cannot be read are dropped.
+func (s *AttachmentService) LoadAttachments(messages []ChatMessage) {
+	for i := range messages {
+		msg := &messages[i]
+
+		images := msg.Images[:0]
+		for _, image := range msg.Images {
+			data, err := os.ReadFile(s.path(&models.Attachment{ID: image.ID, Kind: models.AttachmentKindImage, ContentType: image.ContentType}))
+			if err != nil {
+				logger.Log.Warnf("Skipping image %s: %v", image.ID.Hex(), err)
+				continue
+			}
+			image.Data = data
+			images = append(images, image)
+		}
+		msg.Images = images
+
+		if len(msg.Files) == 0 {
+			continue
+		}
+		var blocks []string
+		for _, file := range msg.Files {
+			data, err := os.ReadFile(s.path(&models.Attachment{ID: file.ID, Kind: models.AttachmentKindFile}))
+			if err != nil {
+				logger.Log.Warnf("Skipping file %s: %v", file.ID.Hex(), err)
+				continue
+			}
+			blocks = append(blocks, FormatAttachedFile(file.Name, string(data)))
+		}
+		if msg.Content != "" {
+			blocks = append(blocks, msg.Content)
+		}
+		msg.Content = strings.Join(blocks, "\n\n")
+		msg.Files = nil
+	}
+}
+
+// fileCloseTag matches closing file tags inside attached content, in any case.
+var fileCloseTag = regexp.MustCompile(`(?i)</(file\b)`)
+
+// FormatAttachedFile wraps the content of an attached file in a delimited block.
+// Closing tags in the content are escaped as "<\/file", so only the block's own
+// tag ends it.
+func FormatAttachedFile(name string, content string) string {
+	content = fileCloseTag.ReplaceAllString(strings.TrimRight(content, "\n"), `<\/$1`)
+	return fmt.Sprintf("<file name=%q>\n%s\n</file>", name, content)
+}
+
+// DeleteChatAttachments removes the files attached in a chat.
+func (s *AttachmentService) DeleteChatAttachments(chatID primitive.ObjectID) error {
+	attachments, err := s.AttachmentRepo.GetChatAttachments(chatID)
+	if err != nil {
+		return err
+	}
+	return s.delete(attachments)
+}
+
+// DeleteAllAttachments removes every stored file.
+func (s *AttachmentService) DeleteAllAttachments() error {
+	attachments, err := s.AttachmentRepo.GetAllAttachments()
+	if err != nil {
+		return err
+	}
+	return s.delete(attachments)
+}
+
+func (s *AttachmentService) delete(attachments []models.Attachment) error {
+	for i := range attachments {
+		if err := os.Remove(s.path(&attachments[i])); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		if err := s.AttachmentRepo.DeleteAttachment(attachments[i].ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// path is where a file is stored: its ID with an extension for its type.
+func (s *AttachmentService) path(attachment *models.Attachment) string {
+	ext := ".txt"
+	if attachment.Kind == models.AttachmentKindImage {
+		ext = imageExtensions[attachment.ContentType]
+	}
+	return filepath.Join(s.Dir, attachment.ID.Hex()+ext)
+}
+
+// HasImages reports whether any message carries images.
+func HasImages(messages []ChatMessage) bool {
+	for _, msg := range messages {
+		if len(msg.Images) > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,410 @@
+// services/builtin_tools.go
+
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// NewBuiltinToolRegistry returns a registry with the tools that run entirely locally.
+func NewBuiltinToolRegistry() *ToolRegistry {
+	registry := NewToolRegistry()
+	registry.Register(calculatorTool)
+	registry.Register(currentTimeTool)
+	registry.Register(convertUnitsTool)
+	return registry
+}
+
+var calculatorTool = Tool{
+	Name:        "calculator",
+	Description: "Evaluates an arithmetic expression. Supports + - * / % ^, parentheses, the constants pi and e and the functions sqrt, abs, round, floor, ceil, ln, log, sin, cos and tan (radians).",
+	Parameters: map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"expression": map[string]interface{}{
+				"type":        "string",
+				"description": "The expression to evaluate, e.g. (2 + 3) * sqrt(16)",
+			},
+		},
+		"required": []string{"expression"},
+	},
+	Execute: func(ctx context.Context, args map[string]interface{}) (string, error) {
+		expression, err := stringArg(args, "expression")
+		if err != nil {
+			return "", err
+		}
+		value, err := EvaluateExpression(expression)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatFloat(value, 'g', 15, 64), nil
+	},
+}
+
+var currentTimeTool = Tool{
+	Name:        "current_time",
+	Description: "Returns the current date, time and weekday, in the server's time zone or the given IANA time zone.",
+	Parameters: map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"timezone": map[string]interface{}{
+				"type":        "string",
+				"description": "IANA time zone, e.g. Europe/Berlin. Optional.",
+			},
+		},
+	},
+	Execute: func(ctx context.Context, args map[string]interface{}) (string, error) {
+		now := time.Now()
+		if name, _ := args["timezone"].(string); name != "" {
+			location, err := time.LoadLocation(name)
+			if err != nil {
+				return "", fmt.Errorf("unknown time zone %q", name)
+			}
+			now = now.In(location)
+		}
+		return now.Format("Monday, 2 January 2006 15:04:05 MST (-07:00)"), nil
+	},
+}
+
+var convertUnitsTool = Tool{
+	Name:        "convert_units",
+	Description: "Converts a value between units of length (mm, cm, m, km, in, ft, yd, mi), mass (mg, g, kg, t, oz, lb), volume (ml, l, tsp, tbsp, cup, floz, gal), temperature (c, f, k), speed (m/s, km/h, mph, kn) and data size (b, kb, mb, gb, tb, kib, mib, gib, tib).",
+	Parameters: map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"value": map[string]interface{}{"type": "number"},
+			"from":  map[string]interface{}{"type": "string", "description": "Unit to convert from"},
+			"to":    map[string]interface{}{"type": "string", "description": "Unit to convert to"},
+		},
+		"required": []string{"value", "from", "to"},
+	},
+	Execute: func(ctx context.Context, args map[string]interface{}) (string, error) {
+		value, err := numberArg(args, "value")
+		if err != nil {
+			return "", err
+		}
+		from, err := stringArg(args, "from")
+		if err != nil {
+			return "", err
+		}
+		to, err := stringArg(args, "to")
+		if err != nil {
+			return "", err
+		}
+		result, err := ConvertUnits(value, from, to)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s %s = %s %s", strconv.FormatFloat(value, 'g', 15, 64), from, strconv.FormatFloat(result, 'g', 10, 64), to), nil
+	},
+}
+
+// unit is a unit of measure as a factor to the base unit of its dimension.
+type unit struct {
+	dimension string
+	factor    float64
+}
+
+var units = map[string]unit{
+	"mm": {"length", 0.001}, "cm": {"length", 0.01}, "m": {"length", 1}, "km": {"length", 1000},
+	"in": {"length", 0.0254}, "ft": {"length", 0.3048}, "yd": {"length", 0.9144}, "mi": {"length", 1609.344},
+
+	"mg": {"mass", 1e-6}, "g": {"mass", 0.001}, "kg": {"mass", 1}, "t": {"mass", 1000},
+	"oz": {"mass", 0.028349523125}, "lb": {"mass", 0.45359237},
+
+	"ml": {"volume", 0.001}, "l": {"volume", 1}, "tsp": {"volume", 0.00492892159375}, "tbsp": {"volume", 0.01478676478125},
+	"cup": {"volume", 0.2365882365}, "floz": {"volume", 0.0295735295625}, "gal": {"volume", 3.785411784},
+
+	"m/s": {"speed", 1}, "km/h": {"speed", 1 / 3.6}, "mph": {"speed", 0.44704}, "kn": {"speed", 1852.0 / 3600},
+
+	"b": {"data", 1}, "kb": {"data", 1e3}, "mb": {"data", 1e6}, "gb": {"data", 1e9}, "tb": {"data", 1e12},
+	"kib": {"data", 1 << 10}, "mib": {"data", 1 << 20}, "gib": {"data", 1 << 30}, "tib": {"data", 1 << 40},
+}
+
+// unitAliases maps spelled-out unit names onto the keys of units.
+var unitAliases = map[string]string{
+	"millimeter": "mm", "centimeter": "cm", "meter": "m", "metre": "m", "kilometer": "km", "kilometre": "km",
+	"inch": "in", "inches": "in", "foot": "ft", "feet": "ft", "yard": "yd", "mile": "mi",
+	"milligram": "mg", "gram": "g", "kilogram": "kg", "tonne": "t", "ounce": "oz", "pound": "lb", "lbs": "lb",
+	"milliliter": "ml", "millilitre": "ml", "liter": "l", "litre": "l", "teaspoon": "tsp", "tablespoon": "tbsp", "tbs": "tbsp",
+	"fl oz": "floz", "gallon": "gal", "kph": "km/h", "knot": "kn",
+	"celsius": "c", "°c": "c", "fahrenheit": "f", "°f": "f", "kelvin": "k",
+	"byte": "b", "kilobyte": "kb", "megabyte": "mb", "gigabyte": "gb", "terabyte": "tb",
+}
+
+// normalizeUnit returns the key of units for a unit name, abbreviation or alias,
+// singular or plural ("kgs", "cups", "miles").
+func normalizeUnit(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if key, ok := unitKey(name); ok {
+		return key
+	}
+	if singular := strings.TrimSuffix(name, "s"); singular != name {
+		if key, ok := unitKey(singular); ok {
+			return key
+		}
+	}
+	return name
+}
+
+func unitKey(name string) (string, bool) {
+	if alias, ok := unitAliases[name]; ok {
+		return alias, true
+	}
+	if _, ok := units[name]; ok || isTemperature(name) {
+		return name, true
+	}
+	return "", false
+}
+
+// ConvertUnits converts value from one unit to another of the same dimension.
+func ConvertUnits(value float64, from string, to string) (float64, error) {
+	fromKey, toKey := normalizeUnit(from), normalizeUnit(to)
+
+	if isTemperature(fromKey) || isTemperature(toKey) {
+		if !isTemperature(fromKey) || !isTemperature(toKey) {
+			return 0, fmt.Errorf("cannot convert %s to %s", from, to)
+		}
+		return fromKelvin(toKelvin(value, fromKey), toKey), nil
+	}
+
+	fromUnit, ok := units[fromKey]
+	if !ok {
+		return 0, fmt.Errorf("unknown unit %q", from)
+	}
+	toUnit, ok := units[toKey]
+	if !ok {
+		return 0, fmt.Errorf("unknown unit %q", to)
+	}
+	if fromUnit.dimension != toUnit.dimension {
+		return 0, fmt.Errorf("cannot convert %s (%s) to %s (%s)", from, fromUnit.dimension, to, toUnit.dimension)
+	}
+	return value * fromUnit.factor / toUnit.factor, nil
+}
+
+func isTemperature(key string) bool {
+	return key == "c" || key == "f" || key == "k"
+}
+
+func toKelvin(value float64, key string) float64 {
+	switch key {
+	case "c":
+		return value + 273.15
+	case "f":
+		return (value-32)*5/9 + 273.15
+	}
+	return value
+}
+
+func fromKelvin(value float64, key string) float64 {
+	switch key {
+	case "c":
+		return value - 273.15
+	case "f":
+		return (value-273.15)*9/5 + 32
+	}
+	return value
+}
+
+// EvaluateExpression evaluates an arithmetic expression with the usual precedence.
+// ^ is exponentiation and binds tighter than unary minus, so -2^2 is -4.
+func EvaluateExpression(expression string) (float64, error) {
+	p := &expressionParser{input: expression}
+	value, err := p.parseSum()
+	if err != nil {
+		return 0, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.input) {
+		return 0, fmt.Errorf("unexpected %q at position %d", p.input[p.pos:], p.pos+1)
+	}
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, fmt.Errorf("result is not a finite number")
+	}
+	return value, nil
+}
+
+// expressionParser is a recursive descent parser over the grammar
+//
+//	sum     = product { ("+" | "-") product }
+//	product = unary { ("*" | "/" | "%") unary }
+//	unary   = ("+" | "-") unary | power
+//	power   = primary [ "^" unary ]
+//	primary = number | name | name "(" sum ")" | "(" sum ")"
+type expressionParser struct {
+	input string
+	pos   int
+	depth int // Nesting of parseUnary calls, bounded by maxExpressionDepth
+}
+
+// maxExpressionDepth bounds the nesting of parentheses, signs and powers, so a
+// model-supplied expression cannot exhaust the stack.
+const maxExpressionDepth = 100
+
+var expressionFunctions = map[string]func(float64) float64{
+	"sqrt": math.Sqrt, "abs": math.Abs, "round": math.Round, "floor": math.Floor, "ceil": math.Ceil,
+	"ln": math.Log, "log": math.Log10, "sin": math.Sin, "cos": math.Cos, "tan": math.Tan,
+}
+
+var expressionConstants = map[string]float64{"pi": math.Pi, "e": math.E}
+
+func (p *expressionParser) skipSpace() {
+	for p.pos < len(p.input) && unicode.IsSpace(rune(p.input[p.pos])) {
+		p.pos++
+	}
+}
+
+// accept consumes op if it is the next non-space character.
+func (p *expressionParser) accept(op byte) bool {
+	p.skipSpace()
+	if p.pos < len(p.input) && p.input[p.pos] == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *expressionParser) parseSum() (float64, error) {
+	value, err := p.parseProduct()
+	for err == nil {
+		var rhs float64
+		switch {
+		case p.accept('+'):
+			rhs, err = p.parseProduct()
+			value += rhs
+		case p.accept('-'):
+			rhs, err = p.parseProduct()
+			value -= rhs
+		default:
+			return value, nil
+		}
+	}
+	return 0, err
+}
+
+func (p *expressionParser) parseProduct() (float64, error) {
+	value, err := p.parseUnary()
+	for err == nil {
+		var rhs float64
+		switch {
+		case p.accept('*'):
+			rhs, err = p.parseUnary()
+			value *= rhs
+		case p.accept('/'):
+			if rhs, err = p.parseUnary(); err == nil && rhs == 0 {
+				return 0, fmt.Errorf("division by zero")
+			}
+			value /= rhs
+		case p.accept('%'):
+			if rhs, err = p.parseUnary(); err == nil && rhs == 0 {
+				return 0, fmt.Errorf("division by zero")
+			}
+			value = math.Mod(value, rhs)
+		default:
+			return value, nil
+		}
+	}
+	return 0, err
+}
+
+func (p *expressionParser) parseUnary() (float64, error) {
+	// Every nested expression goes through here
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxExpressionDepth {
+		return 0, fmt.Errorf("expression is nested too deeply")
+	}
+
+	if p.accept('-') {
+		value, err := p.parseUnary()
+		return -value, err
+	}
+	if p.accept('+') {
+		return p.parseUnary()
+	}
+	return p.parsePower()
+}
+
+func (p *expressionParser) parsePower() (float64, error) {
+	base, err := p.parsePrimary()
+	if err != nil {
+		return 0, err
+	}
+	if p.accept('^') {
+		exponent, err := p.parseUnary()
+		if err != nil {
+			return 0, err
+		}
+		return math.Pow(base, exponent), nil
+	}
+	return base, nil
+}
+
+func (p *expressionParser) parsePrimary() (float64, error) {
+	p.skipSpace()
+	if p.pos >= len(p.input) {
+		return 0, fmt.Errorf("unexpected end of expression")
+	}
+
+	if p.accept('(') {
+		value, err := p.parseSum()
+		if err != nil {
+			return 0, err
+		}
+		if !p.accept(')') {
+			return 0, fmt.Errorf("missing closing parenthesis")
+		}
+		return value, nil
+	}
+
+	start := p.pos
+	c := p.input[p.pos]
+	switch {
+	case c >= '0' && c <= '9' || c == '.':
+		for p.pos < len(p.input) && (p.input[p.pos] >= '0' && p.input[p.pos] <= '9' || p.input[p.pos] == '.') {
+			p.pos++
+		}
+		// Scientific notation such as 1.5e3
+		if p.pos+1 < len(p.input) && (p.input[p.pos] == 'e' || p.input[p.pos] == 'E') && strings.ContainsRune("0123456789+-", rune(p.input[p.pos+1])) {
+			p.pos += 2
+			for p.pos < len(p.input) && p.input[p.pos] >= '0' && p.input[p.pos] <= '9' {
+				p.pos++
+			}
+		}
+		value, err := strconv.ParseFloat(p.input[start:p.pos], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid number %q", p.input[start:p.pos])
+		}
+		return value, nil
+
+	case unicode.IsLetter(rune(c)):
+		for p.pos < len(p.input) && unicode.IsLetter(rune(p.input[p.pos])) {
+			p.pos++
+		}
+		name := strings.ToLower(p.input[start:p.pos])
+		if fn, ok := expressionFunctions[name]; ok {
+			if !p.accept('(') {
+				return 0, fmt.Errorf("%s needs an argument in parentheses", name)
+			}
+			arg, err := p.parseSum()
+			if err != nil {
+				return 0, err
+			}
+			if !p.accept(')') {
+				return 0, fmt.Errorf("missing closing parenthesis")
+			}
+			return fn(arg), nil
+		}
+		if value, ok := expressionConstants[name]; ok {
+			return value, nil
+		}
+		return 0, fmt.Errorf("unknown name %q", name)
+	}
+	return 0, fmt.Errorf("unexpected %q at position %d", string(c), p.pos+1)
+}
@@ -0,0 +1,120 @@
+// services/builtin_tools_test.go
+
+package services
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestEvaluateExpression(t *testing.T) {
+	nested := func(depth int) string {
+		return strings.Repeat("(", depth) + "1" + strings.Repeat(")", depth)
+	}
+
+	tests := []struct {
+		expression string
+		want       float64
+		err        string // Substring of the error, empty if evaluation succeeds
+	}{
+		{expression: "1 + 2 * 3", want: 7},
+		{expression: "(1 + 2) * 3", want: 9},
+		{expression: "10 - 4 - 3", want: 3},
+		{expression: "2 ^ 3 ^ 2", want: 512},
+		{expression: "-2^2", want: -4},
+		{expression: "2^-1", want: 0.5},
+		{expression: "--3", want: 3},
+		{expression: "7 % 4 / 2", want: 1.5},
+		{expression: "1.5e3 + .5", want: 1500.5},
+		{expression: "sqrt(16) + abs(-2) + ROUND(2.5)", want: 9},
+		{expression: "floor(-1.5) + ceil(1.2)", want: 0},
+		{expression: "log(1000) + ln(e)", want: 4},
+		{expression: "cos(pi)", want: -1},
+		{expression: nested(maxExpressionDepth - 1), want: 1},
+		{expression: "", err: "unexpected end of expression"},
+		{expression: "1 +", err: "unexpected end of expression"},
+		{expression: "(1 + 2", err: "missing closing parenthesis"},
+		{expression: "1 2", err: `unexpected "2" at position 3`},
+		{expression: "1 $ 2", err: `unexpected "$ 2" at position 3`},
+		{expression: "1..2", err: `invalid number "1..2"`},
+		{expression: "sqrt 4", err: "sqrt needs an argument in parentheses"},
+		{expression: "foo(1)", err: `unknown name "foo"`},
+		{expression: "1 / 0", err: "division by zero"},
+		{expression: "10 ^ 400", err: "result is not a finite number"},
+		{expression: "sqrt(-1)", err: "result is not a finite number"},
+		{expression: nested(maxExpressionDepth), err: "expression is nested too deeply"},
+		{expression: strings.Repeat("-", 100000) + "1", err: "expression is nested too deeply"},
+		{expression: strings.Repeat("2^", 100000) + "1", err: "expression is nested too deeply"},
+		{expression: strings.Repeat("sqrt(", 100000), err: "expression is nested too deeply"},
+	}
+
+	for _, tt := range tests {
+		name := tt.expression
+		if len(name) > 30 {
+			name = name[:30] + "..."
+		}
+		t.Run(name, func(t *testing.T) {
+			got, err := EvaluateExpression(tt.expression)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("error = %v, want one containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertUnits(t *testing.T) {
+	tests := []struct {
+		value    float64
+		from, to string
+		want     float64
+		err      string // Substring of the error, empty if the conversion succeeds
+	}{
+		{value: 1, from: "km", to: "m", want: 1000},
+		{value: 12, from: "in", to: "ft", want: 1},
+		{value: 1, from: "Mile", to: "kilometres", want: 1.609344},
+		{value: 2, from: "kgs", to: "lbs", want: 4.40924524},
+		{value: 2, from: "cups", to: "ml", want: 473.176473},
+		{value: 3, from: "feet", to: "inches", want: 36},
+		{value: 1, from: "tbs", to: "tsp", want: 3},
+		{value: 1, from: "tbsps", to: "tsp", want: 3},
+		{value: 1, from: "gallons", to: "l", want: 3.785411784},
+		{value: 36, from: "km/h", to: "m/s", want: 10},
+		{value: 1, from: "kn", to: "km/h", want: 1.852},
+		{value: 1, from: "gib", to: "mib", want: 1024},
+		{value: 2, from: "megabytes", to: "kb", want: 2000},
+		{value: 100, from: "celsius", to: "f", want: 212},
+		{value: 32, from: "°F", to: "c", want: 0},
+		{value: 0, from: "kelvins", to: "c", want: -273.15},
+		{value: 1, from: "m", to: "kg", err: "cannot convert m (length) to kg (mass)"},
+		{value: 1, from: "c", to: "m", err: "cannot convert c to m"},
+		{value: 1, from: "parsecs", to: "m", err: `unknown unit "parsecs"`},
+		{value: 1, from: "m", to: "", err: `unknown unit ""`},
+	}
+
+	for _, tt := range tests {
+		got, err := ConvertUnits(tt.value, tt.from, tt.to)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("ConvertUnits(%v, %q, %q) error = %v, want one containing %q", tt.value, tt.from, tt.to, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ConvertUnits(%v, %q, %q): %v", tt.value, tt.from, tt.to, err)
+			continue
+		}
+		if math.Abs(got-tt.want) > 1e-6*math.Max(1, math.Abs(tt.want)) {
+			t.Errorf("ConvertUnits(%v, %q, %q) = %v, want %v", tt.value, tt.from, tt.to, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+// services/chat_events.go
+
+package services
+
+import (
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ChatEvent is a change to a chat that happened outside the request streaming it,
+// such as a title generated in the background.
+type ChatEvent struct {
+	Type string      // Used as the SSE event name, e.g. "title"
+	Data interface{} // Encoded as JSON
+}
+
+// chatEventBuffer is how many events a slow subscriber may lag behind before
+// further events are dropped for it.
+const chatEventBuffer = 8
+
+// ChatEventBroker fans chat events out to the clients subscribed to that chat.
+type ChatEventBroker struct {
+	mu          sync.Mutex
+	subscribers map[primitive.ObjectID]map[chan ChatEvent]struct{}
+}
+
+func NewChatEventBroker() *ChatEventBroker {
+	return &ChatEventBroker{
+		subscribers: make(map[primitive.ObjectID]map[chan ChatEvent]struct{}),
+	}
+}
+
+// Subscribe returns a channel receiving the events of a chat and a function that
+// must be called to unsubscribe.
+func (b *ChatEventBroker) Subscribe(chatID primitive.ObjectID) (<-chan ChatEvent, func()) {
+	ch := make(chan ChatEvent, chatEventBuffer)
+
+	b.mu.Lock()
+	if b.subscribers[chatID] == nil {
+		b.subscribers[chatID] = make(map[chan ChatEvent]struct{})
+	}
+	b.subscribers[chatID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	unsubscribe := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subscribers[chatID], ch)
+		if len(b.subscribers[chatID]) == 0 {
+			delete(b.subscribers, chatID)
+		}
+	}
+	return ch, unsubscribe
+}
+
+// Publish sends an event to every subscriber of the chat without blocking.
+func (b *ChatEventBroker) Publish(chatID primitive.ObjectID, event ChatEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers[chatID] {
+		select {
+		case ch <- event:
+		default:
+			// Subscriber is not keeping up; drop rather than block the publisher
+		}
+	}
+}
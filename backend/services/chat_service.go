@@ -3,27 +3,88 @@
 package services
 
 import (
+	"errors"
+
 	"github.com/ashuthe1/localmind/models"
 	"github.com/ashuthe1/localmind/repository"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// ErrMessageNotFound is returned when a message ID does not belong to the chat.
+var ErrMessageNotFound = errors.New("message not found")
+
+// ErrVariantNotFound is returned when a variant index is out of range for the message.
+var ErrVariantNotFound = errors.New("variant not found")
+
+// ErrNotRegenerable is returned when a message cannot be regenerated, i.e. it is not an
+// assistant reply to a user turn.
+var ErrNotRegenerable = errors.New("message cannot be regenerated")
+
+// ErrNotEditable is returned when editing a message that is not a user message.
+var ErrNotEditable = errors.New("only user messages can be edited")
+
+// ErrChatBusy is returned when a message could not be added because other messages
+// kept being added to the chat at the same time.
+var ErrChatBusy = errors.New("chat is being changed concurrently")
+
+// maxAppendAttempts bounds the retries of AddMessage when the active branch changes.
+const maxAppendAttempts = 5
+
 type ChatService struct {
 	ChatRepo *repository.ChatRepository
+	// Indexer is told about every change to a chat's messages, for semantic search.
+	Indexer *MessageIndexer
 }
 
-func NewChatService(chatRepo *repository.ChatRepository) *ChatService {
+func NewChatService(chatRepo *repository.ChatRepository, indexer *MessageIndexer) *ChatService {
 	return &ChatService{
 		ChatRepo: chatRepo,
+		Indexer:  indexer,
 	}
 }
 
-func (s *ChatService) CreateChat(title string) (*models.Chat, error) {
+// loadForUpdate loads a chat whose messages are about to change. The targeted
+// updates below rely on stored parent links, so a chat saved before messages had
+// them gets its tree written first.
+func (s *ChatService) loadForUpdate(chatID primitive.ObjectID) (*models.Chat, error) {
+	chat, err := s.ChatRepo.GetChatByID(chatID)
+	if err != nil {
+		return nil, err
+	}
+	if normalizeTree(chat) {
+		if err := s.ChatRepo.UpdateChat(chat); err != nil {
+			return nil, err
+		}
+	}
+	return chat, nil
+}
+
+// appendMessage stores a new message as the active leaf and queues the chat for indexing.
+func (s *ChatService) appendMessage(chatID primitive.ObjectID, message models.Message) error {
+	if err := s.ChatRepo.AppendMessage(chatID, message); err != nil {
+		return err
+	}
+	s.Indexer.Notify(chatID)
+	return nil
+}
+
+// replaceMessage stores a changed message and queues the chat for indexing.
+func (s *ChatService) replaceMessage(chatID primitive.ObjectID, message models.Message) error {
+	if err := s.ChatRepo.ReplaceMessage(chatID, message); err != nil {
+		return err
+	}
+	s.Indexer.Notify(chatID)
+	return nil
+}
+
+func (s *ChatService) CreateChat(title string, model string, personaID primitive.ObjectID) (*models.Chat, error) {
 	chat := &models.Chat{
-		ID:       primitive.NewObjectID(),
-		Title:    title,
-		Messages: []models.Message{},
+		ID:        primitive.NewObjectID(),
+		Title:     title,
+		Messages:  []models.Message{},
+		Model:     model,
+		PersonaID: personaID,
 	}
 
 	err := s.ChatRepo.CreateChat(chat)
@@ -34,27 +95,266 @@ func (s *ChatService) CreateChat(title string) (*models.Chat, error) {
 }
 
 func (s *ChatService) GetChatByID(id primitive.ObjectID) (*models.Chat, error) {
-	return s.ChatRepo.GetChatByID(id)
+	chat, err := s.ChatRepo.GetChatByID(id)
+	if err != nil {
+		return nil, err
+	}
+	normalizeTree(chat)
+	return chat, nil
 }
 
 func (s *ChatService) GetAllChats() ([]models.Chat, error) {
-	return s.ChatRepo.GetAllChats()
+	chats, err := s.ChatRepo.GetAllChats()
+	if err != nil {
+		return nil, err
+	}
+	for i := range chats {
+		normalizeTree(&chats[i])
+	}
+	return chats, nil
 }
 
+// AddMessage adds a message to a chat and makes it the end of the active branch. A
+// message without a ParentID continues the active branch; it is only stored while
+// that branch's leaf is unchanged, so concurrent sends are chained instead of forking.
 func (s *ChatService) AddMessage(chatID primitive.ObjectID, message models.Message) error {
-	chat, err := s.ChatRepo.GetChatByID(chatID)
+	if !message.ParentID.IsZero() {
+		if _, err := s.loadForUpdate(chatID); err != nil {
+			return err
+		}
+		return s.appendMessage(chatID, message)
+	}
+
+	for attempt := 0; attempt < maxAppendAttempts; attempt++ {
+		chat, err := s.loadForUpdate(chatID)
+		if err != nil {
+			return err
+		}
+		message.ParentID = chat.ActiveLeafID
+		stored, err := s.ChatRepo.AppendMessageAfterLeaf(chatID, chat.ActiveLeafID, message)
+		if err != nil {
+			return err
+		}
+		if stored {
+			s.Indexer.Notify(chatID)
+			return nil
+		}
+	}
+	return ErrChatBusy
+}
+
+// ForkMessage stores message as an edit of a user message: a sibling of the edited
+// message that starts a new, now active, branch. The original branch is kept. It
+// returns the stored message.
+func (s *ChatService) ForkMessage(chatID primitive.ObjectID, editedID primitive.ObjectID, message models.Message) (models.Message, error) {
+	chat, err := s.loadForUpdate(chatID)
+	if err != nil {
+		return message, err
+	}
+
+	message, err = forkOf(chat, editedID, message)
+	if err != nil {
+		return message, err
+	}
+	return message, s.appendMessage(chatID, message)
+}
+
+// forkOf prepares message to be stored as an edit of the user message editedID.
+func forkOf(chat *models.Chat, editedID primitive.ObjectID, message models.Message) (models.Message, error) {
+	idx := indexOfMessage(chat.Messages, editedID)
+	if idx < 0 {
+		return message, ErrMessageNotFound
+	}
+	if chat.Messages[idx].Role != "user" {
+		return message, ErrNotEditable
+	}
+
+	message.ParentID = chat.Messages[idx].ParentID
+	// The edit keeps the attachments of the original message, and its schema unless it sets one
+	message.Attachments = chat.Messages[idx].Attachments
+	if len(message.Schema) == 0 {
+		message.Schema = chat.Messages[idx].Schema
+	}
+	return message, nil
+}
+
+// SwitchBranch makes the branch through messageID active. When the message has been
+// continued, its most recent continuation is shown. It returns the new active leaf.
+func (s *ChatService) SwitchBranch(chatID primitive.ObjectID, messageID primitive.ObjectID) (primitive.ObjectID, error) {
+	chat, err := s.GetChatByID(chatID)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	if indexOfMessage(chat.Messages, messageID) < 0 {
+		return primitive.NilObjectID, ErrMessageNotFound
+	}
+
+	leafID := latestLeaf(chat, messageID)
+	if err := s.ChatRepo.UpdateChatActiveLeaf(chatID, leafID); err != nil {
+		return primitive.NilObjectID, err
+	}
+	return leafID, nil
+}
+
+// SetChatModel changes the model used for new replies in a chat.
+func (s *ChatService) SetChatModel(chatID primitive.ObjectID, model string) error {
+	return s.ChatRepo.UpdateChatModel(chatID, model)
+}
+
+// SetChatPersona changes the persona applied to a chat.
+func (s *ChatService) SetChatPersona(chatID primitive.ObjectID, personaID primitive.ObjectID) error {
+	return s.ChatRepo.UpdateChatPersona(chatID, personaID)
+}
+
+// SetChatCollections replaces the document collections a chat retrieves from.
+func (s *ChatService) SetChatCollections(chatID primitive.ObjectID, collectionIDs []primitive.ObjectID) error {
+	return s.ChatRepo.UpdateChatCollections(chatID, collectionIDs)
+}
+
+// SetChatOptions replaces the default generation options of a chat.
+func (s *ChatService) SetChatOptions(chatID primitive.ObjectID, options *models.GenerationOptions) error {
+	return s.ChatRepo.UpdateChatOptions(chatID, options)
+}
+
+// SetMessagePinned marks a message so the "pinned" context strategy never drops it.
+func (s *ChatService) SetMessagePinned(chatID primitive.ObjectID, messageID primitive.ObjectID, pinned bool) error {
+	chat, err := s.GetChatByID(chatID)
+	if err != nil {
+		return err
+	}
+
+	for i := range chat.Messages {
+		if chat.Messages[i].ID == messageID {
+			chat.Messages[i].Pinned = pinned
+			return s.ChatRepo.ReplaceMessage(chatID, chat.Messages[i])
+		}
+	}
+	return ErrMessageNotFound
+}
+
+// RegenerationHistory returns a copy of the chat holding only the messages of the
+// branch before the assistant message to regenerate, so the new reply answers the
+// same user turn.
+func (s *ChatService) RegenerationHistory(chat *models.Chat, messageID primitive.ObjectID) (*models.Chat, error) {
+	path := PathTo(chat, messageID)
+	if len(path) == 0 {
+		return nil, ErrMessageNotFound
+	}
+	n := len(path)
+	// The tools called for the reply stay part of its history
+	turn := n - 2
+	for turn >= 0 && (path[turn].Role == models.MessageRoleToolCall || path[turn].Role == models.MessageRoleToolResult) {
+		turn--
+	}
+	if path[n-1].Role != "assistant" || turn < 0 || path[turn].Role != "user" {
+		return nil, ErrNotRegenerable
+	}
+	history := *chat
+	history.Messages = path[:n-1]
+	return &history, nil
+}
+
+// TurnSchema returns the JSON schema requested with the last user message of a branch.
+func TurnSchema(branch *models.Chat) models.RawJSON {
+	for i := len(branch.Messages) - 1; i >= 0; i-- {
+		if branch.Messages[i].Role == "user" {
+			return branch.Messages[i].Schema
+		}
+	}
+	return nil
+}
+
+// AddVariant stores reply as a new variant of an assistant message and makes it the
+// active one. The message's original reply becomes the first variant.
+func (s *ChatService) AddVariant(chatID primitive.ObjectID, messageID primitive.ObjectID, reply models.Message) error {
+	chat, err := s.GetChatByID(chatID)
 	if err != nil {
 		return err
 	}
 
-	chat.Messages = append(chat.Messages, message)
-	return s.ChatRepo.UpdateChat(chat)
+	for i := range chat.Messages {
+		msg := &chat.Messages[i]
+		if msg.ID != messageID {
+			continue
+		}
+		if len(msg.Variants) == 0 {
+			msg.Variants = []models.MessageVariant{variantOf(*msg)}
+		}
+		msg.Variants = append(msg.Variants, variantOf(reply))
+		activateVariant(msg, len(msg.Variants)-1)
+		return s.replaceMessage(chatID, *msg)
+	}
+	return ErrMessageNotFound
+}
+
+// SelectVariant makes the variant at index the reply shown and replayed for a message.
+func (s *ChatService) SelectVariant(chatID primitive.ObjectID, messageID primitive.ObjectID, index int) error {
+	chat, err := s.GetChatByID(chatID)
+	if err != nil {
+		return err
+	}
+
+	for i := range chat.Messages {
+		msg := &chat.Messages[i]
+		if msg.ID != messageID {
+			continue
+		}
+		if len(msg.Variants) == 0 && index == 0 {
+			return nil // The only reply is already active
+		}
+		if index < 0 || index >= len(msg.Variants) {
+			return ErrVariantNotFound
+		}
+		activateVariant(msg, index)
+		return s.replaceMessage(chatID, *msg)
+	}
+	return ErrMessageNotFound
+}
+
+func variantOf(msg models.Message) models.MessageVariant {
+	return models.MessageVariant{
+		ID:        msg.ID,
+		Content:   msg.Content,
+		Reasoning: msg.Reasoning,
+		Model:     msg.Model,
+		Status:    msg.Status,
+		Citations: msg.Citations,
+		Data:      msg.Data,
+		Tools:     msg.Tools,
+		Timestamp: msg.Timestamp,
+	}
+}
+
+// activateVariant copies a variant into the message fields the rest of the app reads.
+func activateVariant(msg *models.Message, index int) {
+	variant := msg.Variants[index]
+	msg.ActiveVariant = index
+	msg.Content = variant.Content
+	msg.Reasoning = variant.Reasoning
+	msg.Model = variant.Model
+	msg.Status = variant.Status
+	msg.Citations = variant.Citations
+	msg.Data = variant.Data
+	msg.Tools = variant.Tools
+}
+
+// SearchChats runs a keyword search over chat titles and messages.
+func (s *ChatService) SearchChats(query repository.ChatSearchQuery) (*repository.ChatSearchPage, error) {
+	return s.ChatRepo.SearchMessages(query)
 }
 
 func (s *ChatService) DeleteChat(id primitive.ObjectID) error {
-	return s.ChatRepo.DeleteChat(id)
+	if err := s.ChatRepo.DeleteChat(id); err != nil {
+		return err
+	}
+	s.Indexer.Forget(id)
+	return nil
 }
 
 func (s *ChatService) DeleteAllChats() error {
-	return s.ChatRepo.DeleteAllChats()
-}
\ No newline at end of file
+	if err := s.ChatRepo.DeleteAllChats(); err != nil {
+		return err
+	}
+	s.Indexer.ForgetAll()
+	return nil
+}
@@ -0,0 +1,160 @@
+// services/chat_tree.go
+
+package services
+
+import (
+	"time"
+
+	"github.com/ashuthe1/localmind/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// A chat is stored as a tree: Chat.Messages holds the messages of every branch in
+// creation order, each pointing at the message it follows through ParentID, and
+// Chat.ActiveLeafID selects the branch that is shown and replayed to the model.
+
+// ChatBranch describes one branch of a chat, identified by its last message.
+type ChatBranch struct {
+	LeafID    primitive.ObjectID `json:"leafId"`
+	ForkID    primitive.ObjectID `json:"forkId"`  // First message of the branch after its latest fork
+	Preview   string             `json:"preview"` // Content of the fork message
+	Length    int                `json:"length"`  // Messages from the root to the leaf
+	UpdatedAt time.Time          `json:"updatedAt"`
+	Active    bool               `json:"active"`
+}
+
+// normalizeTree turns chats stored before branching existed, a flat list of messages,
+// into a single branch. It reports whether the chat was changed.
+func normalizeTree(chat *models.Chat) bool {
+	if !chat.ActiveLeafID.IsZero() || len(chat.Messages) == 0 {
+		return false
+	}
+	for i := 1; i < len(chat.Messages); i++ {
+		if chat.Messages[i].ParentID.IsZero() {
+			chat.Messages[i].ParentID = chat.Messages[i-1].ID
+		}
+	}
+	chat.ActiveLeafID = chat.Messages[len(chat.Messages)-1].ID
+	return true
+}
+
+// PathTo returns the messages from the root of the chat down to and including id, or
+// nil if the chat has no such message.
+func PathTo(chat *models.Chat, id primitive.ObjectID) []models.Message {
+	return pathTo(chat, messageIndexes(chat), id)
+}
+
+// pathTo is PathTo with the index of every message by ID already built.
+func pathTo(chat *models.Chat, byID map[primitive.ObjectID]int, id primitive.ObjectID) []models.Message {
+	var path []models.Message
+	for idx, ok := byID[id]; ok; idx, ok = byID[chat.Messages[idx].ParentID] {
+		path = append(path, chat.Messages[idx])
+		if len(path) > len(chat.Messages) {
+			return nil // A cycle means the tree is corrupt
+		}
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+// ActiveBranch returns a copy of the chat whose Messages are only those on the active branch.
+func ActiveBranch(chat *models.Chat) *models.Chat {
+	return BranchTo(chat, chat.ActiveLeafID)
+}
+
+// BranchTo returns a copy of the chat whose Messages are those from the root down to
+// and including leafID.
+func BranchTo(chat *models.Chat, leafID primitive.ObjectID) *models.Chat {
+	branch := *chat
+	branch.Messages = PathTo(chat, leafID)
+	if branch.Messages == nil {
+		branch.Messages = []models.Message{}
+	}
+	return &branch
+}
+
+// Branches lists every branch of the chat, oldest first.
+func Branches(chat *models.Chat) []ChatBranch {
+	byID := messageIndexes(chat)
+	children := childIndexes(chat)
+
+	var branches []ChatBranch
+	for _, msg := range chat.Messages {
+		if len(children[msg.ID]) > 0 {
+			continue // Not a leaf
+		}
+		path := pathTo(chat, byID, msg.ID)
+		if len(path) == 0 {
+			continue
+		}
+
+		// The fork is the latest message on the path that has siblings
+		fork := path[0]
+		for i := len(path) - 1; i >= 0; i-- {
+			if len(children[path[i].ParentID]) > 1 {
+				fork = path[i]
+				break
+			}
+		}
+
+		branches = append(branches, ChatBranch{
+			LeafID:    msg.ID,
+			ForkID:    fork.ID,
+			Preview:   fork.Content,
+			Length:    len(path),
+			UpdatedAt: msg.Timestamp,
+			Active:    msg.ID == chat.ActiveLeafID,
+		})
+	}
+	return branches
+}
+
+// latestLeaf returns the most recently created leaf below a message, or the message
+// itself if nothing follows it. Messages are in creation order, so that is the
+// descendant stored last.
+func latestLeaf(chat *models.Chat, id primitive.ObjectID) primitive.ObjectID {
+	children := childIndexes(chat)
+
+	latest := -1
+	visited := make(map[int]bool)
+	stack := []primitive.ObjectID{id}
+	for len(stack) > 0 {
+		parent := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, idx := range children[parent] {
+			if visited[idx] {
+				continue // A cycle means the tree is corrupt
+			}
+			visited[idx] = true
+			if idx > latest {
+				latest = idx
+			}
+			stack = append(stack, chat.Messages[idx].ID)
+		}
+	}
+	if latest < 0 {
+		return id
+	}
+	return chat.Messages[latest].ID
+}
+
+// messageIndexes maps the ID of every message to its index in chat.Messages.
+func messageIndexes(chat *models.Chat) map[primitive.ObjectID]int {
+	byID := make(map[primitive.ObjectID]int, len(chat.Messages))
+	for i, msg := range chat.Messages {
+		byID[msg.ID] = i
+	}
+	return byID
+}
+
+// childIndexes lists the indexes of the replies to every message, in creation order.
+// Roots are listed under the zero ID.
+func childIndexes(chat *models.Chat) map[primitive.ObjectID][]int {
+	children := make(map[primitive.ObjectID][]int, len(chat.Messages))
+	for i, msg := range chat.Messages {
+		children[msg.ParentID] = append(children[msg.ParentID], i)
+	}
+	return children
+}
@@ -0,0 +1,249 @@
+// services/chat_tree_test.go
+
+package services
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/ashuthe1/localmind/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// testTree builds a chat from messages given as {label, role, parent label}, in
+// creation order. The returned map finds a message ID by label.
+func testTree(messages ...[3]string) (*models.Chat, map[string]primitive.ObjectID) {
+	ids := make(map[string]primitive.ObjectID)
+	chat := &models.Chat{ID: primitive.NewObjectID()}
+	for _, m := range messages {
+		ids[m[0]] = primitive.NewObjectID()
+		chat.Messages = append(chat.Messages, models.Message{ID: ids[m[0]], Role: m[1], Content: m[0], ParentID: ids[m[2]]})
+	}
+	return chat, ids
+}
+
+// forkedChat has two branches after a1: u2 edited as u2b, and u2's branch continued by u3.
+func forkedChat() (*models.Chat, map[string]primitive.ObjectID) {
+	return testTree(
+		[3]string{"u1", "user", ""},
+		[3]string{"a1", "assistant", "u1"},
+		[3]string{"u2", "user", "a1"},
+		[3]string{"a2", "assistant", "u2"},
+		[3]string{"u2b", "user", "a1"},
+		[3]string{"a2b", "assistant", "u2b"},
+		[3]string{"u3", "user", "a2"},
+	)
+}
+
+func contents(messages []models.Message) []string {
+	out := []string{}
+	for _, msg := range messages {
+		out = append(out, msg.Content)
+	}
+	return out
+}
+
+func TestPathTo(t *testing.T) {
+	chat, ids := forkedChat()
+	tests := []struct {
+		leaf string
+		want []string
+	}{
+		{"u1", []string{"u1"}},
+		{"a2", []string{"u1", "a1", "u2", "a2"}},
+		{"u3", []string{"u1", "a1", "u2", "a2", "u3"}},
+		{"a2b", []string{"u1", "a1", "u2b", "a2b"}},
+		{"missing", []string{}},
+	}
+	for _, tt := range tests {
+		if got := contents(PathTo(chat, ids[tt.leaf])); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("PathTo(%s) = %q, want %q", tt.leaf, got, tt.want)
+		}
+	}
+}
+
+func TestPathToCycle(t *testing.T) {
+	chat, ids := testTree([3]string{"x", "user", ""}, [3]string{"y", "assistant", "x"})
+	chat.Messages[0].ParentID = ids["y"]
+
+	if path := PathTo(chat, ids["y"]); path != nil {
+		t.Errorf("PathTo in a cycle = %q, want nil", contents(path))
+	}
+	if branch := ActiveBranch(chat); branch.Messages == nil || len(branch.Messages) != 0 {
+		t.Errorf("ActiveBranch in a cycle = %v, want no messages", branch.Messages)
+	}
+	// Must terminate
+	latestLeaf(chat, ids["x"])
+}
+
+func TestActiveBranch(t *testing.T) {
+	chat, ids := forkedChat()
+	chat.ActiveLeafID = ids["a2b"]
+
+	branch := ActiveBranch(chat)
+	if got, want := contents(branch.Messages), []string{"u1", "a1", "u2b", "a2b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ActiveBranch = %q, want %q", got, want)
+	}
+	if len(chat.Messages) != 7 || branch.ID != chat.ID {
+		t.Error("ActiveBranch changed the chat or lost its fields")
+	}
+	if got, want := contents(BranchTo(chat, ids["a2"]).Messages), []string{"u1", "a1", "u2", "a2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("BranchTo(a2) = %q, want %q", got, want)
+	}
+}
+
+func TestBranches(t *testing.T) {
+	chat, ids := forkedChat()
+	chat.ActiveLeafID = ids["u3"]
+
+	got := Branches(chat)
+	want := []ChatBranch{
+		{LeafID: ids["a2b"], ForkID: ids["u2b"], Preview: "u2b", Length: 4},
+		{LeafID: ids["u3"], ForkID: ids["u2"], Preview: "u2", Length: 5, Active: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Branches = %+v, want %+v", got, want)
+	}
+
+	// A chat without forks has one branch, forking at its root
+	single, ids := testTree([3]string{"u1", "user", ""}, [3]string{"a1", "assistant", "u1"})
+	got = Branches(single)
+	if len(got) != 1 || got[0].LeafID != ids["a1"] || got[0].ForkID != ids["u1"] || got[0].Length != 2 {
+		t.Errorf("Branches without forks = %+v", got)
+	}
+}
+
+func TestLatestLeaf(t *testing.T) {
+	chat, ids := forkedChat()
+	tests := []struct {
+		from, want string
+	}{
+		{"u1", "u3"},
+		{"a1", "u3"},
+		{"u2", "u3"},
+		{"u2b", "a2b"},
+		{"a2b", "a2b"},
+		{"u3", "u3"},
+	}
+	for _, tt := range tests {
+		if got := latestLeaf(chat, ids[tt.from]); got != ids[tt.want] {
+			t.Errorf("latestLeaf(%s) = %s, want %s", tt.from, chat.Messages[indexOfMessage(chat.Messages, got)].Content, tt.want)
+		}
+	}
+}
+
+func TestNormalizeTree(t *testing.T) {
+	// Chats stored before branching are a flat list without parents
+	legacy, ids := testTree([3]string{"u1", "user", ""}, [3]string{"a1", "assistant", ""}, [3]string{"u2", "user", ""})
+	if !normalizeTree(legacy) {
+		t.Fatal("normalizeTree did not change a legacy chat")
+	}
+	if legacy.ActiveLeafID != ids["u2"] || legacy.Messages[1].ParentID != ids["u1"] || legacy.Messages[2].ParentID != ids["a1"] {
+		t.Errorf("normalized chat = %+v", legacy)
+	}
+	if !legacy.Messages[0].ParentID.IsZero() {
+		t.Error("the first message got a parent")
+	}
+
+	tree, ids := forkedChat()
+	tree.ActiveLeafID = ids["a2b"]
+	if normalizeTree(tree) {
+		t.Error("normalizeTree changed a chat with an active leaf")
+	}
+	if normalizeTree(&models.Chat{}) {
+		t.Error("normalizeTree changed an empty chat")
+	}
+}
+
+func TestRegenerationHistory(t *testing.T) {
+	chat, ids := testTree(
+		[3]string{"greeting", "assistant", ""},
+		[3]string{"u1", "user", "greeting"},
+		[3]string{"a1", "assistant", "u1"},
+		[3]string{"u2", "user", "a1"},
+		[3]string{"call", models.MessageRoleToolCall, "u2"},
+		[3]string{"result", models.MessageRoleToolResult, "call"},
+		[3]string{"a2", "assistant", "result"},
+		[3]string{"a2b", "assistant", "u2"},
+	)
+
+	tests := []struct {
+		message string
+		want    []string
+		err     error
+	}{
+		{message: "a1", want: []string{"greeting", "u1"}},
+		{message: "a2", want: []string{"greeting", "u1", "a1", "u2", "call", "result"}},
+		{message: "a2b", want: []string{"greeting", "u1", "a1", "u2"}},
+		{message: "greeting", err: ErrNotRegenerable},
+		{message: "u2", err: ErrNotRegenerable},
+		{message: "result", err: ErrNotRegenerable},
+		{message: "missing", err: ErrMessageNotFound},
+	}
+
+	s := &ChatService{}
+	for _, tt := range tests {
+		history, err := s.RegenerationHistory(chat, ids[tt.message])
+		if tt.err != nil {
+			if !errors.Is(err, tt.err) {
+				t.Errorf("RegenerationHistory(%s) error = %v, want %v", tt.message, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("RegenerationHistory(%s): %v", tt.message, err)
+			continue
+		}
+		if got := contents(history.Messages); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("RegenerationHistory(%s) = %q, want %q", tt.message, got, tt.want)
+		}
+	}
+	if len(chat.Messages) != 8 {
+		t.Error("RegenerationHistory changed the chat")
+	}
+}
+
+func TestForkOf(t *testing.T) {
+	chat, ids := forkedChat()
+	attachments := []models.Attachment{{ID: primitive.NewObjectID(), Kind: models.AttachmentKindImage}}
+	chat.Messages[2].Attachments = attachments
+	chat.Messages[2].Schema = models.RawJSON(`{"type":"object"}`)
+
+	tests := []struct {
+		name   string
+		edited string
+		schema string
+		want   string // Schema of the fork
+		err    error
+	}{
+		{name: "keeps the schema", edited: "u2", want: `{"type":"object"}`},
+		{name: "replaces the schema", edited: "u2", schema: `{"type":"array"}`, want: `{"type":"array"}`},
+		{name: "not a user message", edited: "a1", err: ErrNotEditable},
+		{name: "missing message", edited: "missing", err: ErrMessageNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			edit := models.Message{ID: primitive.NewObjectID(), Role: "user", Content: "edited"}
+			if tt.schema != "" {
+				edit.Schema = models.RawJSON(tt.schema)
+			}
+			fork, err := forkOf(chat, ids[tt.edited], edit)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Errorf("error = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fork.ParentID != ids["a1"] || fork.ID != edit.ID || fork.Content != "edited" {
+				t.Errorf("fork = %+v", fork)
+			}
+			if !reflect.DeepEqual(fork.Attachments, attachments) || string(fork.Schema) != tt.want {
+				t.Errorf("fork attachments = %v, schema = %s", fork.Attachments, fork.Schema)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+// services/chunker.go
+
+package services
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Limits for the chunk size, in bytes, accepted from configuration and uploads.
+const (
+	minChunkSize = 100
+	maxChunkSize = 8000
+)
+
+// TextChunk is a piece of a document. Start and End are byte offsets into the text.
+type TextChunk struct {
+	Start int
+	End   int
+	Text  string
+}
+
+// chunkBreaks are the preferred places to end a chunk, best first.
+var chunkBreaks = []string{"\n\n", "\n", ". ", " "}
+
+// ValidateChunking checks a chunk size and overlap. Errors are meant for the client.
+func ValidateChunking(size int, overlap int) error {
+	if size < minChunkSize || size > maxChunkSize {
+		return fmt.Errorf("chunk size must be between %d and %d", minChunkSize, maxChunkSize)
+	}
+	if overlap < 0 || overlap > size/2 {
+		return fmt.Errorf("chunk overlap must be between 0 and half the chunk size")
+	}
+	return nil
+}
+
+// ChunkText splits text into chunks of at most size bytes, each starting overlap bytes
+// before the end of the previous one. Chunks end at a paragraph, line, sentence or
+// word break when one exists in their second half, and never split a UTF-8 character.
+func ChunkText(text string, size int, overlap int) []TextChunk {
+	var chunks []TextChunk
+	start := 0
+	for start < len(text) {
+		end := start + size
+		if end >= len(text) {
+			end = len(text)
+		} else {
+			end = chunkEnd(text, start, end)
+		}
+
+		if chunk, ok := trimChunk(text, start, end); ok {
+			chunks = append(chunks, chunk)
+		}
+		if end == len(text) {
+			break
+		}
+
+		next := end - overlap
+		if next <= start {
+			next = end
+		}
+		start = chunkStart(text, next, end)
+	}
+	return chunks
+}
+
+// chunkEnd picks where a chunk starting at start should end, at most at limit.
+func chunkEnd(text string, start int, limit int) int {
+	window := text[start+(limit-start)/2 : limit]
+	for _, sep := range chunkBreaks {
+		if idx := strings.LastIndex(window, sep); idx >= 0 {
+			return limit - len(window) + idx + len(sep)
+		}
+	}
+	for limit > start && !utf8.RuneStart(text[limit]) {
+		limit--
+	}
+	return limit
+}
+
+// chunkStart moves an overlapping start forward to the next word, as long as it stays
+// before end, so chunks do not begin mid-word.
+func chunkStart(text string, start int, end int) int {
+	for start < end && !utf8.RuneStart(text[start]) {
+		start++
+	}
+	if start == 0 || start == end {
+		return start
+	}
+	if prev, _ := utf8.DecodeLastRuneInString(text[:start]); unicode.IsSpace(prev) {
+		return start
+	}
+	if idx := strings.IndexFunc(text[start:end], unicode.IsSpace); idx >= 0 {
+		return start + idx + 1
+	}
+	return start
+}
+
+// trimChunk drops surrounding whitespace, adjusting the offsets. It reports false for
+// a chunk that is only whitespace.
+func trimChunk(text string, start int, end int) (TextChunk, bool) {
+	raw := text[start:end]
+	trimmed := strings.TrimLeftFunc(raw, unicode.IsSpace)
+	start += len(raw) - len(trimmed)
+	trimmed = strings.TrimRightFunc(trimmed, unicode.IsSpace)
+	if trimmed == "" {
+		return TextChunk{}, false
+	}
+	return TextChunk{Start: start, End: start + len(trimmed), Text: trimmed}, true
+}
@@ -0,0 +1,146 @@
+// services/chunker_test.go
+
+package services
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode"
+	"unicode/utf8"
+)
+
+func TestChunkText(t *testing.T) {
+	tests := []struct {
+		name          string
+		text          string
+		size, overlap int
+		want          []TextChunk
+	}{
+		{
+			name: "empty",
+			text: "",
+			size: 10,
+		},
+		{
+			name: "whitespace only",
+			text: " \n\n\t ",
+			size: 10,
+		},
+		{
+			name: "fits in one chunk",
+			text: "  short text\n",
+			size: 100,
+			want: []TextChunk{{2, 12, "short text"}},
+		},
+		{
+			name: "breaks at words",
+			text: "aaaa bbbb cccc dddd",
+			size: 10,
+			want: []TextChunk{{0, 9, "aaaa bbbb"}, {10, 19, "cccc dddd"}},
+		},
+		{
+			name:    "overlap",
+			text:    "aaaa bbbb cccc dddd",
+			size:    10,
+			overlap: 5,
+			want:    []TextChunk{{0, 9, "aaaa bbbb"}, {5, 14, "bbbb cccc"}, {10, 19, "cccc dddd"}},
+		},
+		{
+			name:    "overlap moves to the next word",
+			text:    "abcdefgh ijklmnop",
+			size:    10,
+			overlap: 3,
+			want:    []TextChunk{{0, 8, "abcdefgh"}, {9, 17, "ijklmnop"}},
+		},
+		{
+			name: "prefers line breaks over spaces",
+			text: "para one.\n\npara two is here",
+			size: 20,
+			want: []TextChunk{{0, 9, "para one."}, {11, 27, "para two is here"}},
+		},
+		{
+			name: "prefers sentence ends over spaces",
+			text: "Alpha beta gamma. Delta epsilon zeta",
+			size: 26,
+			want: []TextChunk{{0, 17, "Alpha beta gamma."}, {18, 36, "Delta epsilon zeta"}},
+		},
+		{
+			name: "does not split characters",
+			text: "ééééé",
+			size: 5,
+			want: []TextChunk{{0, 4, "éé"}, {4, 8, "éé"}, {8, 10, "é"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ChunkText(tt.text, tt.size, tt.overlap); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ChunkText = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestChunkTextInvariants checks every chunk of a longer text against the guarantees
+// ChunkText documents, for a range of sizes and overlaps.
+func TestChunkTextInvariants(t *testing.T) {
+	paragraph := "Ünïcödé text, with punctuation. And sentences!\nA new line follows; then words " +
+		strings.Repeat("x", 130) + " and 日本語のテキスト too.\n\n"
+	text := strings.Repeat(paragraph, 20)
+
+	for _, size := range []int{minChunkSize, 150, 500, maxChunkSize} {
+		for _, overlap := range []int{0, size / 4, size / 2} {
+			if err := ValidateChunking(size, overlap); err != nil {
+				t.Fatalf("ValidateChunking(%d, %d): %v", size, overlap, err)
+			}
+			chunks := ChunkText(text, size, overlap)
+			if len(chunks) == 0 {
+				t.Fatalf("size %d overlap %d: no chunks", size, overlap)
+			}
+
+			covered := 0
+			for i, chunk := range chunks {
+				if text[chunk.Start:chunk.End] != chunk.Text {
+					t.Fatalf("size %d overlap %d: chunk %d offsets do not match its text", size, overlap, i)
+				}
+				if len(chunk.Text) > size {
+					t.Errorf("size %d overlap %d: chunk %d is %d bytes", size, overlap, i, len(chunk.Text))
+				}
+				if !utf8.ValidString(chunk.Text) {
+					t.Errorf("size %d overlap %d: chunk %d splits a character", size, overlap, i)
+				}
+				if i > 0 && chunk.Start <= chunks[i-1].Start {
+					t.Errorf("size %d overlap %d: chunk %d does not advance", size, overlap, i)
+				}
+				if gap := text[covered:max(covered, chunk.Start)]; strings.TrimFunc(gap, unicode.IsSpace) != "" {
+					t.Errorf("size %d overlap %d: text %q before chunk %d is in no chunk", size, overlap, gap, i)
+				}
+				covered = max(covered, chunk.End)
+			}
+			if rest := strings.TrimFunc(text[covered:], unicode.IsSpace); rest != "" {
+				t.Errorf("size %d overlap %d: trailing text %q is in no chunk", size, overlap, rest)
+			}
+		}
+	}
+}
+
+func TestValidateChunking(t *testing.T) {
+	tests := []struct {
+		size, overlap int
+		valid         bool
+	}{
+		{1000, 200, true},
+		{minChunkSize, 0, true},
+		{maxChunkSize, maxChunkSize / 2, true},
+		{minChunkSize - 1, 0, false},
+		{maxChunkSize + 1, 0, false},
+		{1000, -1, false},
+		{1000, 501, false},
+	}
+	for _, tt := range tests {
+		if err := ValidateChunking(tt.size, tt.overlap); (err == nil) != tt.valid {
+			t.Errorf("ValidateChunking(%d, %d) = %v, want valid %v", tt.size, tt.overlap, err, tt.valid)
+		}
+	}
+}
@@ -0,0 +1,354 @@
+// services/context_manager.go
+
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ashuthe1/localmind/logger"
+	"github.com/ashuthe1/localmind/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ContextStrategy decides what happens to old messages once a chat no longer fits
+// into the model's context window.
+type ContextStrategy string
+
+const (
+	// StrategyDropOldest drops the oldest messages until the conversation fits.
+	StrategyDropOldest ContextStrategy = "drop_oldest"
+	// StrategySummarize folds the oldest messages into a rolling summary stored on the chat.
+	StrategySummarize ContextStrategy = "summarize"
+	// StrategyPinned always keeps pinned messages and drops the oldest of the rest.
+	StrategyPinned ContextStrategy = "pinned"
+)
+
+// messageOverheadTokens approximates the role and formatting tokens the model adds per message.
+const messageOverheadTokens = 4
+
+// imageTokens approximates the prompt tokens a vision model spends on one image.
+const imageTokens = 768
+
+// minContextBudget stops a misconfigured reserve from leaving no room for the prompt.
+const minContextBudget = 256
+
+// SummaryStore persists the rolling summaries of the summarize strategy. It is
+// implemented by repository.ChatRepository.
+type SummaryStore interface {
+	SaveChatSummary(chatID primitive.ObjectID, until primitive.ObjectID, summary string) error
+}
+
+// ContextManager fits a chat's history into the context window of the model it is sent to.
+type ContextManager struct {
+	Summaries     SummaryStore
+	LLM           LLMProvider
+	Strategy      ContextStrategy
+	DefaultBudget int
+	ModelBudgets  map[string]int
+	// ReserveTokens is kept free for the model's reply.
+	ReserveTokens int
+}
+
+func NewContextManager(summaries SummaryStore, llm LLMProvider, strategy string, defaultBudget int, modelBudgets map[string]int, reserveTokens int) *ContextManager {
+	s := ContextStrategy(strategy)
+	switch s {
+	case StrategyDropOldest, StrategySummarize, StrategyPinned:
+	default:
+		logger.Log.Warnf("Unknown context strategy %q, using %q", strategy, StrategyDropOldest)
+		s = StrategyDropOldest
+	}
+
+	return &ContextManager{
+		Summaries:     summaries,
+		LLM:           llm,
+		Strategy:      s,
+		DefaultBudget: defaultBudget,
+		ModelBudgets:  modelBudgets,
+		ReserveTokens: reserveTokens,
+	}
+}
+
+// EstimateTokens approximates the token count of a text at roughly four bytes per token.
+func EstimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
+
+// EstimateMessageTokens approximates the tokens a stored message takes up in the prompt.
+func EstimateMessageTokens(msg models.Message) int {
+	tokens := EstimateTokens(msg.Content) + messageOverheadTokens
+	for _, attachment := range msg.Attachments {
+		switch attachment.Kind {
+		case models.AttachmentKindImage:
+			tokens += imageTokens
+		case models.AttachmentKindFile:
+			tokens += int(attachment.Size+3)/4 + EstimateTokens(attachment.Name) + messageOverheadTokens
+		}
+	}
+	if msg.Role == models.MessageRoleToolCall && msg.ToolCall != nil {
+		arguments, _ := json.Marshal(msg.ToolCall.Arguments)
+		tokens += EstimateTokens(msg.ToolCall.Name) + EstimateTokens(string(arguments))
+	}
+	return tokens
+}
+
+// Budget returns the number of prompt tokens available for the given model. A
+// non-zero numCtx (the context size requested in the generation options) takes
+// precedence over the configured budgets.
+func (m *ContextManager) Budget(model string, numCtx int) int {
+	budget := m.DefaultBudget
+	if b, ok := m.ModelBudgets[model]; ok {
+		budget = b
+	}
+	if numCtx > 0 {
+		budget = numCtx
+	}
+
+	budget -= m.ReserveTokens
+	if budget < minContextBudget {
+		budget = minContextBudget
+	}
+	return budget
+}
+
+// BuildContext turns the stored messages of a chat into the role-tagged conversation sent
+// to the model, preceded by the preamble (system prompt and few-shot examples) and
+// trimmed to fit the model's budget.
+func (m *ContextManager) BuildContext(ctx context.Context, chat *models.Chat, preamble []ChatMessage, model string, numCtx int) []ChatMessage {
+	messages := replayableMessages(chat.Messages)
+	budget := m.Budget(model, numCtx)
+	for _, msg := range preamble {
+		budget -= EstimateTokens(msg.Content) + messageOverheadTokens
+	}
+
+	var kept []models.Message
+	summary := ""
+	switch m.Strategy {
+	case StrategySummarize:
+		kept, summary = m.fitSummarized(ctx, chat, messages, budget, model)
+	case StrategyPinned:
+		kept = fitPinned(messages, budget)
+	default:
+		kept = fitNewest(messages, budget)
+	}
+
+	if dropped := len(messages) - len(kept); dropped > 0 {
+		logger.Log.Infof("Context budget for %s: %d of %d messages sent (strategy %s)", model, len(kept), len(messages), m.Strategy)
+	}
+	return assembleHistory(preamble, summary, kept)
+}
+
+// fitSummarized replaces the messages covered by a rolling summary with the summary
+// itself. When the rest still overflows, the oldest messages are folded into a new
+// summary, which is persisted so later turns can reuse it.
+//
+// Summaries are keyed by the last message they cover, so each branch of the chat
+// uses the latest summary on its own path and switching branches does not discard
+// the summaries of the other.
+func (m *ContextManager) fitSummarized(ctx context.Context, chat *models.Chat, messages []models.Message, budget int, model string) ([]models.Message, string) {
+	summary := ""
+	remaining := messages
+	for i := len(messages) - 1; i >= 0; i-- {
+		if s, ok := chat.Summaries[messages[i].ID.Hex()]; ok {
+			summary = s
+			remaining = messages[i+1:]
+			break
+		}
+	}
+
+	summaryCost := 0
+	if summary != "" {
+		summaryCost = EstimateTokens(summary) + messageOverheadTokens
+	}
+	if totalTokens(remaining)+summaryCost <= budget {
+		return remaining, summary
+	}
+
+	// Keep the newest messages within half the budget so the next few turns fit
+	// without summarising again.
+	keep := fitNewest(remaining, budget/2)
+	fold := remaining[:len(remaining)-len(keep)]
+	if len(fold) == 0 {
+		return keep, summary
+	}
+
+	newSummary, err := m.summarize(ctx, model, summary, fold)
+	if err != nil {
+		logger.Log.Errorf("Error summarizing chat %s, dropping oldest messages instead: %v", chat.ID.Hex(), err)
+		return fitNewest(remaining, budget-summaryCost), summary
+	}
+
+	until := fold[len(fold)-1].ID
+	if err := m.Summaries.SaveChatSummary(chat.ID, until, newSummary); err != nil {
+		logger.Log.Errorf("Error saving chat summary: %v", err)
+	}
+	if chat.Summaries == nil {
+		chat.Summaries = make(map[string]string)
+	}
+	chat.Summaries[until.Hex()] = newSummary
+
+	return keep, newSummary
+}
+
+// summarize asks the model to merge the previous summary and the given messages into a new summary.
+func (m *ContextManager) summarize(ctx context.Context, model string, previous string, messages []models.Message) (string, error) {
+	var prompt strings.Builder
+	prompt.WriteString("Summarize the following conversation between a user and an AI assistant in at most 200 words. ")
+	prompt.WriteString("Keep facts, names, decisions and open questions. Reply with the summary only.\n\n")
+	if previous != "" {
+		fmt.Fprintf(&prompt, "Summary of the conversation so far:\n%s\n\n", previous)
+	}
+	prompt.WriteString("Messages:\n")
+	for _, msg := range messages {
+		fmt.Fprintf(&prompt, "%s: %s\n", msg.Role, toChatMessage(msg).Content)
+	}
+
+	summary, err := m.LLM.Generate(ctx, model, prompt.String())
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(StripReasoning(summary)), nil
+}
+
+// fitNewest keeps the newest messages that fit into the budget. The latest message is
+// always kept, even when it alone exceeds the budget.
+func fitNewest(messages []models.Message, budget int) []models.Message {
+	used := 0
+	start := len(messages)
+	for i := len(messages) - 1; i >= 0; i-- {
+		cost := EstimateMessageTokens(messages[i])
+		if used+cost > budget && i < len(messages)-1 {
+			break
+		}
+		used += cost
+		start = i
+	}
+	return messages[start:]
+}
+
+// fitPinned keeps every pinned message and the latest message, then fills the rest of
+// the budget with the newest unpinned messages. The original order is preserved.
+func fitPinned(messages []models.Message, budget int) []models.Message {
+	keep := make([]bool, len(messages))
+	used := 0
+	for i, msg := range messages {
+		if msg.Pinned || i == len(messages)-1 {
+			keep[i] = true
+			used += EstimateMessageTokens(msg)
+		}
+	}
+
+	for i := len(messages) - 2; i >= 0; i-- {
+		if keep[i] {
+			continue
+		}
+		cost := EstimateMessageTokens(messages[i])
+		if used+cost > budget {
+			break
+		}
+		keep[i] = true
+		used += cost
+	}
+
+	kept := make([]models.Message, 0, len(messages))
+	for i, msg := range messages {
+		if keep[i] {
+			kept = append(kept, msg)
+		}
+	}
+	return kept
+}
+
+// replayableMessages filters out messages that should not be sent back to the model
+// and puts the tools of regenerated replies before them.
+func replayableMessages(messages []models.Message) []models.Message {
+	replayable := make([]models.Message, 0, len(messages))
+	for _, msg := range messages {
+		if len(msg.Tools) > 0 {
+			replayable = append(replayable, replayableMessages(msg.Tools)...)
+		}
+		switch msg.Role {
+		case "user", "assistant", "system":
+			if msg.Content != "" || len(msg.Attachments) > 0 {
+				replayable = append(replayable, msg)
+			}
+		case models.MessageRoleToolCall, models.MessageRoleToolResult:
+			if msg.ToolCall != nil {
+				replayable = append(replayable, msg)
+			}
+		}
+	}
+	return replayable
+}
+
+// assembleHistory builds the final conversation: preamble, summary, then messages.
+func assembleHistory(preamble []ChatMessage, summary string, messages []models.Message) []ChatMessage {
+	history := make([]ChatMessage, 0, len(preamble)+len(messages)+1)
+	history = append(history, preamble...)
+	if summary != "" {
+		history = append(history, ChatMessage{Role: "system", Content: "Summary of the earlier conversation:\n" + summary})
+	}
+	// A call without its result, or a result without its call, would be rejected by
+	// the model, so tool messages are only kept in pairs
+	calls := make(map[string]bool)
+	results := make(map[string]bool)
+	for _, msg := range messages {
+		switch msg.Role {
+		case models.MessageRoleToolCall:
+			calls[msg.ToolCall.ID] = true
+		case models.MessageRoleToolResult:
+			results[msg.ToolCall.ID] = true
+		}
+	}
+	for _, msg := range messages {
+		if msg.Role == models.MessageRoleToolCall || msg.Role == models.MessageRoleToolResult {
+			if !calls[msg.ToolCall.ID] || !results[msg.ToolCall.ID] {
+				continue
+			}
+		}
+		history = append(history, toChatMessage(msg))
+	}
+	return history
+}
+
+// toChatMessage converts a stored message for replay. Reasoning is never sent back
+// to the model; older replies may still have it inline in Content.
+func toChatMessage(msg models.Message) ChatMessage {
+	switch msg.Role {
+	case "assistant":
+		return ChatMessage{Role: msg.Role, Content: StripReasoning(msg.Content)}
+	case models.MessageRoleToolCall:
+		return ChatMessage{Role: "assistant", ToolCalls: []models.ToolCall{*msg.ToolCall}}
+	case models.MessageRoleToolResult:
+		return ChatMessage{Role: "tool", Content: msg.Content, ToolCallID: msg.ToolCall.ID, ToolName: msg.ToolCall.Name}
+	}
+	chatMessage := ChatMessage{Role: msg.Role, Content: msg.Content}
+	for _, attachment := range msg.Attachments {
+		switch attachment.Kind {
+		case models.AttachmentKindImage:
+			chatMessage.Images = append(chatMessage.Images, ChatImage{ID: attachment.ID, ContentType: attachment.ContentType})
+		case models.AttachmentKindFile:
+			chatMessage.Files = append(chatMessage.Files, ChatFile{ID: attachment.ID, Name: attachment.Name})
+		}
+	}
+	return chatMessage
+}
+
+func totalTokens(messages []models.Message) int {
+	total := 0
+	for _, msg := range messages {
+		total += EstimateMessageTokens(msg)
+	}
+	return total
+}
+
+func indexOfMessage(messages []models.Message, id primitive.ObjectID) int {
+	for i, msg := range messages {
+		if msg.ID == id {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,402 @@
+// services/context_manager_test.go
+
+package services
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ashuthe1/localmind/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// stubLLM answers every Generate call with Reply and records the prompts.
+type stubLLM struct {
+	Reply   string
+	Prompts []string
+}
+
+func (s *stubLLM) Name() string         { return "stub" }
+func (s *stubLLM) DefaultModel() string { return "stub-model" }
+
+func (s *stubLLM) StreamChat(ctx context.Context, req ChatRequest, sendChunk func(chunk string) error) (*ChatResult, error) {
+	return &ChatResult{Content: s.Reply}, sendChunk(s.Reply)
+}
+
+func (s *stubLLM) Generate(ctx context.Context, model string, prompt string) (string, error) {
+	s.Prompts = append(s.Prompts, prompt)
+	return s.Reply, nil
+}
+
+func (s *stubLLM) ListModels(ctx context.Context) ([]ModelInfo, error) { return nil, nil }
+func (s *stubLLM) Health(ctx context.Context) error                    { return nil }
+
+// stubSummaryStore records the saved summaries by the hex ID of their last message.
+type stubSummaryStore map[string]string
+
+func (s stubSummaryStore) SaveChatSummary(chatID primitive.ObjectID, until primitive.ObjectID, summary string) error {
+	s[until.Hex()] = summary
+	return nil
+}
+
+// textTokens is the size of every message built by textMessage, in estimated tokens
+// including the per-message overhead.
+const textTokens = 100 + messageOverheadTokens
+
+// textMessage returns a message whose content starts with label and is padded to
+// exactly textTokens.
+func textMessage(role string, label string) models.Message {
+	return models.Message{
+		ID:      primitive.NewObjectID(),
+		Role:    role,
+		Content: label + strings.Repeat(".", 400-len(label)),
+	}
+}
+
+// conversation returns alternating user and assistant messages labelled m1, m2, ...,
+// each the reply to the previous one.
+func conversation(n int) []models.Message {
+	messages := make([]models.Message, n)
+	for i := range messages {
+		role := "user"
+		if i%2 == 1 {
+			role = "assistant"
+		}
+		messages[i] = textMessage(role, "m"+string(rune('1'+i)))
+		if i > 0 {
+			messages[i].ParentID = messages[i-1].ID
+		}
+	}
+	return messages
+}
+
+// labels returns the label of every history message, or its role when it has no text.
+func labels(history []ChatMessage) []string {
+	out := make([]string, len(history))
+	for i, msg := range history {
+		out[i] = strings.TrimRight(msg.Content, ".")
+		if out[i] == "" {
+			out[i] = msg.Role
+		}
+	}
+	return out
+}
+
+func newTestContextManager(strategy string, budget int, llm LLMProvider, store SummaryStore) *ContextManager {
+	return NewContextManager(store, llm, strategy, budget, nil, 0)
+}
+
+func TestBuildContextStrategies(t *testing.T) {
+	pinned := func(messages []models.Message, indexes ...int) []models.Message {
+		for _, i := range indexes {
+			messages[i].Pinned = true
+		}
+		return messages
+	}
+
+	withTools := func(messages []models.Message) []models.Message {
+		call := models.ToolCall{ID: "c1", Name: "calculator"}
+		messages[len(messages)-1].Tools = []models.Message{
+			{ID: primitive.NewObjectID(), Role: models.MessageRoleToolCall, ToolCall: &call},
+			{ID: primitive.NewObjectID(), Role: models.MessageRoleToolResult, Content: "42", ToolCall: &call},
+		}
+		return messages
+	}
+
+	tests := []struct {
+		name     string
+		strategy string
+		budget   int
+		messages []models.Message
+		preamble []ChatMessage
+		want     []string
+	}{
+		{
+			name:     "everything fits",
+			strategy: "drop_oldest",
+			budget:   1000,
+			messages: conversation(3),
+			want:     []string{"m1", "m2", "m3"},
+		},
+		{
+			name:     "drop oldest",
+			strategy: "drop_oldest",
+			budget:   2*textTokens + 10,
+			messages: conversation(5),
+			want:     []string{"m4", "m5"},
+		},
+		{
+			name:     "latest message kept over budget",
+			strategy: "drop_oldest",
+			budget:   minContextBudget,
+			messages: []models.Message{textMessage("user", "short"), {ID: primitive.NewObjectID(), Role: "user", Content: strings.Repeat("x", 4*minContextBudget)}},
+			want:     []string{strings.Repeat("x", 4*minContextBudget)},
+		},
+		{
+			name:     "preamble counts against the budget",
+			strategy: "drop_oldest",
+			budget:   3*textTokens + 10,
+			messages: conversation(5),
+			preamble: []ChatMessage{{Role: "system", Content: strings.Repeat("s", 400)}},
+			want:     []string{strings.Repeat("s", 400), "m4", "m5"},
+		},
+		{
+			name:     "unknown strategy drops oldest",
+			strategy: "newest",
+			budget:   2*textTokens + 10,
+			messages: conversation(4),
+			want:     []string{"m3", "m4"},
+		},
+		{
+			name:     "pinned messages are kept",
+			strategy: "pinned",
+			budget:   3*textTokens + 10,
+			messages: pinned(conversation(6), 0),
+			want:     []string{"m1", "m5", "m6"},
+		},
+		{
+			name:     "pinned messages may exceed the budget",
+			strategy: "pinned",
+			budget:   2*textTokens + 10,
+			messages: pinned(conversation(5), 0, 2),
+			want:     []string{"m1", "m3", "m5"},
+		},
+		{
+			name:     "newest unpinned stop at the first that does not fit",
+			strategy: "pinned",
+			budget:   3*textTokens + 10,
+			messages: pinned(conversation(5), 3),
+			want:     []string{"m3", "m4", "m5"},
+		},
+		{
+			name:     "unreplayable messages are skipped",
+			strategy: "drop_oldest",
+			budget:   1000,
+			messages: []models.Message{
+				textMessage("user", "m1"),
+				{ID: primitive.NewObjectID(), Role: "assistant"},
+				{ID: primitive.NewObjectID(), Role: models.MessageRoleToolCall},
+				{ID: primitive.NewObjectID(), Role: "note", Content: "internal"},
+				textMessage("assistant", "m2"),
+			},
+			want: []string{"m1", "m2"},
+		},
+		{
+			name:     "tools of a regenerated reply are replayed before it",
+			strategy: "drop_oldest",
+			budget:   1000,
+			messages: withTools(conversation(2)),
+			want:     []string{"m1", "assistant", "42", "m2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestContextManager(tt.strategy, tt.budget, &stubLLM{}, stubSummaryStore{})
+			chat := &models.Chat{ID: primitive.NewObjectID(), Messages: tt.messages}
+			history := m.BuildContext(context.Background(), chat, tt.preamble, "model", 0)
+			if got := labels(history); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("history = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContextBudget(t *testing.T) {
+	m := &ContextManager{DefaultBudget: 4096, ModelBudgets: map[string]int{"big": 32768}, ReserveTokens: 512}
+	tests := []struct {
+		model  string
+		numCtx int
+		want   int
+	}{
+		{"small", 0, 4096 - 512},
+		{"big", 0, 32768 - 512},
+		{"big", 8192, 8192 - 512},
+		{"small", 600, minContextBudget},
+	}
+	for _, tt := range tests {
+		if got := m.Budget(tt.model, tt.numCtx); got != tt.want {
+			t.Errorf("Budget(%q, %d) = %d, want %d", tt.model, tt.numCtx, got, tt.want)
+		}
+	}
+}
+
+func TestAssembleHistory(t *testing.T) {
+	call := func(id string) models.Message {
+		return models.Message{ID: primitive.NewObjectID(), Role: models.MessageRoleToolCall, ToolCall: &models.ToolCall{ID: id, Name: "calculator"}}
+	}
+	result := func(id string, content string) models.Message {
+		return models.Message{ID: primitive.NewObjectID(), Role: models.MessageRoleToolResult, Content: content, ToolCall: &models.ToolCall{ID: id, Name: "calculator"}}
+	}
+	user := models.Message{Role: "user", Content: "2+2?"}
+	answer := models.Message{Role: "assistant", Content: "<think>easy</think>\n\n4"}
+
+	tests := []struct {
+		name     string
+		summary  string
+		messages []models.Message
+		want     []ChatMessage
+	}{
+		{
+			name:     "reasoning is stripped",
+			messages: []models.Message{user, answer},
+			want:     []ChatMessage{{Role: "system", Content: "preamble"}, {Role: "user", Content: "2+2?"}, {Role: "assistant", Content: "4"}},
+		},
+		{
+			name:     "summary follows the preamble",
+			summary:  "They said hi.",
+			messages: []models.Message{user},
+			want: []ChatMessage{
+				{Role: "system", Content: "preamble"},
+				{Role: "system", Content: "Summary of the earlier conversation:\nThey said hi."},
+				{Role: "user", Content: "2+2?"},
+			},
+		},
+		{
+			name:     "tool call and result pair",
+			messages: []models.Message{user, call("c1"), result("c1", "4"), answer},
+			want: []ChatMessage{
+				{Role: "system", Content: "preamble"},
+				{Role: "user", Content: "2+2?"},
+				{Role: "assistant", ToolCalls: []models.ToolCall{{ID: "c1", Name: "calculator"}}},
+				{Role: "tool", Content: "4", ToolCallID: "c1", ToolName: "calculator"},
+				{Role: "assistant", Content: "4"},
+			},
+		},
+		{
+			name:     "result whose call was trimmed",
+			messages: []models.Message{result("c1", "4"), call("c2"), result("c2", "5"), answer},
+			want: []ChatMessage{
+				{Role: "system", Content: "preamble"},
+				{Role: "assistant", ToolCalls: []models.ToolCall{{ID: "c2", Name: "calculator"}}},
+				{Role: "tool", Content: "5", ToolCallID: "c2", ToolName: "calculator"},
+				{Role: "assistant", Content: "4"},
+			},
+		},
+		{
+			name:     "call without a result",
+			messages: []models.Message{user, call("c1")},
+			want:     []ChatMessage{{Role: "system", Content: "preamble"}, {Role: "user", Content: "2+2?"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := assembleHistory([]ChatMessage{{Role: "system", Content: "preamble"}}, tt.summary, tt.messages)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("history = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildContextTrimsToolPairs(t *testing.T) {
+	messages := conversation(3)
+	c := models.Message{ID: primitive.NewObjectID(), Role: models.MessageRoleToolCall, ToolCall: &models.ToolCall{ID: "c1", Name: "calculator"}}
+	r := textMessage(models.MessageRoleToolResult, "result")
+	r.ToolCall = c.ToolCall
+	messages = append(messages, c, r, textMessage("user", "m4"), textMessage("assistant", "m5"))
+
+	// The budget fits the result and the newer messages, but not the call
+	m := newTestContextManager("drop_oldest", 3*textTokens+2, &stubLLM{}, stubSummaryStore{})
+	history := m.BuildContext(context.Background(), &models.Chat{Messages: messages}, nil, "model", 0)
+	if got, want := labels(history), []string{"m4", "m5"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("history = %q, want %q", got, want)
+	}
+}
+
+func TestBuildContextSummarize(t *testing.T) {
+	llm := &stubLLM{Reply: "<think>hm</think>\nEarlier they talked"}
+	store := stubSummaryStore{}
+	m := newTestContextManager("summarize", 4*textTokens+10, llm, store)
+	chat := &models.Chat{ID: primitive.NewObjectID(), Messages: conversation(4)}
+
+	// Everything fits, so nothing is summarised
+	history := m.BuildContext(context.Background(), chat, nil, "model", 0)
+	if got, want := labels(history), []string{"m1", "m2", "m3", "m4"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("history = %q, want %q", got, want)
+	}
+	if len(llm.Prompts) != 0 {
+		t.Fatalf("summarised %d times, want 0", len(llm.Prompts))
+	}
+
+	// Overflowing folds all but the newest half of the budget into a summary
+	chat.Messages = conversation(6)
+	history = m.BuildContext(context.Background(), chat, nil, "model", 0)
+	want := []string{"Summary of the earlier conversation:\nEarlier they talked", "m5", "m6"}
+	if got := labels(history); !reflect.DeepEqual(got, want) {
+		t.Fatalf("history = %q, want %q", got, want)
+	}
+	if len(llm.Prompts) != 1 || !strings.Contains(llm.Prompts[0], "user: m1") || !strings.Contains(llm.Prompts[0], "assistant: m4") {
+		t.Fatalf("prompts = %q", llm.Prompts)
+	}
+	until := chat.Messages[3].ID.Hex()
+	if store[until] != "Earlier they talked" || chat.Summaries[until] != "Earlier they talked" {
+		t.Fatalf("saved summaries = %v, chat summaries = %v", store, chat.Summaries)
+	}
+
+	// The next turn reuses the summary
+	next := textMessage("user", "m7")
+	next.ParentID = chat.Messages[5].ID
+	chat.Messages = append(chat.Messages, next)
+	history = m.BuildContext(context.Background(), chat, nil, "model", 0)
+	if got, want := labels(history), append(want, "m7"); !reflect.DeepEqual(got, want) {
+		t.Errorf("history = %q, want %q", got, want)
+	}
+	if len(llm.Prompts) != 1 {
+		t.Errorf("summarised %d times, want 1", len(llm.Prompts))
+	}
+}
+
+func TestBuildContextSummarizeBranches(t *testing.T) {
+	llm := &stubLLM{Reply: "summary"}
+	store := stubSummaryStore{}
+	m := newTestContextManager("summarize", 4*textTokens+10, llm, store)
+
+	// Two long branches forking after the first message
+	a := conversation(6)
+	b := conversation(6)
+	b[0] = a[0]
+	b[1].ParentID = a[0].ID
+	chat := &models.Chat{ID: primitive.NewObjectID(), Messages: append(a, b[1:]...)}
+
+	// Every turn loads the chat with the summaries saved so far
+	turn := func(leaf primitive.ObjectID) {
+		chat.ActiveLeafID = leaf
+		chat.Summaries = make(map[string]string)
+		for until, summary := range store {
+			chat.Summaries[until] = summary
+		}
+		m.BuildContext(context.Background(), ActiveBranch(chat), nil, "model", 0)
+	}
+	turn(a[5].ID)
+	turn(b[5].ID)
+	if len(llm.Prompts) != 2 || len(store) != 2 {
+		t.Fatalf("summarised %d times with %d summaries saved, want 2 and 2", len(llm.Prompts), len(store))
+	}
+
+	// Switching back and forth reuses each branch's summary
+	turn(a[5].ID)
+	turn(b[5].ID)
+	if len(llm.Prompts) != 2 {
+		t.Errorf("summarised %d times after switching branches, want 2", len(llm.Prompts))
+	}
+}
+
+func TestBuildContextSummaryOfRemovedMessages(t *testing.T) {
+	llm := &stubLLM{Reply: "new summary"}
+	m := newTestContextManager("summarize", 4*textTokens+10, llm, stubSummaryStore{})
+
+	// A summary whose last message is not on the path is ignored
+	chat := &models.Chat{
+		ID:        primitive.NewObjectID(),
+		Messages:  conversation(3),
+		Summaries: map[string]string{primitive.NewObjectID().Hex(): "stale summary"},
+	}
+	history := m.BuildContext(context.Background(), chat, nil, "model", 0)
+	if got, want := labels(history), []string{"m1", "m2", "m3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("history = %q, want %q", got, want)
+	}
+}
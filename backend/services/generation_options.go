@@ -0,0 +1,76 @@
+// services/generation_options.go
+
+package services
+
+import (
+	"fmt"
+
+	"github.com/ashuthe1/localmind/models"
+)
+
+const maxStopSequences = 8
+
+// ValidateOptions checks that every set generation option is within range.
+func ValidateOptions(o *models.GenerationOptions) error {
+	if o == nil {
+		return nil
+	}
+	if o.Temperature != nil && (*o.Temperature < 0 || *o.Temperature > 2) {
+		return fmt.Errorf("temperature must be between 0 and 2")
+	}
+	if o.TopP != nil && (*o.TopP <= 0 || *o.TopP > 1) {
+		return fmt.Errorf("topP must be greater than 0 and at most 1")
+	}
+	if o.NumCtx != nil && *o.NumCtx < minContextBudget {
+		return fmt.Errorf("numCtx must be at least %d", minContextBudget)
+	}
+	if o.Seed != nil && *o.Seed < 0 {
+		return fmt.Errorf("seed must not be negative")
+	}
+	if o.MaxTokens != nil && *o.MaxTokens < 1 {
+		return fmt.Errorf("maxTokens must be at least 1")
+	}
+	if len(o.Stop) > maxStopSequences {
+		return fmt.Errorf("at most %d stop sequences are allowed", maxStopSequences)
+	}
+	for _, stop := range o.Stop {
+		if stop == "" {
+			return fmt.Errorf("stop sequences must not be empty")
+		}
+	}
+	return nil
+}
+
+// MergeOptions combines option layers field by field; earlier layers win. It returns
+// nil when no layer sets anything.
+func MergeOptions(layers ...*models.GenerationOptions) *models.GenerationOptions {
+	merged := &models.GenerationOptions{}
+	empty := true
+	for _, layer := range layers {
+		if layer == nil {
+			continue
+		}
+		if merged.Temperature == nil && layer.Temperature != nil {
+			merged.Temperature, empty = layer.Temperature, false
+		}
+		if merged.TopP == nil && layer.TopP != nil {
+			merged.TopP, empty = layer.TopP, false
+		}
+		if merged.NumCtx == nil && layer.NumCtx != nil {
+			merged.NumCtx, empty = layer.NumCtx, false
+		}
+		if merged.Seed == nil && layer.Seed != nil {
+			merged.Seed, empty = layer.Seed, false
+		}
+		if merged.Stop == nil && layer.Stop != nil {
+			merged.Stop, empty = layer.Stop, false
+		}
+		if merged.MaxTokens == nil && layer.MaxTokens != nil {
+			merged.MaxTokens, empty = layer.MaxTokens, false
+		}
+	}
+	if empty {
+		return nil
+	}
+	return merged
+}
@@ -0,0 +1,225 @@
+// services/generation_scheduler.go
+
+package services
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/ashuthe1/localmind/logger"
+)
+
+// QueueMode is the order in which waiting generations get a free slot.
+type QueueMode string
+
+const (
+	// QueueModeFIFO serves generations in the order they arrived, users taking turns.
+	QueueModeFIFO QueueMode = "fifo"
+	// QueueModePriority serves higher priority generations first, then like QueueModeFIFO.
+	QueueModePriority QueueMode = "priority"
+)
+
+// Generation priorities, used in QueueModePriority.
+const (
+	PriorityBackground  = 0 // Work nobody is waiting on, like chat titles
+	PriorityInteractive = 10
+)
+
+// GenerationRequest describes a generation waiting for a slot.
+type GenerationRequest struct {
+	Model    string
+	User     string // Generations of different users take turns
+	Priority int
+}
+
+// GenerationScheduler limits how many generations run at once for each model and
+// queues the rest. Within a priority, users take turns: every queued generation is
+// given its user's next turn, so one user queueing many requests does not hold up
+// the others.
+type GenerationScheduler struct {
+	Mode QueueMode
+	// MaxConcurrent is the default number of generations per model. Zero or less is unlimited.
+	MaxConcurrent int
+	// ModelLimits overrides MaxConcurrent for specific models.
+	ModelLimits map[string]int
+
+	mu     sync.Mutex
+	seq    uint64
+	queues map[string]*modelQueue
+}
+
+// modelQueue is the state of one model's slots.
+type modelQueue struct {
+	running int
+	waiting []*queuedGeneration
+	// turn is the turn of the last started generation, userTurns the last turn given to each user
+	turn      uint64
+	userTurns map[string]uint64
+}
+
+type queuedGeneration struct {
+	req      GenerationRequest
+	seq      uint64
+	turn     uint64
+	position int
+	ready    chan struct{}
+	// positions holds the latest queue position not yet reported to the waiter
+	positions chan int
+}
+
+func NewGenerationScheduler(mode string, maxConcurrent int, modelLimits map[string]int) *GenerationScheduler {
+	m := QueueMode(mode)
+	switch m {
+	case QueueModeFIFO, QueueModePriority:
+	default:
+		logger.Log.Warnf("Unknown generation queue mode %q, using %q", mode, QueueModeFIFO)
+		m = QueueModeFIFO
+	}
+
+	return &GenerationScheduler{
+		Mode:          m,
+		MaxConcurrent: maxConcurrent,
+		ModelLimits:   modelLimits,
+		queues:        make(map[string]*modelQueue),
+	}
+}
+
+// Acquire waits until the request may run and returns the function that frees its
+// slot, which must be called once the generation has finished. While it waits,
+// onQueued is called with the 1-based queue position whenever it changes. It
+// returns ctx.Err() if ctx is cancelled first.
+func (s *GenerationScheduler) Acquire(ctx context.Context, req GenerationRequest, onQueued func(position int)) (func(), error) {
+	s.mu.Lock()
+	q := s.queue(req.Model)
+	s.seq++
+	g := &queuedGeneration{
+		req:       req,
+		seq:       s.seq,
+		turn:      q.nextTurn(req.User),
+		ready:     make(chan struct{}),
+		positions: make(chan int, 1),
+	}
+	q.waiting = append(q.waiting, g)
+	s.dispatch(req.Model, q)
+	s.mu.Unlock()
+
+	for {
+		select {
+		case <-g.ready:
+			return s.releaseFunc(req), nil
+		case position := <-g.positions:
+			if onQueued != nil {
+				onQueued(position)
+			}
+		case <-ctx.Done():
+			s.mu.Lock()
+			removed := q.remove(g)
+			if removed {
+				s.dispatch(req.Model, q)
+			}
+			s.mu.Unlock()
+			if !removed {
+				// The slot was granted while the request was being cancelled
+				s.releaseFunc(req)()
+			}
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// releaseFunc frees a slot of req's model and hands it to the next waiting generation.
+func (s *GenerationScheduler) releaseFunc(req GenerationRequest) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			q := s.queues[req.Model]
+			q.running--
+			s.dispatch(req.Model, q)
+		})
+	}
+}
+
+// Limit returns the number of generations that may run at once for a model.
+func (s *GenerationScheduler) Limit(model string) int {
+	if limit, ok := s.ModelLimits[model]; ok {
+		return limit
+	}
+	return s.MaxConcurrent
+}
+
+func (s *GenerationScheduler) queue(model string) *modelQueue {
+	q, ok := s.queues[model]
+	if !ok {
+		q = &modelQueue{userTurns: make(map[string]uint64)}
+		s.queues[model] = q
+	}
+	return q
+}
+
+// dispatch starts waiting generations while the model has free slots and reports the
+// new positions of the rest. s.mu must be held.
+func (s *GenerationScheduler) dispatch(model string, q *modelQueue) {
+	sort.SliceStable(q.waiting, func(i, j int) bool {
+		a, b := q.waiting[i], q.waiting[j]
+		if s.Mode == QueueModePriority && a.req.Priority != b.req.Priority {
+			return a.req.Priority > b.req.Priority
+		}
+		if a.turn != b.turn {
+			return a.turn < b.turn
+		}
+		return a.seq < b.seq
+	})
+
+	limit := s.Limit(model)
+	for len(q.waiting) > 0 && (limit <= 0 || q.running < limit) {
+		g := q.waiting[0]
+		q.waiting = q.waiting[1:]
+		q.running++
+		if g.turn > q.turn {
+			q.turn = g.turn
+		}
+		close(g.ready)
+	}
+
+	for i, g := range q.waiting {
+		if g.position == i+1 {
+			continue
+		}
+		g.position = i + 1
+		// Replace a position the waiter has not read yet
+		select {
+		case <-g.positions:
+		default:
+		}
+		g.positions <- g.position
+	}
+
+	if q.running == 0 && len(q.waiting) == 0 {
+		delete(s.queues, model)
+	}
+}
+
+// nextTurn gives a user the turn after both their last one and the last started
+// generation, so users who have been waiting go first.
+func (q *modelQueue) nextTurn(user string) uint64 {
+	turn := q.userTurns[user]
+	if q.turn > turn {
+		turn = q.turn
+	}
+	turn++
+	q.userTurns[user] = turn
+	return turn
+}
+
+func (q *modelQueue) remove(g *queuedGeneration) bool {
+	for i, waiting := range q.waiting {
+		if waiting == g {
+			q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
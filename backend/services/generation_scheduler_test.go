@@ -0,0 +1,228 @@
+// services/generation_scheduler_test.go
+
+package services
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// waitQueued waits until n generations are waiting for model.
+func waitQueued(t *testing.T, s *GenerationScheduler, model string, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		s.mu.Lock()
+		q, ok := s.queues[model]
+		waiting := 0
+		if ok {
+			waiting = len(q.waiting)
+		}
+		s.mu.Unlock()
+		if waiting == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d queued generations", n)
+}
+
+func TestGenerationSchedulerOrder(t *testing.T) {
+	type queued struct {
+		name string
+		req  GenerationRequest
+	}
+	interactive := func(name, user string) queued {
+		return queued{name, GenerationRequest{Model: "m", User: user, Priority: PriorityInteractive}}
+	}
+	background := func(name, user string) queued {
+		return queued{name, GenerationRequest{Model: "m", User: user, Priority: PriorityBackground}}
+	}
+
+	tests := []struct {
+		name   string
+		mode   string
+		queued []queued
+		want   []string
+	}{
+		{
+			name:   "fifo for one user",
+			mode:   "fifo",
+			queued: []queued{interactive("a1", "a"), interactive("a2", "a"), interactive("a3", "a")},
+			want:   []string{"a1", "a2", "a3"},
+		},
+		{
+			name:   "users take turns",
+			mode:   "fifo",
+			queued: []queued{interactive("a1", "a"), interactive("a2", "a"), interactive("a3", "a"), interactive("b1", "b"), interactive("c1", "c")},
+			want:   []string{"a1", "b1", "c1", "a2", "a3"},
+		},
+		{
+			name:   "fifo ignores priority",
+			mode:   "fifo",
+			queued: []queued{background("title", "t"), interactive("a1", "a")},
+			want:   []string{"title", "a1"},
+		},
+		{
+			name:   "priority first",
+			mode:   "priority",
+			queued: []queued{background("title", "t"), interactive("a1", "a"), interactive("a2", "a"), interactive("b1", "b")},
+			want:   []string{"a1", "b1", "a2", "title"},
+		},
+		{
+			name:   "unknown mode falls back to fifo",
+			mode:   "random",
+			queued: []queued{background("title", "t"), interactive("a1", "a")},
+			want:   []string{"title", "a1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewGenerationScheduler(tt.mode, 1, nil)
+			ctx := context.Background()
+
+			// Hold the only slot until everything is queued
+			release, err := s.Acquire(ctx, GenerationRequest{Model: "m", User: "blocker"}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var mu sync.Mutex
+			var order []string
+			var wg sync.WaitGroup
+			for i, q := range tt.queued {
+				wg.Add(1)
+				go func(q queued) {
+					defer wg.Done()
+					done, err := s.Acquire(ctx, q.req, nil)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					mu.Lock()
+					order = append(order, q.name)
+					mu.Unlock()
+					done()
+				}(q)
+				waitQueued(t, s, "m", i+1)
+			}
+			release()
+			wg.Wait()
+
+			if !reflect.DeepEqual(order, tt.want) {
+				t.Errorf("order = %q, want %q", order, tt.want)
+			}
+			if len(s.queues) != 0 {
+				t.Errorf("%d model queues left behind", len(s.queues))
+			}
+		})
+	}
+}
+
+func TestGenerationSchedulerCancel(t *testing.T) {
+	s := NewGenerationScheduler("fifo", 1, nil)
+	release, err := s.Acquire(context.Background(), GenerationRequest{Model: "m", User: "a"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := make(chan error)
+	go func() {
+		_, err := s.Acquire(ctx, GenerationRequest{Model: "m", User: "b"}, nil)
+		cancelled <- err
+	}()
+	waitQueued(t, s, "m", 1)
+
+	positions := make(chan int, 10)
+	acquired := make(chan func())
+	go func() {
+		done, err := s.Acquire(context.Background(), GenerationRequest{Model: "m", User: "c"}, func(position int) {
+			positions <- position
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		acquired <- done
+	}()
+	waitQueued(t, s, "m", 2)
+	if got := <-positions; got != 2 {
+		t.Errorf("first position = %d, want 2", got)
+	}
+
+	// Cancelling the first waiter moves the second up
+	cancel()
+	if err := <-cancelled; !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled Acquire = %v, want context.Canceled", err)
+	}
+	if got := <-positions; got != 1 {
+		t.Errorf("position after cancel = %d, want 1", got)
+	}
+
+	release()
+	release() // Releasing twice frees the slot only once
+	done := <-acquired
+	s.mu.Lock()
+	running := s.queues["m"].running
+	s.mu.Unlock()
+	if running != 1 {
+		t.Errorf("running = %d, want 1", running)
+	}
+	done()
+	if len(s.queues) != 0 {
+		t.Errorf("%d model queues left behind", len(s.queues))
+	}
+}
+
+func TestGenerationSchedulerLimits(t *testing.T) {
+	s := NewGenerationScheduler("fifo", 2, map[string]int{"big": 1, "free": 0})
+	ctx := context.Background()
+
+	tests := []struct {
+		model string
+		limit int // Zero is unlimited
+	}{
+		{"small", 2},
+		{"big", 1},
+		{"free", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			if got := s.Limit(tt.model); got != tt.limit {
+				t.Errorf("Limit = %d, want %d", got, tt.limit)
+			}
+
+			started := tt.limit
+			if started == 0 {
+				started = 5
+			}
+			var releases []func()
+			for i := 0; i < started; i++ {
+				quick, cancel := context.WithTimeout(ctx, time.Second)
+				release, err := s.Acquire(quick, GenerationRequest{Model: tt.model}, nil)
+				cancel()
+				if err != nil {
+					t.Fatalf("generation %d did not start: %v", i+1, err)
+				}
+				releases = append(releases, release)
+			}
+
+			if tt.limit > 0 {
+				blocked, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+				_, err := s.Acquire(blocked, GenerationRequest{Model: tt.model}, nil)
+				cancel()
+				if !errors.Is(err, context.DeadlineExceeded) {
+					t.Errorf("generation over the limit = %v, want it to wait", err)
+				}
+			}
+			for _, release := range releases {
+				release()
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+// services/generation_tracker.go
+
+package services
+
+import (
+	"context"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// GenerationTracker keeps the cancel functions of running generations so they can
+// be stopped by ID, independently of the client connection.
+type GenerationTracker struct {
+	mu      sync.Mutex
+	running map[string]context.CancelFunc
+}
+
+func NewGenerationTracker() *GenerationTracker {
+	return &GenerationTracker{
+		running: make(map[string]context.CancelFunc),
+	}
+}
+
+// Start registers a new generation. The returned context is cancelled when parent is,
+// or when Cancel is called with the returned ID. done must be called once the
+// generation has finished.
+func (t *GenerationTracker) Start(parent context.Context) (string, context.Context, func()) {
+	id := primitive.NewObjectID().Hex()
+	ctx, cancel := context.WithCancel(parent)
+
+	t.mu.Lock()
+	t.running[id] = cancel
+	t.mu.Unlock()
+
+	done := func() {
+		t.mu.Lock()
+		delete(t.running, id)
+		t.mu.Unlock()
+		cancel()
+	}
+	return id, ctx, done
+}
+
+// Cancel stops a running generation. It reports false if no generation has that ID.
+func (t *GenerationTracker) Cancel(id string) bool {
+	t.mu.Lock()
+	cancel, ok := t.running[id]
+	delete(t.running, id)
+	t.mu.Unlock()
+
+	if ok {
+		cancel()
+	}
+	return ok
+}
@@ -0,0 +1,339 @@
+// services/json_schema.go
+
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxSchemaErrors bounds the validation errors reported back to the model.
+const maxSchemaErrors = 10
+
+// schemaTypes are the values the "type" keyword accepts.
+var schemaTypes = map[string]bool{
+	"object": true, "array": true, "string": true, "number": true, "integer": true, "boolean": true, "null": true,
+}
+
+// CheckSchema reports whether schema is a JSON schema ValidateJSON understands. It
+// covers type, enum, const, properties, required, additionalProperties, items,
+// the length, size and range keywords, pattern, anyOf, oneOf and allOf.
+func CheckSchema(schema []byte) error {
+	var root interface{}
+	if err := json.Unmarshal(schema, &root); err != nil {
+		return fmt.Errorf("schema is not valid JSON: %w", err)
+	}
+	if _, ok := root.(map[string]interface{}); !ok {
+		return errors.New("schema must be a JSON object")
+	}
+	return checkSchemaNode(root, "schema")
+}
+
+func checkSchemaNode(node interface{}, path string) error {
+	if _, ok := node.(bool); ok {
+		return nil
+	}
+	schema, ok := node.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("%s must be an object", path)
+	}
+	if _, ok := schema["$ref"]; ok {
+		return fmt.Errorf("%s: $ref is not supported", path)
+	}
+
+	switch t := schema["type"].(type) {
+	case nil:
+	case string:
+		if !schemaTypes[t] {
+			return fmt.Errorf("%s: unknown type %q", path, t)
+		}
+	case []interface{}:
+		for _, item := range t {
+			if name, ok := item.(string); !ok || !schemaTypes[name] {
+				return fmt.Errorf("%s: unknown type %v", path, item)
+			}
+		}
+	default:
+		return fmt.Errorf("%s: type must be a string or an array", path)
+	}
+
+	if pattern, ok := schema["pattern"].(string); ok {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("%s: invalid pattern: %w", path, err)
+		}
+	}
+	if properties, ok := schema["properties"].(map[string]interface{}); ok {
+		for name, property := range properties {
+			if err := checkSchemaNode(property, path+".properties."+name); err != nil {
+				return err
+			}
+		}
+	}
+	for _, keyword := range []string{"items", "additionalProperties"} {
+		if sub, ok := schema[keyword]; ok {
+			if err := checkSchemaNode(sub, path+"."+keyword); err != nil {
+				return err
+			}
+		}
+	}
+	for _, keyword := range []string{"anyOf", "oneOf", "allOf"} {
+		if list, ok := schema[keyword].([]interface{}); ok {
+			for i, sub := range list {
+				if err := checkSchemaNode(sub, fmt.Sprintf("%s.%s[%d]", path, keyword, i)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateJSON checks a decoded JSON value against a schema accepted by CheckSchema
+// and returns the violations, each prefixed with the path of the offending value.
+func ValidateJSON(schema []byte, value interface{}) []string {
+	var root interface{}
+	if err := json.Unmarshal(schema, &root); err != nil {
+		return []string{"invalid schema: " + err.Error()}
+	}
+	v := &schemaValidator{}
+	v.validate(root, value, "$")
+	return v.errors
+}
+
+type schemaValidator struct {
+	errors []string
+}
+
+func (v *schemaValidator) fail(path string, format string, args ...interface{}) {
+	if len(v.errors) < maxSchemaErrors {
+		v.errors = append(v.errors, path+": "+fmt.Sprintf(format, args...))
+	}
+}
+
+// matches reports whether value satisfies schema without recording errors.
+func matches(schema interface{}, value interface{}) bool {
+	v := &schemaValidator{}
+	v.validate(schema, value, "$")
+	return len(v.errors) == 0
+}
+
+func (v *schemaValidator) validate(node interface{}, value interface{}, path string) {
+	if allowed, ok := node.(bool); ok {
+		if !allowed {
+			v.fail(path, "no value is allowed here")
+		}
+		return
+	}
+	schema, _ := node.(map[string]interface{})
+	if schema == nil {
+		return
+	}
+
+	if !v.checkType(schema, value, path) {
+		return
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, option := range enum {
+			if reflect.DeepEqual(option, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			v.fail(path, "must be one of %s", compactJSON(enum))
+		}
+	}
+	if constant, ok := schema["const"]; ok && !reflect.DeepEqual(constant, value) {
+		v.fail(path, "must be %s", compactJSON(constant))
+	}
+
+	switch value := value.(type) {
+	case map[string]interface{}:
+		v.validateObject(schema, value, path)
+	case []interface{}:
+		v.validateArray(schema, value, path)
+	case string:
+		length := float64(utf8.RuneCountInString(value))
+		if min, ok := schema["minLength"].(float64); ok && length < min {
+			v.fail(path, "must be at least %v characters long", min)
+		}
+		if max, ok := schema["maxLength"].(float64); ok && length > max {
+			v.fail(path, "must be at most %v characters long", max)
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			if re, err := regexp.Compile(pattern); err == nil && !re.MatchString(value) {
+				v.fail(path, "must match the pattern %q", pattern)
+			}
+		}
+	case float64:
+		if min, ok := schema["minimum"].(float64); ok && value < min {
+			v.fail(path, "must be at least %v", min)
+		}
+		if max, ok := schema["maximum"].(float64); ok && value > max {
+			v.fail(path, "must be at most %v", max)
+		}
+		if min, ok := schema["exclusiveMinimum"].(float64); ok && value <= min {
+			v.fail(path, "must be greater than %v", min)
+		}
+		if max, ok := schema["exclusiveMaximum"].(float64); ok && value >= max {
+			v.fail(path, "must be less than %v", max)
+		}
+	}
+
+	if list, ok := schema["allOf"].([]interface{}); ok {
+		for _, sub := range list {
+			v.validate(sub, value, path)
+		}
+	}
+	if list, ok := schema["anyOf"].([]interface{}); ok {
+		matched := false
+		for _, sub := range list {
+			if matches(sub, value) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			v.fail(path, "must match at least one of the anyOf schemas")
+		}
+	}
+	if list, ok := schema["oneOf"].([]interface{}); ok {
+		count := 0
+		for _, sub := range list {
+			if matches(sub, value) {
+				count++
+			}
+		}
+		if count != 1 {
+			v.fail(path, "must match exactly one of the oneOf schemas, matched %d", count)
+		}
+	}
+}
+
+// checkType validates the "type" keyword and reports whether validation should continue.
+func (v *schemaValidator) checkType(schema map[string]interface{}, value interface{}, path string) bool {
+	var types []string
+	switch t := schema["type"].(type) {
+	case string:
+		types = []string{t}
+	case []interface{}:
+		for _, item := range t {
+			if name, ok := item.(string); ok {
+				types = append(types, name)
+			}
+		}
+	default:
+		return true
+	}
+
+	actual := jsonType(value)
+	for _, t := range types {
+		if t == actual || t == "number" && actual == "integer" {
+			return true
+		}
+	}
+	v.fail(path, "expected %s, got %s", strings.Join(types, " or "), actual)
+	return false
+}
+
+func (v *schemaValidator) validateObject(schema map[string]interface{}, value map[string]interface{}, path string) {
+	if required, ok := schema["required"].([]interface{}); ok {
+		for _, name := range required {
+			if key, ok := name.(string); ok {
+				if _, present := value[key]; !present {
+					v.fail(path, "missing required property %q", key)
+				}
+			}
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]interface{})
+	keys := make([]string, 0, len(value))
+	for key := range value {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		childPath := path + "." + key
+		if property, ok := properties[key]; ok {
+			v.validate(property, value[key], childPath)
+			continue
+		}
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				v.fail(path, "unexpected property %q", key)
+			}
+		case map[string]interface{}:
+			v.validate(additional, value[key], childPath)
+		}
+	}
+
+	size := float64(len(value))
+	if min, ok := schema["minProperties"].(float64); ok && size < min {
+		v.fail(path, "must have at least %v properties", min)
+	}
+	if max, ok := schema["maxProperties"].(float64); ok && size > max {
+		v.fail(path, "must have at most %v properties", max)
+	}
+}
+
+func (v *schemaValidator) validateArray(schema map[string]interface{}, value []interface{}, path string) {
+	size := float64(len(value))
+	if min, ok := schema["minItems"].(float64); ok && size < min {
+		v.fail(path, "must have at least %v items", min)
+	}
+	if max, ok := schema["maxItems"].(float64); ok && size > max {
+		v.fail(path, "must have at most %v items", max)
+	}
+	if items, ok := schema["items"]; ok {
+		for i, item := range value {
+			v.validate(items, item, fmt.Sprintf("%s[%d]", path, i))
+		}
+	}
+	if unique, _ := schema["uniqueItems"].(bool); unique {
+		for i := range value {
+			for j := i + 1; j < len(value); j++ {
+				if reflect.DeepEqual(value[i], value[j]) {
+					v.fail(path, "items %d and %d are equal", i, j)
+					return
+				}
+			}
+		}
+	}
+}
+
+// jsonType names the JSON type of a value decoded by encoding/json.
+func jsonType(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if value == math.Trunc(value) && !math.IsInf(value, 0) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func compactJSON(value interface{}) string {
+	data, _ := json.Marshal(value)
+	return string(data)
+}
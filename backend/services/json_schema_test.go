@@ -0,0 +1,176 @@
+// services/json_schema_test.go
+
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheckSchema(t *testing.T) {
+	tests := []struct {
+		schema string
+		err    string // Substring of the error, empty if the schema is valid
+	}{
+		{`{"type":"object","properties":{"name":{"type":"string"}},"required":["name"]}`, ""},
+		{`{"type":["string","null"]}`, ""},
+		{`{"items":true,"additionalProperties":false}`, ""},
+		{`{"anyOf":[{"type":"string"},{"type":"integer"}]}`, ""},
+		{`{"type":"object"`, "schema is not valid JSON"},
+		{`[]`, "schema must be a JSON object"},
+		{`{"type":"text"}`, `schema: unknown type "text"`},
+		{`{"type":["string",1]}`, "schema: unknown type 1"},
+		{`{"type":1}`, "type must be a string or an array"},
+		{`{"pattern":"("}`, "schema: invalid pattern"},
+		{`{"properties":{"a":{"$ref":"#/defs/a"}}}`, "schema.properties.a: $ref is not supported"},
+		{`{"items":{"type":"date"}}`, `schema.items: unknown type "date"`},
+		{`{"oneOf":[{"type":"string"},3]}`, "schema.oneOf[1] must be an object"},
+	}
+
+	for _, tt := range tests {
+		err := CheckSchema([]byte(tt.schema))
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("CheckSchema(%s) = %v, want nil", tt.schema, err)
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("CheckSchema(%s) = %v, want an error containing %q", tt.schema, err, tt.err)
+		}
+	}
+}
+
+func TestValidateJSON(t *testing.T) {
+	person := `{
+		"type": "object",
+		"properties": {
+			"name": {"type": "string", "minLength": 1, "maxLength": 5},
+			"age": {"type": "integer", "minimum": 0, "exclusiveMaximum": 150},
+			"email": {"type": "string", "pattern": "^[^@]+@[^@]+$"},
+			"tags": {"type": "array", "items": {"type": "string"}, "maxItems": 2, "uniqueItems": true}
+		},
+		"required": ["name", "age"],
+		"additionalProperties": false
+	}`
+
+	tests := []struct {
+		name   string
+		schema string
+		value  string
+		want   []string
+	}{
+		{
+			name:   "valid object",
+			schema: person,
+			value:  `{"name":"Ann","age":30,"email":"ann@example.com","tags":["a","b"]}`,
+		},
+		{
+			name:   "missing and unexpected properties",
+			schema: person,
+			value:  `{"nickname":"A"}`,
+			want:   []string{`$: missing required property "name"`, `$: missing required property "age"`, `$: unexpected property "nickname"`},
+		},
+		{
+			name:   "property constraints",
+			schema: person,
+			value:  `{"name":"Annabel","age":150.5,"email":"nope","tags":["a","a","b"]}`,
+			want: []string{
+				"$.age: expected integer, got number",
+				`$.email: must match the pattern "^[^@]+@[^@]+$"`,
+				"$.name: must be at most 5 characters long",
+				"$.tags: must have at most 2 items",
+				"$.tags: items 0 and 1 are equal",
+			},
+		},
+		{
+			name:   "wrong root type",
+			schema: person,
+			value:  `["Ann", 30]`,
+			want:   []string{"$: expected object, got array"},
+		},
+		{
+			name:   "array items",
+			schema: `{"type":"array","items":{"type":"number","minimum":0},"minItems":1}`,
+			value:  `[1, -2, "3"]`,
+			want:   []string{"$[1]: must be at least 0", "$[2]: expected number, got string"},
+		},
+		{
+			name:   "integers are numbers",
+			schema: `{"type":"number","exclusiveMinimum":0}`,
+			value:  `3`,
+		},
+		{
+			name:   "characters, not bytes",
+			schema: `{"type":"string","maxLength":3}`,
+			value:  `"日本語"`,
+		},
+		{
+			name:   "nullable",
+			schema: `{"type":["string","null"]}`,
+			value:  `null`,
+		},
+		{
+			name:   "enum and const",
+			schema: `{"type":"object","properties":{"unit":{"enum":["c","f"]},"version":{"const":2}}}`,
+			value:  `{"unit":"k","version":1}`,
+			want:   []string{`$.unit: must be one of ["c","f"]`, "$.version: must be 2"},
+		},
+		{
+			name:   "additional properties schema",
+			schema: `{"type":"object","additionalProperties":{"type":"integer"},"maxProperties":1}`,
+			value:  `{"a":1,"b":"x"}`,
+			want:   []string{"$.b: expected integer, got string", "$: must have at most 1 properties"},
+		},
+		{
+			name:   "anyOf",
+			schema: `{"anyOf":[{"type":"string"},{"type":"integer"}]}`,
+			value:  `true`,
+			want:   []string{"$: must match at least one of the anyOf schemas"},
+		},
+		{
+			name:   "oneOf matching twice",
+			schema: `{"oneOf":[{"type":"number"},{"type":"integer"}]}`,
+			value:  `1`,
+			want:   []string{"$: must match exactly one of the oneOf schemas, matched 2"},
+		},
+		{
+			name:   "allOf",
+			schema: `{"allOf":[{"type":"string"},{"minLength":2}]}`,
+			value:  `"a"`,
+			want:   []string{"$: must be at least 2 characters long"},
+		},
+		{
+			name:   "false schema",
+			schema: `{"properties":{"secret":false}}`,
+			value:  `{"secret":1}`,
+			want:   []string{"$.secret: no value is allowed here"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := CheckSchema([]byte(tt.schema)); err != nil {
+				t.Fatalf("CheckSchema: %v", err)
+			}
+			var value interface{}
+			if err := json.Unmarshal([]byte(tt.value), &value); err != nil {
+				t.Fatal(err)
+			}
+			if got := ValidateJSON([]byte(tt.schema), value); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ValidateJSON = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateJSONLimitsErrors(t *testing.T) {
+	var value []interface{}
+	for i := 0; i < 2*maxSchemaErrors; i++ {
+		value = append(value, fmt.Sprint(i))
+	}
+	errors := ValidateJSON([]byte(`{"type":"array","items":{"type":"integer"}}`), value)
+	if len(errors) != maxSchemaErrors {
+		t.Errorf("got %d errors, want %d", len(errors), maxSchemaErrors)
+	}
+}
@@ -0,0 +1,220 @@
+// services/llm_provider.go
+
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/ashuthe1/localmind/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	// ErrModelNotFound is returned when the backend does not have the requested model.
+	ErrModelNotFound = errors.New("model not found")
+	// ErrProviderUnavailable is returned when the model backend cannot be reached.
+	ErrProviderUnavailable = errors.New("llm provider unavailable")
+)
+
+// LLMProvider is a local model runtime the handlers can generate responses with.
+type LLMProvider interface {
+	// Name is the key the provider is registered under, e.g. "ollama".
+	Name() string
+	// DefaultModel is used when a request does not name a model.
+	DefaultModel() string
+	// StreamChat streams the reply to a conversation, calling sendChunk for every text fragment.
+	StreamChat(ctx context.Context, req ChatRequest, sendChunk func(chunk string) error) (*ChatResult, error)
+	// Generate returns the full completion for a single prompt.
+	Generate(ctx context.Context, model string, prompt string) (string, error)
+	// ListModels returns the models available to the backend.
+	ListModels(ctx context.Context) ([]ModelInfo, error)
+	// Health returns an error if the backend is not ready to serve requests.
+	Health(ctx context.Context) error
+}
+
+// ModelNameLister is implemented by providers that can list their model names more
+// cheaply than ListModels, which may look up details for every model.
+type ModelNameLister interface {
+	ListModelNames(ctx context.Context) ([]string, error)
+}
+
+// ModelManager is implemented by providers that can install and remove models.
+type ModelManager interface {
+	// ShowModel returns the details of an installed model.
+	ShowModel(ctx context.Context, name string) (*ModelDetails, error)
+	// PullModel downloads a model, reporting progress until it is installed.
+	PullModel(ctx context.Context, name string, onProgress func(PullProgress) error) error
+	// DeleteModel removes an installed model.
+	DeleteModel(ctx context.Context, name string) error
+}
+
+// Embedder is implemented by providers that can compute text embeddings.
+type Embedder interface {
+	// Embed returns one vector per input, in the same order.
+	Embed(ctx context.Context, model string, inputs []string) ([][]float64, error)
+}
+
+// ToolCaller is implemented by providers that can pass tools to the model natively.
+type ToolCaller interface {
+	// SupportsTools reports whether the model accepts tool definitions.
+	SupportsTools(ctx context.Context, model string) bool
+}
+
+// ChatMessage is a single role-tagged message sent to the model.
+type ChatMessage struct {
+	Role    string `json:"role"` // 'system', 'user', 'assistant' or 'tool'
+	Content string `json:"content"`
+	// ToolCalls are the tools an assistant message called. Providers translate them
+	// into their own wire format.
+	ToolCalls []models.ToolCall `json:"-"`
+	// ToolCallID and ToolName identify the call a 'tool' message answers.
+	ToolCallID string `json:"-"`
+	ToolName   string `json:"-"`
+	// Images are attached to a user message for vision models.
+	Images []ChatImage `json:"-"`
+	// Files are text files attached to a user message. AttachmentService.LoadAttachments
+	// moves their content into Content.
+	Files []ChatFile `json:"-"`
+}
+
+// ChatFile is a text file attachment waiting to be put into the prompt.
+type ChatFile struct {
+	ID   primitive.ObjectID
+	Name string
+}
+
+// ChatImage is an image attachment sent to the model. Data is filled in by
+// AttachmentService.LoadAttachments right before the request.
+type ChatImage struct {
+	ID          primitive.ObjectID
+	ContentType string
+	Data        []byte
+}
+
+// ChatRequest describes one chat completion.
+type ChatRequest struct {
+	Model    string
+	Messages []ChatMessage
+	Options  *models.GenerationOptions
+	// Tools are offered to the model by providers implementing ToolCaller.
+	Tools []ToolDefinition
+	// Schema asks for JSON output matching this JSON schema.
+	Schema models.RawJSON
+}
+
+// ChatResult is what a provider returns once a streamed reply has finished.
+type ChatResult struct {
+	Content   string
+	Stats     *GenerationStats
+	ToolCalls []models.ToolCall // Tools the model called instead of, or after, answering
+}
+
+// GenerationStats holds the timing and token counts reported at the end of a generation.
+type GenerationStats struct {
+	PromptTokens     int           `json:"promptTokens"`
+	CompletionTokens int           `json:"completionTokens"`
+	TotalDuration    time.Duration `json:"totalDuration"`
+	LoadDuration     time.Duration `json:"loadDuration"`
+	EvalDuration     time.Duration `json:"evalDuration"`
+}
+
+// TokensPerSecond returns the completion throughput, or 0 if it is unknown.
+func (s *GenerationStats) TokensPerSecond() float64 {
+	if s == nil || s.EvalDuration <= 0 {
+		return 0
+	}
+	return float64(s.CompletionTokens) / s.EvalDuration.Seconds()
+}
+
+// ModelInfo describes a model installed in the backend.
+type ModelInfo struct {
+	Name          string    `json:"name"`
+	Size          int64     `json:"size"`
+	Family        string    `json:"family,omitempty"`
+	ParameterSize string    `json:"parameterSize,omitempty"`
+	Quantization  string    `json:"quantization,omitempty"`
+	ContextLength int       `json:"contextLength,omitempty"`
+	ModifiedAt    time.Time `json:"modifiedAt"`
+}
+
+// ModelDetails is the full description of an installed model.
+type ModelDetails struct {
+	ModelInfo
+	Architecture string   `json:"architecture,omitempty"`
+	Format       string   `json:"format,omitempty"`
+	Parameters   string   `json:"parameters,omitempty"`
+	Template     string   `json:"template,omitempty"`
+	License      string   `json:"license,omitempty"`
+	Capabilities []string `json:"capabilities,omitempty"`
+}
+
+// PullProgress reports the state of a model download.
+type PullProgress struct {
+	Status    string  `json:"status"`
+	Digest    string  `json:"digest,omitempty"`
+	Total     int64   `json:"total,omitempty"`
+	Completed int64   `json:"completed,omitempty"`
+	Percent   float64 `json:"percent,omitempty"`
+}
+
+// ResolveModel maps a requested model name onto one the provider actually has. An
+// exact name wins; otherwise a prefix such as "deepseek" or "llama3.2" picks the
+// installed model that starts with it, preferring the default model. An empty request resolves to the
+// provider's default model. If the provider cannot list its models, the name is
+// used as given.
+func ResolveModel(ctx context.Context, llm LLMProvider, requested string) (string, error) {
+	requested = strings.TrimSpace(requested)
+	if requested == "" {
+		return llm.DefaultModel(), nil
+	}
+
+	installed, err := modelNames(ctx, llm)
+	if err != nil || len(installed) == 0 {
+		return requested, nil
+	}
+
+	for _, name := range installed {
+		if name == requested || strings.TrimSuffix(name, ":latest") == requested {
+			return name, nil
+		}
+	}
+	if strings.HasPrefix(llm.DefaultModel(), requested) {
+		return llm.DefaultModel(), nil
+	}
+	for _, name := range installed {
+		if strings.HasPrefix(name, requested) {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %s", ErrModelNotFound, requested)
+}
+
+// modelNames lists the names of the models available to the backend.
+func modelNames(ctx context.Context, llm LLMProvider) ([]string, error) {
+	if lister, ok := llm.(ModelNameLister); ok {
+		return lister.ListModelNames(ctx)
+	}
+	models, err := llm.ListModels(ctx)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(models))
+	for i, m := range models {
+		names[i] = m.Name
+	}
+	return names, nil
+}
+
+// wrapTransportError marks dial failures (e.g. connection refused) as ErrProviderUnavailable.
+func wrapTransportError(err error) error {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return fmt.Errorf("%w: %v", ErrProviderUnavailable, err)
+	}
+	return err
+}
@@ -0,0 +1,19 @@
+// services/main_test.go
+
+package services
+
+import (
+	"io"
+	"os"
+	"testing"
+
+	"github.com/ashuthe1/localmind/logger"
+	"github.com/sirupsen/logrus"
+)
+
+func TestMain(m *testing.M) {
+	// The services log through logger.Log, which main sets up with log files
+	logger.Log = logrus.New()
+	logger.Log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
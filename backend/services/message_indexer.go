@@ -0,0 +1,318 @@
+// services/message_indexer.go
+
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ashuthe1/localmind/logger"
+	"github.com/ashuthe1/localmind/models"
+	"github.com/ashuthe1/localmind/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// maxEmbeddedRunes is how much of a message is embedded; longer messages are cut.
+	maxEmbeddedRunes = 4000
+	// snippetRunes is the length of the message snippet stored for search results.
+	snippetRunes = 240
+	// indexChatTimeout bounds the embedding of one chat.
+	indexChatTimeout = 5 * time.Minute
+	// searchOverfetch scores this many times the requested results, so matches whose
+	// message has just been removed can be replaced.
+	searchOverfetch = 2
+)
+
+// MessageSearchResult is a chat message that matched a search.
+type MessageSearchResult struct {
+	ChatID    primitive.ObjectID `json:"chatId"`
+	ChatTitle string             `json:"chatTitle"`
+	MessageID primitive.ObjectID `json:"messageId"`
+	Role      string             `json:"role"`
+	Snippet   string             `json:"snippet"`
+	Score     float64            `json:"score"`
+	Timestamp time.Time          `json:"timestamp"`
+}
+
+// MessageIndexer embeds chat messages in the background so they can be found by
+// meaning. Chats are queued with Notify when their messages change; Start also
+// indexes whatever was missed while the server was down.
+type MessageIndexer struct {
+	ChatRepo      *repository.ChatRepository
+	EmbeddingRepo *repository.EmbeddingRepository
+	LLM           LLMProvider
+	Model         string // Embedding model
+
+	mu      sync.Mutex
+	pending map[primitive.ObjectID]bool
+	wake    chan struct{}
+}
+
+func NewMessageIndexer(chatRepo *repository.ChatRepository, embeddingRepo *repository.EmbeddingRepository, llm LLMProvider, model string) *MessageIndexer {
+	return &MessageIndexer{
+		ChatRepo:      chatRepo,
+		EmbeddingRepo: embeddingRepo,
+		LLM:           llm,
+		Model:         model,
+		pending:       make(map[primitive.ObjectID]bool),
+		wake:          make(chan struct{}, 1),
+	}
+}
+
+// Start indexes every chat once, then keeps indexing notified chats until ctx is cancelled.
+func (ix *MessageIndexer) Start(ctx context.Context) {
+	if _, ok := ix.LLM.(Embedder); !ok {
+		logger.Log.Warnf("The %s provider cannot compute embeddings, semantic search is disabled", ix.LLM.Name())
+		return
+	}
+
+	go func() {
+		ix.backfill(ctx)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ix.wake:
+				for _, chatID := range ix.takePending() {
+					ix.indexChat(ctx, chatID)
+				}
+			}
+		}
+	}()
+}
+
+// Notify queues a chat whose messages were added or changed. It never blocks.
+func (ix *MessageIndexer) Notify(chatID primitive.ObjectID) {
+	if ix == nil {
+		return
+	}
+	ix.mu.Lock()
+	ix.pending[chatID] = true
+	ix.mu.Unlock()
+
+	select {
+	case ix.wake <- struct{}{}:
+	default: // The worker is already due to run
+	}
+}
+
+// Forget deletes the embeddings of a deleted chat.
+func (ix *MessageIndexer) Forget(chatID primitive.ObjectID) {
+	if ix == nil {
+		return
+	}
+	if err := ix.EmbeddingRepo.DeleteChatEmbeddings(chatID); err != nil {
+		logger.Log.Errorf("Error deleting embeddings of chat %s: %v", chatID.Hex(), err)
+	}
+}
+
+// ForgetAll deletes every message embedding.
+func (ix *MessageIndexer) ForgetAll() {
+	if ix == nil {
+		return
+	}
+	if err := ix.EmbeddingRepo.DeleteAllEmbeddings(); err != nil {
+		logger.Log.Errorf("Error deleting message embeddings: %v", err)
+	}
+}
+
+// Search returns the messages most similar in meaning to the query, best first.
+func (ix *MessageIndexer) Search(ctx context.Context, query string, limit int) ([]MessageSearchResult, error) {
+	embedder, ok := ix.LLM.(Embedder)
+	if !ok {
+		return nil, ErrEmbeddingsUnsupported
+	}
+	vectors, err := embedder.Embed(ctx, ix.Model, []string{query})
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the best matches are kept while scoring; their snippets are loaded afterwards
+	best := newTopScores(limit * searchOverfetch)
+	err = ix.EmbeddingRepo.EachEmbedding(ctx, ix.Model, func(messageID primitive.ObjectID, embedding []float64) {
+		best.Add(messageID, CosineSimilarity(vectors[0], embedding))
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(best.Items) == 0 {
+		return []MessageSearchResult{}, nil
+	}
+
+	messageIDs := make([]primitive.ObjectID, len(best.Items))
+	for i, item := range best.Items {
+		messageIDs[i] = item.ID
+	}
+	embeddings, err := ix.EmbeddingRepo.GetEmbeddingsByIDs(messageIDs)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[primitive.ObjectID]models.MessageEmbedding, len(embeddings))
+	for _, embedding := range embeddings {
+		byID[embedding.MessageID] = embedding
+	}
+
+	chats, err := ix.ChatRepo.GetMessageChats(messageIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Skip, and forget, messages removed since they were indexed
+	results := []MessageSearchResult{}
+	var stale []primitive.ObjectID
+	for _, item := range best.Items {
+		embedding, ok := byID[item.ID]
+		if !ok {
+			continue
+		}
+		chat, ok := chats[item.ID]
+		if !ok {
+			stale = append(stale, item.ID)
+			continue
+		}
+		if len(results) == limit {
+			continue
+		}
+
+		results = append(results, MessageSearchResult{
+			ChatID:    embedding.ChatID,
+			ChatTitle: chat.Title,
+			MessageID: embedding.MessageID,
+			Role:      embedding.Role,
+			Snippet:   embedding.Snippet,
+			Score:     item.Score,
+			Timestamp: embedding.Timestamp,
+		})
+	}
+	if len(stale) > 0 {
+		if err := ix.EmbeddingRepo.DeleteMessageEmbeddings(stale); err != nil {
+			logger.Log.Errorf("Error deleting embeddings of removed messages: %v", err)
+		}
+	}
+	return results, nil
+}
+
+func (ix *MessageIndexer) takePending() []primitive.ObjectID {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	chatIDs := make([]primitive.ObjectID, 0, len(ix.pending))
+	for chatID := range ix.pending {
+		chatIDs = append(chatIDs, chatID)
+	}
+	ix.pending = make(map[primitive.ObjectID]bool)
+	return chatIDs
+}
+
+// backfill indexes the messages of every chat that are not embedded yet.
+func (ix *MessageIndexer) backfill(ctx context.Context) {
+	chats, err := ix.ChatRepo.GetAllChats()
+	if err != nil {
+		logger.Log.Errorf("Error loading chats to index: %v", err)
+		return
+	}
+	for _, chat := range chats {
+		if ctx.Err() != nil {
+			return
+		}
+		ix.indexChat(ctx, chat.ID)
+	}
+}
+
+// indexChat embeds the user and assistant messages of a chat that are new, changed
+// or embedded with another model.
+func (ix *MessageIndexer) indexChat(ctx context.Context, chatID primitive.ObjectID) {
+	ctx, cancel := context.WithTimeout(ctx, indexChatTimeout)
+	defer cancel()
+
+	chat, err := ix.ChatRepo.GetChatByID(chatID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return
+	}
+	if err != nil {
+		logger.Log.Errorf("Error loading chat %s to index: %v", chatID.Hex(), err)
+		return
+	}
+	existing, err := ix.EmbeddingRepo.GetChatEmbeddingKeys(chatID)
+	if err != nil {
+		logger.Log.Errorf("Error loading embeddings of chat %s: %v", chatID.Hex(), err)
+		return
+	}
+
+	var todo []models.MessageEmbedding
+	var inputs []string
+	indexed := make(map[primitive.ObjectID]bool)
+	for _, msg := range chat.Messages {
+		if msg.Role != "user" && msg.Role != "assistant" {
+			continue
+		}
+		text := strings.TrimSpace(toChatMessage(msg).Content)
+		if text == "" {
+			continue
+		}
+		indexed[msg.ID] = true
+		hash := contentHash(text)
+		if known, ok := existing[msg.ID]; ok && known.ContentHash == hash && known.Model == ix.Model {
+			continue
+		}
+		todo = append(todo, models.MessageEmbedding{
+			MessageID:   msg.ID,
+			ChatID:      chatID,
+			Role:        msg.Role,
+			Snippet:     truncateRunes(text, snippetRunes),
+			ContentHash: hash,
+			Model:       ix.Model,
+			Timestamp:   msg.Timestamp,
+		})
+		inputs = append(inputs, truncateRunes(text, maxEmbeddedRunes))
+	}
+
+	// Drop the embeddings of messages that were removed or have no text left
+	var stale []primitive.ObjectID
+	for messageID := range existing {
+		if !indexed[messageID] {
+			stale = append(stale, messageID)
+		}
+	}
+	if len(stale) > 0 {
+		if err := ix.EmbeddingRepo.DeleteMessageEmbeddings(stale); err != nil {
+			logger.Log.Errorf("Error deleting stale embeddings of chat %s: %v", chatID.Hex(), err)
+		}
+	}
+
+	embedder := ix.LLM.(Embedder)
+	for start := 0; start < len(todo); start += embedBatchSize {
+		end := start + embedBatchSize
+		if end > len(todo) {
+			end = len(todo)
+		}
+		vectors, err := embedder.Embed(ctx, ix.Model, inputs[start:end])
+		if err != nil {
+			logger.Log.Errorf("Error embedding messages of chat %s: %v", chatID.Hex(), err)
+			return
+		}
+		for i, vector := range vectors {
+			embedding := todo[start+i]
+			embedding.Embedding = vector
+			if err := ix.EmbeddingRepo.UpsertMessageEmbedding(&embedding); err != nil {
+				logger.Log.Errorf("Error saving message embedding: %v", err)
+				return
+			}
+		}
+	}
+	if len(todo) > 0 {
+		logger.Log.Infof("Indexed %d messages of chat %s", len(todo), chatID.Hex())
+	}
+}
+
+func contentHash(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
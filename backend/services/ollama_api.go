@@ -0,0 +1,286 @@
+// services/ollama_api.go
+
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ashuthe1/localmind/models"
+)
+
+// OllamaError describes a non-2xx response or an in-stream error from the Ollama API.
+type OllamaError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *OllamaError) Error() string {
+	if e.StatusCode == 0 {
+		return fmt.Sprintf("ollama: %s", e.Message)
+	}
+	return fmt.Sprintf("ollama: %s (status %d)", e.Message, e.StatusCode)
+}
+
+// modelNotFound matches Ollama's message for a missing model, e.g. `model "x" not
+// found, try pulling it first`, which streamed errors carry without a status code.
+var modelNotFound = regexp.MustCompile(`^model ["'][^"']*["'] not found`)
+
+// Unwrap lets callers match OllamaError against the sentinel errors with errors.Is.
+func (e *OllamaError) Unwrap() error {
+	if e.StatusCode == http.StatusNotFound || modelNotFound.MatchString(e.Message) {
+		return ErrModelNotFound
+	}
+	return nil
+}
+
+type ollamaChatRequest struct {
+	Model    string          `json:"model"`
+	Messages []ollamaMessage `json:"messages"`
+	Stream   bool            `json:"stream"`
+	Options  *ollamaOptions  `json:"options,omitempty"`
+	Tools    []ollamaTool    `json:"tools,omitempty"`
+	Format   models.RawJSON  `json:"format,omitempty"` // JSON schema the reply is constrained to
+}
+
+// ollamaMessage is a ChatMessage in the shape /api/chat expects, with tool calls
+// carrying their arguments as an object.
+type ollamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+	ToolName  string           `json:"tool_name,omitempty"`
+	Images    [][]byte         `json:"images,omitempty"` // Encoded as base64 strings
+}
+
+type ollamaToolCall struct {
+	Function struct {
+		Name      string                 `json:"name"`
+		Arguments map[string]interface{} `json:"arguments"`
+	} `json:"function"`
+}
+
+type ollamaTool struct {
+	Type     string         `json:"type"`
+	Function ToolDefinition `json:"function"`
+}
+
+func toOllamaMessages(messages []ChatMessage) []ollamaMessage {
+	out := make([]ollamaMessage, 0, len(messages))
+	for _, msg := range messages {
+		m := ollamaMessage{Role: msg.Role, Content: msg.Content, ToolName: msg.ToolName}
+		for _, image := range msg.Images {
+			m.Images = append(m.Images, image.Data)
+		}
+		for _, call := range msg.ToolCalls {
+			var tc ollamaToolCall
+			tc.Function.Name = call.Name
+			tc.Function.Arguments = call.Arguments
+			m.ToolCalls = append(m.ToolCalls, tc)
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+func toOllamaTools(tools []ToolDefinition) []ollamaTool {
+	out := make([]ollamaTool, 0, len(tools))
+	for _, tool := range tools {
+		out = append(out, ollamaTool{Type: "function", Function: tool})
+	}
+	return out
+}
+
+// ollamaOptions are the model parameters Ollama accepts under "options".
+type ollamaOptions struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	NumCtx      *int     `json:"num_ctx,omitempty"`
+	Seed        *int     `json:"seed,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+	NumPredict  *int     `json:"num_predict,omitempty"`
+}
+
+func toOllamaOptions(o *models.GenerationOptions) *ollamaOptions {
+	if o == nil {
+		return nil
+	}
+	return &ollamaOptions{
+		Temperature: o.Temperature,
+		TopP:        o.TopP,
+		NumCtx:      o.NumCtx,
+		Seed:        o.Seed,
+		Stop:        o.Stop,
+		NumPredict:  o.MaxTokens,
+	}
+}
+
+type ollamaEmbedRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type ollamaEmbedResponse struct {
+	Embeddings [][]float64 `json:"embeddings"`
+}
+
+type ollamaGenerateRequest struct {
+	Model  string `json:"model"`
+	Prompt string `json:"prompt"`
+	Stream bool   `json:"stream"`
+}
+
+type ollamaTagsResponse struct {
+	Models []ollamaModel `json:"models"`
+}
+
+type ollamaModel struct {
+	Name       string             `json:"name"`
+	Size       int64              `json:"size"`
+	Digest     string             `json:"digest"`
+	ModifiedAt time.Time          `json:"modified_at"`
+	Details    ollamaModelDetails `json:"details"`
+}
+
+type ollamaModelDetails struct {
+	Format            string `json:"format"`
+	Family            string `json:"family"`
+	ParameterSize     string `json:"parameter_size"`
+	QuantizationLevel string `json:"quantization_level"`
+}
+
+func (m *ollamaModel) toModelInfo() ModelInfo {
+	return ModelInfo{
+		Name:          m.Name,
+		Size:          m.Size,
+		Family:        m.Details.Family,
+		ParameterSize: m.Details.ParameterSize,
+		Quantization:  m.Details.QuantizationLevel,
+		ModifiedAt:    m.ModifiedAt,
+	}
+}
+
+type ollamaModelRequest struct {
+	Model  string `json:"model"`
+	Stream bool   `json:"stream,omitempty"`
+}
+
+type ollamaShowResponse struct {
+	Parameters   string                 `json:"parameters"`
+	Template     string                 `json:"template"`
+	License      string                 `json:"license"`
+	Details      ollamaModelDetails     `json:"details"`
+	ModelInfo    map[string]interface{} `json:"model_info"`
+	Capabilities []string               `json:"capabilities"`
+	ModifiedAt   time.Time              `json:"modified_at"`
+}
+
+// architecture returns the model architecture, e.g. "llama" or "qwen2".
+func (r *ollamaShowResponse) architecture() string {
+	arch, _ := r.ModelInfo["general.architecture"].(string)
+	return arch
+}
+
+// contextLength reads "<architecture>.context_length" from the model info.
+func (r *ollamaShowResponse) contextLength() int {
+	value, _ := r.ModelInfo[r.architecture()+".context_length"].(float64)
+	return int(value)
+}
+
+// ollamaPullChunk is one NDJSON progress line emitted by /api/pull.
+type ollamaPullChunk struct {
+	Status    string `json:"status"`
+	Digest    string `json:"digest"`
+	Total     int64  `json:"total"`
+	Completed int64  `json:"completed"`
+	Error     string `json:"error"`
+}
+
+// ollamaStreamChunk covers the NDJSON lines emitted by both /api/chat and /api/generate.
+type ollamaStreamChunk struct {
+	Model           string        `json:"model"`
+	Message         ollamaMessage `json:"message"`  // /api/chat
+	Response        string        `json:"response"` // /api/generate
+	Done            bool          `json:"done"`
+	DoneReason      string        `json:"done_reason"`
+	Error           string        `json:"error"`
+	TotalDuration   int64         `json:"total_duration"`
+	LoadDuration    int64         `json:"load_duration"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+	EvalDuration    int64         `json:"eval_duration"`
+}
+
+func (c *ollamaStreamChunk) text() string {
+	if c.Message.Content != "" {
+		return c.Message.Content
+	}
+	return c.Response
+}
+
+func (c *ollamaStreamChunk) stats() *GenerationStats {
+	return &GenerationStats{
+		PromptTokens:     c.PromptEvalCount,
+		CompletionTokens: c.EvalCount,
+		TotalDuration:    time.Duration(c.TotalDuration),
+		LoadDuration:     time.Duration(c.LoadDuration),
+		EvalDuration:     time.Duration(c.EvalDuration),
+	}
+}
+
+// decodeOllamaStream reads NDJSON chunks from body, passing every text fragment to
+// onText, and returns the stats from the final chunk and the tools the model called.
+func decodeOllamaStream(body io.Reader, onText func(text string) error) (*GenerationStats, []models.ToolCall, error) {
+	var toolCalls []models.ToolCall
+	decoder := json.NewDecoder(body)
+	for {
+		var chunk ollamaStreamChunk
+		if err := decoder.Decode(&chunk); err != nil {
+			if err == io.EOF {
+				return nil, nil, fmt.Errorf("ollama stream ended before completion")
+			}
+			return nil, nil, fmt.Errorf("error decoding ollama stream: %w", err)
+		}
+
+		if chunk.Error != "" {
+			return nil, nil, &OllamaError{Message: chunk.Error}
+		}
+
+		if text := chunk.text(); text != "" {
+			if err := onText(text); err != nil {
+				return nil, nil, err
+			}
+		}
+		for _, call := range chunk.Message.ToolCalls {
+			toolCalls = append(toolCalls, models.ToolCall{Name: call.Function.Name, Arguments: call.Function.Arguments})
+		}
+
+		if chunk.Done {
+			return chunk.stats(), toolCalls, nil
+		}
+	}
+}
+
+// checkOllamaResponse converts an unsuccessful HTTP response into an OllamaError.
+func checkOllamaResponse(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	var body struct {
+		Error string `json:"error"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err := json.Unmarshal(data, &body); err != nil || body.Error == "" {
+		body.Error = strings.TrimSpace(string(data))
+	}
+	if body.Error == "" {
+		body.Error = http.StatusText(resp.StatusCode)
+	}
+	return &OllamaError{StatusCode: resp.StatusCode, Message: body.Error}
+}
@@ -0,0 +1,103 @@
+// services/ollama_cli.go
+
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"time"
+
+	"github.com/ashuthe1/localmind/logger"
+)
+
+// cliWaitDelay bounds how long Wait blocks on the output pipes after the process is killed.
+const cliWaitDelay = 2 * time.Second
+
+// generateResponseCLI runs the prompt through `ollama run`. It is only used as a
+// fallback when the Ollama HTTP API cannot be reached. Cancelling ctx kills the process.
+func generateResponseCLI(ctx context.Context, prompt string, model string) (string, error) {
+	// Prepare the command
+	cmd := exec.CommandContext(ctx, "ollama", "run", model)
+	cmd.WaitDelay = cliWaitDelay
+
+	// Provide the prompt as stdin input
+	cmd.Stdin = bytes.NewBufferString(prompt)
+
+	// Capture the output
+	var out bytes.Buffer
+	cmd.Stdout = &out
+
+	// Run the command
+	if err := cmd.Run(); err != nil {
+		return "", err
+	}
+
+	return out.String(), nil
+}
+
+// streamResponseCLI streams `ollama run` stdout line by line. The process is killed
+// when ctx is cancelled or when sendChunk fails.
+func streamResponseCLI(ctx context.Context, prompt string, model string, sendChunk func(chunk string) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "ollama", "run", model)
+	cmd.Stdin = bytes.NewBufferString(prompt)
+	cmd.WaitDelay = cliWaitDelay
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		logger.Log.Errorf("Error getting stdout pipe: %v", err)
+		return err
+	}
+
+	if err := cmd.Start(); err != nil {
+		logger.Log.Errorf("Error starting Ollama process: %v", err)
+		return err
+	}
+
+	// Ensure process cleanup. On an early return the process is killed first so
+	// Wait does not block while it keeps generating.
+	exited := false
+	defer func() {
+		if !exited {
+			cancel()
+			cmd.Wait()
+		}
+	}()
+
+	reader := bufio.NewReader(stdout)
+	for {
+		chunk, err := reader.ReadString('\n')
+		if chunk != "" {
+			if err := sendChunk(chunk); err != nil {
+				logger.Log.Errorf("Error in sendChunk: %v", err)
+				return fmt.Errorf("failed to send chunk: %w", err)
+			}
+		}
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			logger.Log.Errorf("Error reading from Ollama stream: %v", err)
+			return err
+		}
+	}
+
+	exited = true
+	return cmd.Wait()
+}
+
+// flattenMessages renders a role-tagged conversation as a single prompt for `ollama run`.
+func flattenMessages(messages []ChatMessage) string {
+	var buf bytes.Buffer
+	for _, msg := range messages {
+		fmt.Fprintf(&buf, "%s: %s\n\n", msg.Role, msg.Content)
+	}
+	buf.WriteString("assistant: ")
+	return buf.String()
+}
@@ -0,0 +1,130 @@
+// services/ollama_models.go
+
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+var _ ModelManager = (*OllamaService)(nil)
+
+// ShowModel returns the details of an installed model from /api/show.
+func (s *OllamaService) ShowModel(ctx context.Context, name string) (*ModelDetails, error) {
+	show, err := s.show(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ModelDetails{
+		ModelInfo: ModelInfo{
+			Name:          name,
+			Family:        show.Details.Family,
+			ParameterSize: show.Details.ParameterSize,
+			Quantization:  show.Details.QuantizationLevel,
+			ContextLength: show.contextLength(),
+			ModifiedAt:    show.ModifiedAt,
+		},
+		Architecture: show.architecture(),
+		Format:       show.Details.Format,
+		Parameters:   show.Parameters,
+		Template:     show.Template,
+		License:      show.License,
+		Capabilities: show.Capabilities,
+	}, nil
+}
+
+// PullModel downloads a model through /api/pull, passing every progress update to onProgress.
+func (s *OllamaService) PullModel(ctx context.Context, name string, onProgress func(PullProgress) error) error {
+	// The pull may replace the model with a new version
+	defer s.forgetShow(name)
+
+	resp, err := s.do(ctx, http.MethodPost, "/api/pull", ollamaModelRequest{Model: name, Stream: true})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var chunk ollamaPullChunk
+		if err := decoder.Decode(&chunk); err != nil {
+			if err == io.EOF {
+				return fmt.Errorf("ollama pull ended before completion")
+			}
+			return fmt.Errorf("error decoding ollama pull stream: %w", err)
+		}
+
+		if chunk.Error != "" {
+			return &OllamaError{Message: chunk.Error}
+		}
+
+		progress := PullProgress{
+			Status:    chunk.Status,
+			Digest:    chunk.Digest,
+			Total:     chunk.Total,
+			Completed: chunk.Completed,
+		}
+		if chunk.Total > 0 {
+			progress.Percent = float64(chunk.Completed) / float64(chunk.Total) * 100
+		}
+		if err := onProgress(progress); err != nil {
+			return err
+		}
+
+		if chunk.Status == "success" {
+			return nil
+		}
+	}
+}
+
+// DeleteModel removes an installed model through /api/delete.
+func (s *OllamaService) DeleteModel(ctx context.Context, name string) error {
+	s.forgetShow(name)
+	return s.requestJSON(ctx, http.MethodDelete, "/api/delete", ollamaModelRequest{Model: name}, nil)
+}
+
+func (s *OllamaService) show(ctx context.Context, name string) (*ollamaShowResponse, error) {
+	var show ollamaShowResponse
+	if err := s.requestJSON(ctx, http.MethodPost, "/api/show", ollamaModelRequest{Model: name}, &show); err != nil {
+		return nil, err
+	}
+	return &show, nil
+}
+
+// cachedShow returns the /api/show response of a model, asking Ollama only when it is
+// not cached or the cached one is for another digest. An empty digest accepts any.
+func (s *OllamaService) cachedShow(ctx context.Context, name string, digest string) (*ollamaShowResponse, error) {
+	s.showMu.Lock()
+	cached, ok := s.shows[name]
+	s.showMu.Unlock()
+	if ok && (digest == "" || cached.digest == digest) {
+		return cached.show, nil
+	}
+
+	show, err := s.show(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	s.showMu.Lock()
+	if s.shows == nil {
+		s.shows = make(map[string]cachedShow)
+	}
+	s.shows[name] = cachedShow{digest: digest, show: show}
+	s.showMu.Unlock()
+	return show, nil
+}
+
+// forgetShow drops the cached /api/show response of a model.
+func (s *OllamaService) forgetShow(name string) {
+	s.showMu.Lock()
+	delete(s.shows, name)
+	if !strings.Contains(name, ":") {
+		delete(s.shows, name+":latest")
+	}
+	s.showMu.Unlock()
+}
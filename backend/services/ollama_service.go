@@ -3,79 +3,252 @@
 package services
 
 import (
-	"bufio"
 	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"os/exec"
+	"net/http"
+	"strings"
+	"sync"
 
 	"github.com/ashuthe1/localmind/logger"
+	"github.com/ashuthe1/localmind/models"
 )
 
-type OllamaService struct{}
+// OllamaService is the LLMProvider for a local Ollama server, talking to its HTTP API.
+type OllamaService struct {
+	BaseURL    string
+	Model      string
+	HTTPClient *http.Client
+	// CLIFallback runs `ollama run` when the HTTP API cannot be reached.
+	CLIFallback bool
+
+	// shows caches /api/show responses by model name
+	showMu sync.Mutex
+	shows  map[string]cachedShow
+}
 
-func NewOllamaService() *OllamaService {
-	return &OllamaService{}
+// cachedShow is an /api/show response for one version of a model.
+type cachedShow struct {
+	digest string // Empty when the response was cached without knowing the digest
+	show   *ollamaShowResponse
 }
 
-// GenerateResponse remains for non-streaming use if needed.
-func (s *OllamaService) GenerateResponse(prompt string, model string) (string, error) {
-	// Prepare the command
-	cmd := exec.Command("ollama", "run", model)
+var (
+	_ LLMProvider     = (*OllamaService)(nil)
+	_ Embedder        = (*OllamaService)(nil)
+	_ ToolCaller      = (*OllamaService)(nil)
+	_ ModelNameLister = (*OllamaService)(nil)
+)
+
+func NewOllamaService(baseURL string, model string, cliFallback bool) *OllamaService {
+	return &OllamaService{
+		BaseURL:     strings.TrimRight(baseURL, "/"),
+		Model:       model,
+		HTTPClient:  &http.Client{},
+		CLIFallback: cliFallback,
+	}
+}
 
-	// Provide the prompt as stdin input
-	cmd.Stdin = bytes.NewBufferString(prompt)
+func (s *OllamaService) Name() string {
+	return "ollama"
+}
 
-	// Capture the output
-	var out bytes.Buffer
-	cmd.Stdout = &out
+func (s *OllamaService) DefaultModel() string {
+	return s.Model
+}
 
-	// Run the command
-	if err := cmd.Run(); err != nil {
+// Generate returns the full completion for a single prompt.
+func (s *OllamaService) Generate(ctx context.Context, model string, prompt string) (string, error) {
+	var out strings.Builder
+	_, _, err := s.stream(ctx, "/api/generate", ollamaGenerateRequest{Model: model, Prompt: prompt, Stream: true}, func(text string) error {
+		out.WriteString(text)
+		return nil
+	})
+	if errors.Is(err, ErrProviderUnavailable) && s.CLIFallback {
+		logger.Log.Warnf("Ollama API unreachable, falling back to CLI: %v", err)
+		return generateResponseCLI(ctx, prompt, model)
+	}
+	if err != nil {
 		return "", err
 	}
+	return out.String(), nil
+}
+
+// StreamChat streams the reply to a role-tagged conversation token by token.
+func (s *OllamaService) StreamChat(ctx context.Context, req ChatRequest, sendChunk func(chunk string) error) (*ChatResult, error) {
+	var out strings.Builder
+	onText := func(text string) error {
+		out.WriteString(text)
+		return sendChunk(text)
+	}
+
+	payload := ollamaChatRequest{
+		Model:    req.Model,
+		Messages: toOllamaMessages(req.Messages),
+		Stream:   true,
+		Options:  toOllamaOptions(req.Options),
+		Format:   req.Schema,
+	}
+	if len(req.Tools) > 0 {
+		payload.Tools = toOllamaTools(req.Tools)
+	}
+	stats, toolCalls, err := s.stream(ctx, "/api/chat", payload, onText)
+	if errors.Is(err, ErrProviderUnavailable) && s.CLIFallback {
+		if reason := cliUnsupported(req); reason != "" {
+			logger.Log.Warnf("Ollama API unreachable, not falling back to CLI: %s", reason)
+		} else {
+			logger.Log.Warnf("Ollama API unreachable, falling back to CLI: %v", err)
+			err = streamResponseCLI(ctx, flattenMessages(req.Messages), req.Model, onText)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ChatResult{Content: out.String(), Stats: stats, ToolCalls: toolCalls}, nil
+}
+
+// cliUnsupported explains why `ollama run` cannot serve a request, or returns "". It
+// reads the prompt from stdin, which cannot carry images, and cannot constrain the
+// output to a schema or offer tools.
+func cliUnsupported(req ChatRequest) string {
+	switch {
+	case HasImages(req.Messages):
+		return "the request has images"
+	case len(req.Schema) > 0:
+		return "the request has a JSON schema"
+	case len(req.Tools) > 0:
+		return "the request has tools"
+	}
+	return ""
+}
+
+// SupportsTools checks the model's capabilities reported by /api/show.
+func (s *OllamaService) SupportsTools(ctx context.Context, model string) bool {
+	show, err := s.cachedShow(ctx, model, "")
+	if err != nil {
+		return false
+	}
+	for _, capability := range show.Capabilities {
+		if capability == "tools" {
+			return true
+		}
+	}
+	return false
+}
+
+// ListModels returns the locally installed models.
+func (s *OllamaService) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	var tags ollamaTagsResponse
+	if err := s.requestJSON(ctx, http.MethodGet, "/api/tags", nil, &tags); err != nil {
+		return nil, err
+	}
 
-	// Get the response
-	response := out.String()
-	return response, nil
+	models := make([]ModelInfo, 0, len(tags.Models))
+	for _, m := range tags.Models {
+		info := m.toModelInfo()
+		// The context length is only reported by /api/show.
+		if show, err := s.cachedShow(ctx, m.Name, m.Digest); err == nil {
+			info.ContextLength = show.contextLength()
+		}
+		models = append(models, info)
+	}
+	return models, nil
 }
 
-func (s *OllamaService) StreamResponse(prompt string, model string, sendChunk func(chunk string) error) error {
-	// log.Println("Starting Ollama streaming process...")
+// ListModelNames returns the names of the locally installed models from /api/tags alone.
+func (s *OllamaService) ListModelNames(ctx context.Context) ([]string, error) {
+	var tags ollamaTagsResponse
+	if err := s.requestJSON(ctx, http.MethodGet, "/api/tags", nil, &tags); err != nil {
+		return nil, err
+	}
 
-	cmd := exec.Command("ollama", "run", model)
-	cmd.Stdin = bytes.NewBufferString(prompt)
+	names := make([]string, len(tags.Models))
+	for i, m := range tags.Models {
+		names[i] = m.Name
+	}
+	return names, nil
+}
 
-	stdout, err := cmd.StdoutPipe()
+// Embed computes embeddings with /api/embed. The CLI has no equivalent, so there is no fallback.
+func (s *OllamaService) Embed(ctx context.Context, model string, inputs []string) ([][]float64, error) {
+	var result ollamaEmbedResponse
+	if err := s.requestJSON(ctx, http.MethodPost, "/api/embed", ollamaEmbedRequest{Model: model, Input: inputs}, &result); err != nil {
+		return nil, err
+	}
+	if len(result.Embeddings) != len(inputs) {
+		return nil, fmt.Errorf("ollama: got %d embeddings for %d inputs", len(result.Embeddings), len(inputs))
+	}
+	return result.Embeddings, nil
+}
+
+// Health checks that the Ollama server answers.
+func (s *OllamaService) Health(ctx context.Context) error {
+	var version struct {
+		Version string `json:"version"`
+	}
+	return s.requestJSON(ctx, http.MethodGet, "/api/version", nil, &version)
+}
+
+// stream sends a streaming request to the Ollama API and decodes the NDJSON response.
+func (s *OllamaService) stream(ctx context.Context, path string, payload interface{}, onText func(text string) error) (*GenerationStats, []models.ToolCall, error) {
+	resp, err := s.do(ctx, http.MethodPost, path, payload)
 	if err != nil {
-		logger.Log.Errorf("Error getting stdout pipe: %v", err)
-		return err
+		return nil, nil, err
 	}
+	defer resp.Body.Close()
 
-	if err := cmd.Start(); err != nil {
-		logger.Log.Errorf("Error starting Ollama process: %v", err)
+	stats, toolCalls, err := decodeOllamaStream(resp.Body, onText)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return stats, toolCalls, nil
+}
+
+// requestJSON sends a request to the Ollama API and decodes the JSON response into out,
+// which may be nil when the body is not needed.
+func (s *OllamaService) requestJSON(ctx context.Context, method string, path string, payload interface{}, out interface{}) error {
+	resp, err := s.do(ctx, method, path, payload)
+	if err != nil {
 		return err
 	}
+	defer resp.Body.Close()
 
-	defer cmd.Wait() // Ensure process cleanup
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
 
-	reader := bufio.NewReader(stdout)
-	for {
-		chunk, err := reader.ReadString('\n')
+// do sends a request to the Ollama API and checks the response status.
+// The caller must close the body of the returned response.
+func (s *OllamaService) do(ctx context.Context, method string, path string, payload interface{}) (*http.Response, error) {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
 		if err != nil {
-			if err == io.EOF {
-				// log.Println("End of Ollama output stream")
-				break
-			}
-			logger.Log.Errorf("Error reading from Ollama stream: %v", err)
-			return err
+			return nil, err
 		}
+		body = bytes.NewReader(data)
+	}
 
-		if err := sendChunk(chunk); err != nil {
-			logger.Log.Errorf("Error in sendChunk: %v", err)
-			return fmt.Errorf("failed to send chunk: %w", err)
-		}
+	req, err := http.NewRequestWithContext(ctx, method, s.BaseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
 	}
 
-	return nil
+	resp, err := s.HTTPClient.Do(req)
+	if err != nil {
+		return nil, wrapTransportError(err)
+	}
+	if err := checkOllamaResponse(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp, nil
 }
@@ -0,0 +1,161 @@
+// services/ollama_service_test.go
+
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOllamaStreamChat(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			t.Errorf("path = %q", r.URL.Path)
+		}
+		var req ollamaChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		if req.Model != "llama3.2" || !req.Stream || len(req.Tools) != 1 || string(req.Format) != `{"type":"object"}` {
+			t.Errorf("unexpected request %+v", req)
+		}
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":"Hel"},"done":false}`)
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":"lo"},"done":false}`)
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"calculate","arguments":{"expression":"2*3"}}}]},"done":false}`)
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":""},"done":true,"total_duration":3000,"load_duration":1000,"prompt_eval_count":7,"eval_count":4,"eval_duration":2000}`)
+	}))
+	defer server.Close()
+
+	llm := NewOllamaService(server.URL+"/", "llama3.2", false)
+	var chunks []string
+	result, err := llm.StreamChat(context.Background(), ChatRequest{
+		Model:    "llama3.2",
+		Messages: []ChatMessage{{Role: "user", Content: "Hi"}},
+		Tools:    []ToolDefinition{{Name: "calculate"}},
+		Schema:   []byte(`{"type":"object"}`),
+	}, func(chunk string) error {
+		chunks = append(chunks, chunk)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamChat: %v", err)
+	}
+
+	if !reflect.DeepEqual(chunks, []string{"Hel", "lo"}) {
+		t.Errorf("chunks = %q", chunks)
+	}
+	if result.Content != "Hello" {
+		t.Errorf("content = %q", result.Content)
+	}
+	want := GenerationStats{PromptTokens: 7, CompletionTokens: 4, TotalDuration: 3000, LoadDuration: 1000, EvalDuration: 2000 * time.Nanosecond}
+	if *result.Stats != want {
+		t.Errorf("stats = %+v", result.Stats)
+	}
+	if len(result.ToolCalls) != 1 || result.ToolCalls[0].Name != "calculate" || result.ToolCalls[0].Arguments["expression"] != "2*3" {
+		t.Errorf("tool calls = %+v", result.ToolCalls)
+	}
+}
+
+func TestOllamaStreamChatErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		message  string // Substring of the error
+		notFound bool
+	}{
+		{
+			name:     "model not found",
+			status:   http.StatusNotFound,
+			body:     `{"error":"model \"nope\" not found, try pulling it first"}`,
+			message:  "not found, try pulling it first (status 404)",
+			notFound: true,
+		},
+		{
+			name:    "plain text",
+			status:  http.StatusInternalServerError,
+			body:    "out of memory\n",
+			message: "ollama: out of memory (status 500)",
+		},
+		{
+			name:    "empty body",
+			status:  http.StatusBadGateway,
+			message: "Bad Gateway",
+		},
+		{
+			name:    "in-stream error",
+			status:  http.StatusOK,
+			body:    `{"message":{"content":"a"},"done":false}` + "\n" + `{"error":"llama runner process has terminated"}` + "\n",
+			message: "llama runner process has terminated",
+		},
+		{
+			name:     "in-stream model not found",
+			status:   http.StatusOK,
+			body:     `{"error":"model 'nope' not found"}` + "\n",
+			message:  "model 'nope' not found",
+			notFound: true,
+		},
+		{
+			name:    "other errors mentioning not found",
+			status:  http.StatusInternalServerError,
+			body:    `{"error":"open /models/blobs/sha256-1: file not found"}`,
+			message: "file not found (status 500)",
+		},
+		{
+			name:    "stream ended early",
+			status:  http.StatusOK,
+			body:    `{"message":{"content":"a"},"done":false}` + "\n",
+			message: "ollama stream ended before completion",
+		},
+		{
+			name:    "invalid chunk",
+			status:  http.StatusOK,
+			body:    `{"message":` + "\n",
+			message: "error decoding ollama stream",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer server.Close()
+
+			llm := NewOllamaService(server.URL, "m", false)
+			_, err := llm.StreamChat(context.Background(), ChatRequest{Model: "m"}, func(string) error { return nil })
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if !strings.Contains(err.Error(), tt.message) {
+				t.Errorf("error = %q, want it to contain %q", err, tt.message)
+			}
+			if got := errors.Is(err, ErrModelNotFound); got != tt.notFound {
+				t.Errorf("errors.Is(err, ErrModelNotFound) = %v, want %v", got, tt.notFound)
+			}
+		})
+	}
+}
+
+func TestOllamaUnavailable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	// The CLI fallback is skipped for requests with tools
+	llm := NewOllamaService(server.URL, "m", true)
+	_, err := llm.StreamChat(context.Background(), ChatRequest{Model: "m", Tools: []ToolDefinition{{Name: "calculate"}}}, func(string) error { return nil })
+	if !errors.Is(err, ErrProviderUnavailable) {
+		t.Errorf("StreamChat = %v, want ErrProviderUnavailable", err)
+	}
+}
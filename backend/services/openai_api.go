@@ -0,0 +1,334 @@
+// services/openai_api.go
+
+package services
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/ashuthe1/localmind/models"
+)
+
+// OpenAIError describes a non-2xx response or an in-stream error from an
+// OpenAI-compatible server.
+type OpenAIError struct {
+	StatusCode int
+	Type       string
+	Code       string
+	Message    string
+}
+
+func (e *OpenAIError) Error() string {
+	if e.StatusCode == 0 {
+		return fmt.Sprintf("openai: %s", e.Message)
+	}
+	return fmt.Sprintf("openai: %s (status %d)", e.Message, e.StatusCode)
+}
+
+// Unwrap lets callers match OpenAIError against the sentinel errors with errors.Is.
+func (e *OpenAIError) Unwrap() error {
+	if e.StatusCode == http.StatusNotFound || e.Code == "model_not_found" {
+		return ErrModelNotFound
+	}
+	return nil
+}
+
+type openAIChatRequest struct {
+	Model          string                `json:"model"`
+	Messages       []openAIMessage       `json:"messages"`
+	Stream         bool                  `json:"stream"`
+	StreamOptions  *openAIStreamOptions  `json:"stream_options,omitempty"`
+	Temperature    *float64              `json:"temperature,omitempty"`
+	TopP           *float64              `json:"top_p,omitempty"`
+	Seed           *int                  `json:"seed,omitempty"`
+	Stop           []string              `json:"stop,omitempty"`
+	MaxTokens      *int                  `json:"max_tokens,omitempty"`
+	Tools          []openAITool          `json:"tools,omitempty"`
+	ResponseFormat *openAIResponseFormat `json:"response_format,omitempty"`
+}
+
+type openAIResponseFormat struct {
+	Type       string `json:"type"` // "json_schema"
+	JSONSchema struct {
+		Name   string         `json:"name"`
+		Schema models.RawJSON `json:"schema"`
+	} `json:"json_schema"`
+}
+
+// openAIMessage is a ChatMessage in the chat completions format, where tool call
+// arguments are a JSON-encoded string.
+type openAIMessage struct {
+	Role       string           `json:"role"`
+	Content    interface{}      `json:"content"` // A string, or []openAIContentPart for messages with images
+	ToolCalls  []openAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+type openAIContentPart struct {
+	Type     string          `json:"type"` // "text" or "image_url"
+	Text     string          `json:"text,omitempty"`
+	ImageURL *openAIImageURL `json:"image_url,omitempty"`
+}
+
+type openAIImageURL struct {
+	URL string `json:"url"` // Images are sent inline as data: URLs
+}
+
+type openAIToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+type openAITool struct {
+	Type     string         `json:"type"`
+	Function ToolDefinition `json:"function"`
+}
+
+func toOpenAIMessages(messages []ChatMessage) []openAIMessage {
+	out := make([]openAIMessage, 0, len(messages))
+	for _, msg := range messages {
+		m := openAIMessage{Role: msg.Role, Content: msg.Content, ToolCallID: msg.ToolCallID}
+		if len(msg.Images) > 0 {
+			parts := []openAIContentPart{{Type: "text", Text: msg.Content}}
+			for _, image := range msg.Images {
+				url := "data:" + image.ContentType + ";base64," + base64.StdEncoding.EncodeToString(image.Data)
+				parts = append(parts, openAIContentPart{Type: "image_url", ImageURL: &openAIImageURL{URL: url}})
+			}
+			m.Content = parts
+		}
+		for _, call := range msg.ToolCalls {
+			tc := openAIToolCall{ID: call.ID, Type: "function"}
+			tc.Function.Name = call.Name
+			arguments, _ := json.Marshal(call.Arguments)
+			tc.Function.Arguments = string(arguments)
+			m.ToolCalls = append(m.ToolCalls, tc)
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+func toOpenAITools(tools []ToolDefinition) []openAITool {
+	out := make([]openAITool, 0, len(tools))
+	for _, tool := range tools {
+		out = append(out, openAITool{Type: "function", Function: tool})
+	}
+	return out
+}
+
+// applyOptions copies the generation options the OpenAI API understands. The
+// context size is fixed when the server loads the model, so NumCtx is not sent.
+func (r *openAIChatRequest) applyOptions(o *models.GenerationOptions) {
+	if o == nil {
+		return
+	}
+	r.Temperature = o.Temperature
+	r.TopP = o.TopP
+	r.Seed = o.Seed
+	r.Stop = o.Stop
+	r.MaxTokens = o.MaxTokens
+}
+
+type openAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// openAIStreamChunk is one `data:` payload of a streamed chat completion.
+type openAIStreamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content   string                `json:"content"`
+			ToolCalls []openAIToolCallDelta `json:"tool_calls"`
+		} `json:"delta"`
+		FinishReason *string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *openAIUsage     `json:"usage"`
+	Error *openAIErrorBody `json:"error"`
+}
+
+// openAIToolCallDelta is a fragment of a streamed tool call. The ID and name come
+// in the first fragment of each index; the arguments arrive in pieces.
+type openAIToolCallDelta struct {
+	Index    int    `json:"index"`
+	ID       string `json:"id"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+type openAIErrorBody struct {
+	Message string      `json:"message"`
+	Type    string      `json:"type"`
+	Code    interface{} `json:"code"`
+}
+
+type openAIEmbeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type openAIEmbeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float64 `json:"embedding"`
+	} `json:"data"`
+}
+
+type openAIModelsResponse struct {
+	Data []struct {
+		ID      string `json:"id"`
+		Created int64  `json:"created"`
+		OwnedBy string `json:"owned_by"`
+	} `json:"data"`
+}
+
+// decodeOpenAIStream reads the SSE body of a streamed chat completion, passing every
+// content delta to onText, and returns the usage reported by the server, if any, and
+// the tools the model called.
+func decodeOpenAIStream(body io.Reader, onText func(text string) error) (*openAIUsage, []models.ToolCall, error) {
+	var usage *openAIUsage
+	var calls toolCallAccumulator
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue // blank separators, comments and event names
+		}
+
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk openAIStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return nil, nil, fmt.Errorf("error decoding openai stream: %w", err)
+		}
+		if chunk.Error != nil {
+			return nil, nil, chunk.Error.toError(0)
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+
+		for _, choice := range chunk.Choices {
+			for _, delta := range choice.Delta.ToolCalls {
+				if err := calls.add(delta); err != nil {
+					return nil, nil, err
+				}
+			}
+			if choice.Delta.Content == "" {
+				continue
+			}
+			if err := onText(choice.Delta.Content); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("error reading openai stream: %w", err)
+	}
+
+	// Some servers close the stream without sending [DONE].
+	toolCalls, err := calls.toolCalls()
+	if err != nil {
+		return nil, nil, err
+	}
+	return usage, toolCalls, nil
+}
+
+// maxToolCallIndex bounds the tool call indexes accepted from the server.
+const maxToolCallIndex = 64
+
+// toolCallAccumulator joins the streamed fragments of tool calls by index.
+type toolCallAccumulator struct {
+	calls []*openAIToolCallDelta
+}
+
+func (a *toolCallAccumulator) add(delta openAIToolCallDelta) error {
+	if delta.Index < 0 || delta.Index >= maxToolCallIndex {
+		return fmt.Errorf("openai: invalid tool call index %d", delta.Index)
+	}
+	for len(a.calls) <= delta.Index {
+		a.calls = append(a.calls, &openAIToolCallDelta{Index: len(a.calls)})
+	}
+	call := a.calls[delta.Index]
+	if delta.ID != "" {
+		call.ID = delta.ID
+	}
+	call.Function.Name += delta.Function.Name
+	call.Function.Arguments += delta.Function.Arguments
+	return nil
+}
+
+func (a *toolCallAccumulator) toolCalls() ([]models.ToolCall, error) {
+	var toolCalls []models.ToolCall
+	for _, call := range a.calls {
+		if call.Function.Name == "" {
+			continue
+		}
+		arguments := map[string]interface{}{}
+		if strings.TrimSpace(call.Function.Arguments) != "" {
+			if err := json.Unmarshal([]byte(call.Function.Arguments), &arguments); err != nil {
+				return nil, fmt.Errorf("openai: invalid arguments for tool %s: %w", call.Function.Name, err)
+			}
+		}
+		toolCalls = append(toolCalls, models.ToolCall{ID: call.ID, Name: call.Function.Name, Arguments: arguments})
+	}
+	return toolCalls, nil
+}
+
+func (b *openAIErrorBody) toError(statusCode int) *OpenAIError {
+	code := ""
+	if b.Code != nil {
+		code = fmt.Sprint(b.Code)
+	}
+	return &OpenAIError{StatusCode: statusCode, Type: b.Type, Code: code, Message: b.Message}
+}
+
+// checkOpenAIResponse converts an unsuccessful HTTP response into an OpenAIError.
+func checkOpenAIResponse(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+
+	// Servers disagree on whether "error" is an object or a plain string.
+	var objectBody struct {
+		Error *openAIErrorBody `json:"error"`
+	}
+	if err := json.Unmarshal(data, &objectBody); err == nil && objectBody.Error != nil && objectBody.Error.Message != "" {
+		return objectBody.Error.toError(resp.StatusCode)
+	}
+
+	var stringBody struct {
+		Error string `json:"error"`
+	}
+	message := strings.TrimSpace(string(data))
+	if err := json.Unmarshal(data, &stringBody); err == nil && stringBody.Error != "" {
+		message = stringBody.Error
+	}
+	if message == "" {
+		message = http.StatusText(resp.StatusCode)
+	}
+	return &OpenAIError{StatusCode: resp.StatusCode, Message: message}
+}
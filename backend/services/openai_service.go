@@ -0,0 +1,229 @@
+// services/openai_service.go
+
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// OpenAIService is the LLMProvider for any server exposing the OpenAI chat
+// completions API, such as llama.cpp's llama-server, vLLM or LM Studio.
+type OpenAIService struct {
+	// BaseURL includes the version prefix, e.g. http://localhost:1234/v1.
+	BaseURL    string
+	APIKey     string
+	Model      string
+	HTTPClient *http.Client
+}
+
+var (
+	_ LLMProvider = (*OpenAIService)(nil)
+	_ Embedder    = (*OpenAIService)(nil)
+	_ ToolCaller  = (*OpenAIService)(nil)
+)
+
+func NewOpenAIService(baseURL string, apiKey string, model string) *OpenAIService {
+	return &OpenAIService{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		APIKey:     apiKey,
+		Model:      model,
+		HTTPClient: &http.Client{},
+	}
+}
+
+// DetectModel makes the first model served by the endpoint the default model when
+// none is configured. It fails if the endpoint cannot be reached or serves no model.
+func (s *OpenAIService) DetectModel(ctx context.Context) error {
+	if s.Model != "" {
+		return nil
+	}
+	models, err := s.ListModels(ctx)
+	if err != nil {
+		return err
+	}
+	if len(models) == 0 {
+		return fmt.Errorf("%s/models lists no models", s.BaseURL)
+	}
+	s.Model = models[0].Name
+	return nil
+}
+
+func (s *OpenAIService) Name() string {
+	return "openai"
+}
+
+func (s *OpenAIService) DefaultModel() string {
+	return s.Model
+}
+
+// StreamChat streams the reply to a conversation from /chat/completions.
+func (s *OpenAIService) StreamChat(ctx context.Context, req ChatRequest, sendChunk func(chunk string) error) (*ChatResult, error) {
+	payload := openAIChatRequest{
+		Model:         req.Model,
+		Messages:      toOpenAIMessages(req.Messages),
+		Stream:        true,
+		StreamOptions: &openAIStreamOptions{IncludeUsage: true},
+	}
+	payload.applyOptions(req.Options)
+	if len(req.Tools) > 0 {
+		payload.Tools = toOpenAITools(req.Tools)
+	}
+	if len(req.Schema) > 0 {
+		payload.ResponseFormat = &openAIResponseFormat{Type: "json_schema"}
+		payload.ResponseFormat.JSONSchema.Name = "reply"
+		payload.ResponseFormat.JSONSchema.Schema = req.Schema
+	}
+
+	start := time.Now()
+	resp, err := s.do(ctx, http.MethodPost, "/chat/completions", payload)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var firstToken time.Time
+	var out strings.Builder
+	usage, toolCalls, err := decodeOpenAIStream(resp.Body, func(text string) error {
+		if firstToken.IsZero() {
+			firstToken = time.Now()
+		}
+		out.WriteString(text)
+		return sendChunk(text)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// OpenAI-compatible servers do not report timings, so measure them here. The
+	// evaluation starts with the first token; the time before it is prompt processing.
+	stats := &GenerationStats{TotalDuration: time.Since(start)}
+	if !firstToken.IsZero() {
+		stats.EvalDuration = time.Since(firstToken)
+	}
+	if usage != nil {
+		stats.PromptTokens = usage.PromptTokens
+		stats.CompletionTokens = usage.CompletionTokens
+	}
+	return &ChatResult{Content: out.String(), Stats: stats, ToolCalls: toolCalls}, nil
+}
+
+// SupportsTools returns false: the endpoint cannot be asked, and servers such as
+// llama-server without --jinja reject requests with tools. TOOL_CALLING=auto therefore
+// uses prompt-based calling; set TOOL_CALLING=native for servers that support tools.
+func (s *OpenAIService) SupportsTools(ctx context.Context, model string) bool {
+	return false
+}
+
+// Generate returns the full completion for a single prompt, sent as one user message.
+func (s *OpenAIService) Generate(ctx context.Context, model string, prompt string) (string, error) {
+	result, err := s.StreamChat(ctx, ChatRequest{
+		Model:    model,
+		Messages: []ChatMessage{{Role: "user", Content: prompt}},
+	}, func(string) error { return nil })
+	if err != nil {
+		return "", err
+	}
+	return result.Content, nil
+}
+
+// Embed computes embeddings with /embeddings.
+func (s *OpenAIService) Embed(ctx context.Context, model string, inputs []string) ([][]float64, error) {
+	resp, err := s.do(ctx, http.MethodPost, "/embeddings", openAIEmbeddingRequest{Model: model, Input: inputs})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result openAIEmbeddingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	// The data is not guaranteed to be in input order
+	vectors := make([][]float64, len(inputs))
+	for _, d := range result.Data {
+		if d.Index < 0 || d.Index >= len(vectors) {
+			return nil, fmt.Errorf("openai: embedding index %d out of range", d.Index)
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	for i, v := range vectors {
+		if v == nil {
+			return nil, fmt.Errorf("openai: no embedding returned for input %d", i)
+		}
+	}
+	return vectors, nil
+}
+
+// ListModels returns the models served by the endpoint. The OpenAI API does
+// not expose sizes or quantization, so only the names are filled in.
+func (s *OpenAIService) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	resp, err := s.do(ctx, http.MethodGet, "/models", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var list openAIModelsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, err
+	}
+
+	models := make([]ModelInfo, 0, len(list.Data))
+	for _, m := range list.Data {
+		info := ModelInfo{Name: m.ID}
+		if m.Created > 0 {
+			info.ModifiedAt = time.Unix(m.Created, 0)
+		}
+		models = append(models, info)
+	}
+	return models, nil
+}
+
+// Health checks that the endpoint answers the model listing.
+func (s *OpenAIService) Health(ctx context.Context) error {
+	_, err := s.ListModels(ctx)
+	return err
+}
+
+// do sends a request to the OpenAI-compatible API and checks the response status.
+// The caller must close the body of the returned response.
+func (s *OpenAIService) do(ctx context.Context, method string, path string, payload interface{}) (*http.Response, error) {
+	var body *bytes.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(data)
+	} else {
+		body = bytes.NewReader(nil)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, s.BaseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if s.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.APIKey)
+	}
+
+	resp, err := s.HTTPClient.Do(req)
+	if err != nil {
+		return nil, wrapTransportError(err)
+	}
+	if err := checkOpenAIResponse(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp, nil
+}
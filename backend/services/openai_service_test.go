@@ -0,0 +1,244 @@
+// services/openai_service_test.go
+
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// sseBody joins data payloads into an SSE stream.
+func sseBody(payloads ...string) string {
+	var b strings.Builder
+	for _, payload := range payloads {
+		fmt.Fprintf(&b, "data: %s\n\n", payload)
+	}
+	return b.String()
+}
+
+func TestOpenAIStreamChat(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("path = %q", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+			t.Errorf("Authorization = %q", got)
+		}
+		var req openAIChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		if req.Model != "qwen" || !req.Stream || len(req.Tools) != 1 || len(req.Messages) != 1 {
+			t.Errorf("unexpected request %+v", req)
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, ": keep-alive\n\n")
+		fmt.Fprint(w, sseBody(
+			`{"choices":[{"delta":{"content":"Hel"}}]}`,
+			`{"choices":[{"delta":{"content":"lo"}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_1","function":{"name":"calcu","arguments":"{\"expr"}}]}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"name":"late","arguments":"ession\":\"1+1\"}"}}]}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":1,"id":"call_2","function":{"name":"get_time"}}]}}]}`,
+			`{"choices":[],"usage":{"prompt_tokens":12,"completion_tokens":5,"total_tokens":17}}`,
+			`[DONE]`,
+			`{"choices":[{"delta":{"content":"ignored"}}]}`,
+		))
+	}))
+	defer server.Close()
+
+	llm := NewOpenAIService(server.URL+"/v1/", "secret", "qwen")
+	var chunks []string
+	result, err := llm.StreamChat(context.Background(), ChatRequest{
+		Model:    "qwen",
+		Messages: []ChatMessage{{Role: "user", Content: "Hi"}},
+		Tools:    []ToolDefinition{{Name: "calculate"}},
+	}, func(chunk string) error {
+		chunks = append(chunks, chunk)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamChat: %v", err)
+	}
+
+	if !reflect.DeepEqual(chunks, []string{"Hel", "lo"}) {
+		t.Errorf("chunks = %q", chunks)
+	}
+	if result.Content != "Hello" {
+		t.Errorf("content = %q", result.Content)
+	}
+	if result.Stats.PromptTokens != 12 || result.Stats.CompletionTokens != 5 {
+		t.Errorf("stats = %+v", result.Stats)
+	}
+	if len(result.ToolCalls) != 2 {
+		t.Fatalf("tool calls = %+v", result.ToolCalls)
+	}
+	call := result.ToolCalls[0]
+	if call.ID != "call_1" || call.Name != "calculate" || call.Arguments["expression"] != "1+1" {
+		t.Errorf("first tool call = %+v", call)
+	}
+	if call := result.ToolCalls[1]; call.ID != "call_2" || call.Name != "get_time" || len(call.Arguments) != 0 {
+		t.Errorf("second tool call = %+v", call)
+	}
+}
+
+func TestOpenAIStreamChatErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		message  string // Substring of the error
+		notFound bool
+	}{
+		{
+			name:     "object error",
+			status:   http.StatusNotFound,
+			body:     `{"error":{"message":"The model does not exist","type":"invalid_request_error","code":"model_not_found"}}`,
+			message:  "The model does not exist (status 404)",
+			notFound: true,
+		},
+		{
+			name:     "model_not_found code",
+			status:   http.StatusBadRequest,
+			body:     `{"error":{"message":"unknown model","code":"model_not_found"}}`,
+			message:  "unknown model",
+			notFound: true,
+		},
+		{
+			name:    "string error",
+			status:  http.StatusInternalServerError,
+			body:    `{"error":"server overloaded"}`,
+			message: "server overloaded (status 500)",
+		},
+		{
+			name:    "plain text",
+			status:  http.StatusBadGateway,
+			body:    "upstream failed\n",
+			message: "upstream failed (status 502)",
+		},
+		{
+			name:    "empty body",
+			status:  http.StatusServiceUnavailable,
+			message: "Service Unavailable",
+		},
+		{
+			name:    "in-stream error",
+			status:  http.StatusOK,
+			body:    sseBody(`{"choices":[{"delta":{"content":"a"}}]}`, `{"error":{"message":"context overflow"}}`),
+			message: "openai: context overflow",
+		},
+		{
+			name:    "invalid chunk",
+			status:  http.StatusOK,
+			body:    sseBody(`{"choices":`),
+			message: "error decoding openai stream",
+		},
+		{
+			name:    "tool call index out of range",
+			status:  http.StatusOK,
+			body:    sseBody(`{"choices":[{"delta":{"tool_calls":[{"index":100000000,"function":{"name":"x"}}]}}]}`),
+			message: "invalid tool call index 100000000",
+		},
+		{
+			name:    "negative tool call index",
+			status:  http.StatusOK,
+			body:    sseBody(`{"choices":[{"delta":{"tool_calls":[{"index":-1,"function":{"name":"x"}}]}}]}`),
+			message: "invalid tool call index -1",
+		},
+		{
+			name:    "invalid tool arguments",
+			status:  http.StatusOK,
+			body:    sseBody(`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"name":"x","arguments":"{"}}]}}]}`, `[DONE]`),
+			message: "invalid arguments for tool x",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer server.Close()
+
+			llm := NewOpenAIService(server.URL, "", "m")
+			_, err := llm.StreamChat(context.Background(), ChatRequest{Model: "m"}, func(string) error { return nil })
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if !strings.Contains(err.Error(), tt.message) {
+				t.Errorf("error = %q, want it to contain %q", err, tt.message)
+			}
+			if got := errors.Is(err, ErrModelNotFound); got != tt.notFound {
+				t.Errorf("errors.Is(err, ErrModelNotFound) = %v, want %v", got, tt.notFound)
+			}
+		})
+	}
+}
+
+func TestOpenAIUnavailable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	llm := NewOpenAIService(server.URL, "", "m")
+	if err := llm.Health(context.Background()); !errors.Is(err, ErrProviderUnavailable) {
+		t.Errorf("Health = %v, want ErrProviderUnavailable", err)
+	}
+}
+
+func TestOpenAIDetectModel(t *testing.T) {
+	tests := []struct {
+		name       string
+		configured string
+		body       string
+		want       string
+		err        bool
+	}{
+		{name: "first listed model", body: `{"data":[{"id":"qwen2.5-7b"},{"id":"llama-3"}]}`, want: "qwen2.5-7b"},
+		{name: "configured model is kept", configured: "llama-3", body: `{"data":[{"id":"qwen2.5-7b"}]}`, want: "llama-3"},
+		{name: "no models", body: `{"data":[]}`, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/models" {
+					t.Errorf("path = %q", r.URL.Path)
+				}
+				fmt.Fprint(w, tt.body)
+			}))
+			defer server.Close()
+
+			llm := NewOpenAIService(server.URL, "", tt.configured)
+			err := llm.DetectModel(context.Background())
+			if (err != nil) != tt.err {
+				t.Fatalf("DetectModel = %v, want error %v", err, tt.err)
+			}
+			if llm.DefaultModel() != tt.want {
+				t.Errorf("DefaultModel = %q, want %q", llm.DefaultModel(), tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenAIStreamChatWithoutTokens(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, sseBody(`{"choices":[{"delta":{}}]}`, `[DONE]`))
+	}))
+	defer server.Close()
+
+	result, err := NewOpenAIService(server.URL, "", "m").StreamChat(context.Background(), ChatRequest{Model: "m"}, func(string) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Stats.EvalDuration != 0 || result.Stats.TotalDuration <= 0 {
+		t.Errorf("stats = %+v, want a total duration and no evaluation", result.Stats)
+	}
+}
@@ -0,0 +1,182 @@
+// services/persona_service.go
+
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ashuthe1/localmind/logger"
+	"github.com/ashuthe1/localmind/models"
+	"github.com/ashuthe1/localmind/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrDefaultPersona is returned when deleting the default persona.
+var ErrDefaultPersona = errors.New("the default persona cannot be deleted")
+
+// maxFewShotExamples bounds the sample exchanges sent before every conversation.
+const maxFewShotExamples = 10
+
+// defaultPersona is created on first start so existing chats keep their assistant.
+var defaultPersona = models.Persona{
+	Name:         "Smriti",
+	SystemPrompt: "You are Smriti, a helpful AI assistant running completely locally on the user's system with no external dependencies. Answer clearly and concisely.",
+	Greeting:     "Hi {{user}}, it's a pleasure to meet you!\n I'm Smriti, an AI chatbot running completely locally on your system with no external dependencies.",
+	IsDefault:    true,
+}
+
+type PersonaService struct {
+	PersonaRepo *repository.PersonaRepository
+}
+
+func NewPersonaService(personaRepo *repository.PersonaRepository) *PersonaService {
+	return &PersonaService{PersonaRepo: personaRepo}
+}
+
+// EnsureDefaultPersona creates the built-in persona if there is no default persona yet.
+func (s *PersonaService) EnsureDefaultPersona() error {
+	_, err := s.PersonaRepo.GetDefaultPersona()
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return err
+	}
+
+	persona := defaultPersona
+	logger.Log.Infof("Creating default persona %q", persona.Name)
+	return s.PersonaRepo.CreatePersona(&persona)
+}
+
+func (s *PersonaService) GetPersona(id primitive.ObjectID) (*models.Persona, error) {
+	return s.PersonaRepo.GetPersonaByID(id)
+}
+
+func (s *PersonaService) ListPersonas() ([]models.Persona, error) {
+	return s.PersonaRepo.GetAllPersonas()
+}
+
+// DefaultPersona returns the persona used by chats that do not reference one.
+func (s *PersonaService) DefaultPersona() (*models.Persona, error) {
+	return s.PersonaRepo.GetDefaultPersona()
+}
+
+// PersonaForChat returns the chat's persona, falling back to the default persona when
+// the chat has none or it was deleted. It returns nil if neither exists.
+func (s *PersonaService) PersonaForChat(chat *models.Chat) *models.Persona {
+	if !chat.PersonaID.IsZero() {
+		persona, err := s.PersonaRepo.GetPersonaByID(chat.PersonaID)
+		if err == nil {
+			return persona
+		}
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			logger.Log.Errorf("Error loading persona %s: %v", chat.PersonaID.Hex(), err)
+		}
+	}
+
+	persona, err := s.PersonaRepo.GetDefaultPersona()
+	if err != nil {
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			logger.Log.Errorf("Error loading default persona: %v", err)
+		}
+		return nil
+	}
+	return persona
+}
+
+// CreatePersona stores a new persona. Marking it as default unmarks the previous default.
+func (s *PersonaService) CreatePersona(persona *models.Persona) error {
+	if persona.IsDefault {
+		if err := s.PersonaRepo.ClearDefaultPersona(); err != nil {
+			return err
+		}
+	}
+	return s.PersonaRepo.CreatePersona(persona)
+}
+
+// UpdatePersona stores the changes to a persona. There is always a default persona: it
+// stops being the default only when another one is marked.
+func (s *PersonaService) UpdatePersona(persona *models.Persona) error {
+	existing, err := s.PersonaRepo.GetPersonaByID(persona.ID)
+	if err != nil {
+		return err
+	}
+
+	if existing.IsDefault {
+		persona.IsDefault = true
+	} else if persona.IsDefault {
+		if err := s.PersonaRepo.ClearDefaultPersona(); err != nil {
+			return err
+		}
+	}
+	return s.PersonaRepo.UpdatePersona(persona)
+}
+
+// DeletePersona deletes a persona. Chats using it fall back to the default persona.
+func (s *PersonaService) DeletePersona(id primitive.ObjectID) error {
+	persona, err := s.PersonaRepo.GetPersonaByID(id)
+	if err != nil {
+		return err
+	}
+	if persona.IsDefault {
+		return ErrDefaultPersona
+	}
+	return s.PersonaRepo.DeletePersona(id)
+}
+
+// ValidatePersona checks the fields a client can set and trims the name. Errors are
+// meant for the client.
+func ValidatePersona(persona *models.Persona) error {
+	persona.Name = strings.TrimSpace(persona.Name)
+	if persona.Name == "" {
+		return errors.New("name is required")
+	}
+	if err := ValidateOptions(persona.Options); err != nil {
+		return fmt.Errorf("options: %w", err)
+	}
+	if len(persona.Examples) > maxFewShotExamples {
+		return fmt.Errorf("at most %d examples are allowed", maxFewShotExamples)
+	}
+	for i, example := range persona.Examples {
+		if strings.TrimSpace(example.User) == "" || strings.TrimSpace(example.Assistant) == "" {
+			return fmt.Errorf("example %d needs both a user and an assistant message", i+1)
+		}
+	}
+	if len(persona.Schema) > 0 {
+		if err := CheckSchema(persona.Schema); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Greeting returns the persona's first message for a new chat with the user.
+func Greeting(persona *models.Persona, username string) string {
+	return strings.ReplaceAll(persona.Greeting, "{{user}}", username)
+}
+
+// PersonaPreamble builds the messages sent before the conversation on every turn: the
+// persona's system prompt together with the user's context, then its examples.
+func PersonaPreamble(persona *models.Persona, userContext string) []ChatMessage {
+	var system []string
+	if persona != nil && strings.TrimSpace(persona.SystemPrompt) != "" {
+		system = append(system, strings.TrimSpace(persona.SystemPrompt))
+	}
+	if userContext != "" {
+		system = append(system, userContext)
+	}
+
+	var preamble []ChatMessage
+	if len(system) > 0 {
+		preamble = append(preamble, ChatMessage{Role: "system", Content: strings.Join(system, "\n\n")})
+	}
+	if persona != nil {
+		for _, example := range persona.Examples {
+			preamble = append(preamble,
+				ChatMessage{Role: "user", Content: example.User},
+				ChatMessage{Role: "assistant", Content: example.Assistant},
+			)
+		}
+	}
+	return preamble
+}
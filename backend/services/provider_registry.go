@@ -0,0 +1,56 @@
+// services/provider_registry.go
+
+package services
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// ProviderRegistry holds the available LLM providers keyed by name.
+type ProviderRegistry struct {
+	mu        sync.RWMutex
+	providers map[string]LLMProvider
+}
+
+func NewProviderRegistry() *ProviderRegistry {
+	return &ProviderRegistry{
+		providers: make(map[string]LLMProvider),
+	}
+}
+
+// Register adds a provider under its Name, replacing any previous one.
+func (r *ProviderRegistry) Register(provider LLMProvider) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.providers[provider.Name()] = provider
+}
+
+// Get returns the provider registered under name.
+func (r *ProviderRegistry) Get(name string) (LLMProvider, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	provider, ok := r.providers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown llm provider %q (available: %v)", name, r.namesLocked())
+	}
+	return provider, nil
+}
+
+// Names returns the registered provider names in sorted order.
+func (r *ProviderRegistry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.namesLocked()
+}
+
+func (r *ProviderRegistry) namesLocked() []string {
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
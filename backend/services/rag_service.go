@@ -0,0 +1,389 @@
+// services/rag_service.go
+
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ashuthe1/localmind/logger"
+	"github.com/ashuthe1/localmind/models"
+	"github.com/ashuthe1/localmind/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	// ErrEmbeddingsUnsupported is returned when the LLM provider cannot compute embeddings.
+	ErrEmbeddingsUnsupported = errors.New("the LLM provider cannot compute embeddings")
+	// ErrUnsupportedDocument is returned for uploads that are not text, markdown or source files.
+	ErrUnsupportedDocument = errors.New("unsupported document type")
+)
+
+// embedBatchSize is the number of chunks embedded per request.
+const embedBatchSize = 32
+
+// documentTypes maps the accepted file extensions to the stored content type.
+var documentTypes = map[string]string{
+	".txt": "text/plain", ".text": "text/plain", ".log": "text/plain", ".csv": "text/csv",
+	".md": "text/markdown", ".markdown": "text/markdown", ".rst": "text/x-rst",
+	".go": "text/x-source", ".py": "text/x-source", ".js": "text/x-source", ".jsx": "text/x-source",
+	".ts": "text/x-source", ".tsx": "text/x-source", ".java": "text/x-source", ".kt": "text/x-source",
+	".c": "text/x-source", ".h": "text/x-source", ".cpp": "text/x-source", ".hpp": "text/x-source",
+	".cs": "text/x-source", ".rs": "text/x-source", ".rb": "text/x-source", ".php": "text/x-source",
+	".swift": "text/x-source", ".scala": "text/x-source", ".sh": "text/x-source", ".sql": "text/x-source",
+	".html": "text/x-source", ".css": "text/x-source", ".json": "text/x-source", ".yaml": "text/x-source",
+	".yml": "text/x-source", ".toml": "text/x-source", ".xml": "text/x-source",
+}
+
+// RetrievedChunk is a document chunk that matched a query.
+type RetrievedChunk struct {
+	Chunk        models.DocumentChunk
+	DocumentName string
+	Score        float64 // Cosine similarity to the query
+}
+
+// RAGService ingests documents into collections and retrieves the chunks most
+// similar to a query, for retrieval-augmented answers.
+type RAGService struct {
+	DocumentRepo    *repository.DocumentRepository
+	LLM             LLMProvider
+	EmbeddingModel  string // Used for new collections
+	ChunkSize       int    // Defaults for uploads, in bytes
+	ChunkOverlap    int
+	TopK            int
+	MaxDocumentSize int64 // Largest accepted upload, in bytes
+}
+
+func NewRAGService(documentRepo *repository.DocumentRepository, llm LLMProvider, embeddingModel string, chunkSize int, chunkOverlap int, topK int, maxDocumentSize int64) *RAGService {
+	return &RAGService{
+		DocumentRepo:    documentRepo,
+		LLM:             llm,
+		EmbeddingModel:  embeddingModel,
+		ChunkSize:       chunkSize,
+		ChunkOverlap:    chunkOverlap,
+		TopK:            topK,
+		MaxDocumentSize: maxDocumentSize,
+	}
+}
+
+// CreateCollection creates an empty collection embedded with the configured model.
+func (s *RAGService) CreateCollection(name string, description string) (*models.Collection, error) {
+	collection := &models.Collection{
+		Name:           name,
+		Description:    description,
+		EmbeddingModel: s.EmbeddingModel,
+	}
+	if err := s.DocumentRepo.CreateCollection(collection); err != nil {
+		return nil, err
+	}
+	return collection, nil
+}
+
+func (s *RAGService) GetCollection(id primitive.ObjectID) (*models.Collection, error) {
+	return s.DocumentRepo.GetCollectionByID(id)
+}
+
+func (s *RAGService) ListCollections() ([]models.Collection, error) {
+	return s.DocumentRepo.GetAllCollections()
+}
+
+// DeleteCollection deletes a collection with its documents. Chats attached to it
+// simply stop retrieving from it.
+func (s *RAGService) DeleteCollection(id primitive.ObjectID) error {
+	return s.DocumentRepo.DeleteCollection(id)
+}
+
+func (s *RAGService) ListDocuments(collectionID primitive.ObjectID) ([]models.Document, error) {
+	return s.DocumentRepo.GetDocumentsByCollection(collectionID)
+}
+
+func (s *RAGService) GetDocument(id primitive.ObjectID) (*models.Document, error) {
+	return s.DocumentRepo.GetDocumentByID(id)
+}
+
+func (s *RAGService) DeleteDocument(id primitive.ObjectID) error {
+	document, err := s.DocumentRepo.GetDocumentByID(id)
+	if err != nil {
+		return err
+	}
+	if err := s.DocumentRepo.DeleteDocument(id); err != nil {
+		return err
+	}
+	return s.DocumentRepo.TouchCollection(document.CollectionID)
+}
+
+// IngestDocument chunks and embeds an uploaded file and stores it in a collection.
+// A chunk size of 0 uses the configured defaults.
+func (s *RAGService) IngestDocument(ctx context.Context, collectionID primitive.ObjectID, name string, content []byte, chunkSize int, chunkOverlap int) (*models.Document, error) {
+	contentType, err := DocumentContentType(name, content)
+	if err != nil {
+		return nil, err
+	}
+	if chunkSize == 0 {
+		chunkSize, chunkOverlap = s.ChunkSize, s.ChunkOverlap
+	}
+	if err := ValidateChunking(chunkSize, chunkOverlap); err != nil {
+		return nil, err
+	}
+
+	collection, err := s.DocumentRepo.GetCollectionByID(collectionID)
+	if err != nil {
+		return nil, err
+	}
+
+	text := string(content)
+	pieces := ChunkText(text, chunkSize, chunkOverlap)
+	if len(pieces) == 0 {
+		return nil, fmt.Errorf("%w: %s is empty", ErrUnsupportedDocument, name)
+	}
+	chunks := make([]models.DocumentChunk, len(pieces))
+	for i, piece := range pieces {
+		chunks[i] = models.DocumentChunk{Index: i, Start: piece.Start, End: piece.End, Text: piece.Text}
+	}
+
+	for start := 0; start < len(chunks); start += embedBatchSize {
+		end := start + embedBatchSize
+		if end > len(chunks) {
+			end = len(chunks)
+		}
+		inputs := make([]string, 0, end-start)
+		for _, chunk := range chunks[start:end] {
+			inputs = append(inputs, chunk.Text)
+		}
+		vectors, err := s.embed(ctx, collection.EmbeddingModel, inputs)
+		if err != nil {
+			return nil, fmt.Errorf("error embedding %s: %w", name, err)
+		}
+		for i, vector := range vectors {
+			chunks[start+i].Embedding = vector
+		}
+	}
+
+	document := &models.Document{
+		CollectionID: collectionID,
+		Name:         name,
+		ContentType:  contentType,
+		Size:         len(content),
+		Content:      text,
+	}
+	if err := s.DocumentRepo.CreateDocument(document, chunks); err != nil {
+		return nil, err
+	}
+	if err := s.DocumentRepo.TouchCollection(collectionID); err != nil {
+		logger.Log.Errorf("Error updating collection %s: %v", collectionID.Hex(), err)
+	}
+
+	logger.Log.Infof("Ingested %s into collection %q: %d chunks", name, collection.Name, len(chunks))
+	return document, nil
+}
+
+// Retrieve returns the k chunks of the given collections most similar to the query,
+// best first. Collections that no longer exist are skipped.
+func (s *RAGService) Retrieve(ctx context.Context, collectionIDs []primitive.ObjectID, query string, k int) ([]RetrievedChunk, error) {
+	if k <= 0 {
+		k = s.TopK
+	}
+	if len(collectionIDs) == 0 || strings.TrimSpace(query) == "" || k <= 0 {
+		return nil, nil
+	}
+
+	// Only the best k chunks are kept while scoring; their texts are loaded afterwards
+	best := newTopScores(k)
+	queryVectors := make(map[string][]float64) // By embedding model
+	for _, id := range collectionIDs {
+		collection, err := s.DocumentRepo.GetCollectionByID(id)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		queryVector, ok := queryVectors[collection.EmbeddingModel]
+		if !ok {
+			vectors, err := s.embed(ctx, collection.EmbeddingModel, []string{query})
+			if err != nil {
+				return nil, err
+			}
+			queryVector = vectors[0]
+			queryVectors[collection.EmbeddingModel] = queryVector
+		}
+
+		err = s.DocumentRepo.EachChunkEmbedding(ctx, id, func(chunkID primitive.ObjectID, embedding []float64) {
+			best.Add(chunkID, CosineSimilarity(queryVector, embedding))
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(best.Items) == 0 {
+		return nil, nil
+	}
+
+	chunkIDs := make([]primitive.ObjectID, len(best.Items))
+	for i, item := range best.Items {
+		chunkIDs[i] = item.ID
+	}
+	chunks, err := s.DocumentRepo.GetChunksByIDs(chunkIDs)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[primitive.ObjectID]models.DocumentChunk, len(chunks))
+	var documentIDs []primitive.ObjectID
+	for _, chunk := range chunks {
+		byID[chunk.ID] = chunk
+		documentIDs = append(documentIDs, chunk.DocumentID)
+	}
+	names, err := s.DocumentRepo.GetDocumentNames(documentIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Chunks deleted since they were scored are skipped
+	results := make([]RetrievedChunk, 0, len(best.Items))
+	for _, item := range best.Items {
+		chunk, ok := byID[item.ID]
+		if !ok {
+			continue
+		}
+		results = append(results, RetrievedChunk{Chunk: chunk, DocumentName: names[chunk.DocumentID], Score: item.Score})
+	}
+	return results, nil
+}
+
+func (s *RAGService) embed(ctx context.Context, model string, inputs []string) ([][]float64, error) {
+	embedder, ok := s.LLM.(Embedder)
+	if !ok {
+		return nil, ErrEmbeddingsUnsupported
+	}
+	return embedder.Embed(ctx, model, inputs)
+}
+
+// Citations describes retrieved chunks for storing on a reply, numbered as in
+// FormatRetrievedContext.
+func Citations(chunks []RetrievedChunk) []models.Citation {
+	citations := make([]models.Citation, 0, len(chunks))
+	for i, chunk := range chunks {
+		citations = append(citations, models.Citation{
+			Number:       i + 1,
+			DocumentID:   chunk.Chunk.DocumentID,
+			DocumentName: chunk.DocumentName,
+			ChunkID:      chunk.Chunk.ID,
+			Start:        chunk.Chunk.Start,
+			End:          chunk.Chunk.End,
+			Score:        chunk.Score,
+		})
+	}
+	return citations
+}
+
+// DocumentSpan returns the text of a document between two byte offsets, widened by
+// up to contextBytes on each side, with the offsets actually used. Offsets are moved
+// off UTF-8 continuation bytes.
+func DocumentSpan(document *models.Document, start int, end int, contextBytes int) (string, int, int, error) {
+	content := document.Content
+	if start < 0 || end > len(content) || start > end {
+		return "", 0, 0, fmt.Errorf("span %d-%d is outside the document (%d bytes)", start, end, len(content))
+	}
+
+	start -= contextBytes
+	if start < 0 {
+		start = 0
+	}
+	end += contextBytes
+	if end > len(content) {
+		end = len(content)
+	}
+	for start > 0 && !utf8.RuneStart(content[start]) {
+		start--
+	}
+	for end < len(content) && !utf8.RuneStart(content[end]) {
+		end++
+	}
+	return content[start:end], start, end, nil
+}
+
+// FormatRetrievedContext renders retrieved chunks as a system message that asks the
+// model to use and cite them by number.
+func FormatRetrievedContext(chunks []RetrievedChunk) string {
+	if len(chunks) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("The following excerpts from the user's documents may help answer the question. ")
+	b.WriteString("Use them when relevant and cite them by number, e.g. [1]. ")
+	b.WriteString("If they do not contain the answer, say so instead of guessing.\n")
+	for i, chunk := range chunks {
+		fmt.Fprintf(&b, "\n[%d] %s\n%s\n", i+1, chunk.DocumentName, chunk.Chunk.Text)
+	}
+	return b.String()
+}
+
+// DocumentContentType returns the content type stored for an upload, or
+// ErrUnsupportedDocument if it is not a UTF-8 text, markdown or source file.
+func DocumentContentType(name string, content []byte) (string, error) {
+	contentType, ok := documentTypes[strings.ToLower(filepath.Ext(name))]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedDocument, filepath.Ext(name))
+	}
+	if !utf8.Valid(content) || strings.ContainsRune(string(content), 0) {
+		return "", fmt.Errorf("%w: %s is not a UTF-8 text file", ErrUnsupportedDocument, name)
+	}
+	return contentType, nil
+}
+
+// scoredID is an item scored for similarity to a query.
+type scoredID struct {
+	ID    primitive.ObjectID
+	Score float64
+}
+
+// topScores keeps the k highest scores added to it, best first, so a search does not
+// hold every candidate in memory.
+type topScores struct {
+	k     int
+	Items []scoredID
+}
+
+func newTopScores(k int) *topScores {
+	return &topScores{k: k, Items: make([]scoredID, 0, k)}
+}
+
+// Add records a score if it is among the k best so far. Ties keep the earlier item.
+func (t *topScores) Add(id primitive.ObjectID, score float64) {
+	if t.k <= 0 || len(t.Items) == t.k && score <= t.Items[len(t.Items)-1].Score {
+		return
+	}
+	i := sort.Search(len(t.Items), func(i int) bool { return t.Items[i].Score < score })
+	if len(t.Items) < t.k {
+		t.Items = append(t.Items, scoredID{})
+	}
+	copy(t.Items[i+1:], t.Items[i:])
+	t.Items[i] = scoredID{ID: id, Score: score}
+}
+
+// CosineSimilarity returns the cosine of the angle between two vectors, or 0 when
+// their lengths differ or either is zero.
+func CosineSimilarity(a []float64, b []float64) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
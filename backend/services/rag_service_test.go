@@ -0,0 +1,48 @@
+// services/rag_service_test.go
+
+package services
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestTopScores(t *testing.T) {
+	ids := make([]primitive.ObjectID, 6)
+	for i := range ids {
+		ids[i] = primitive.NewObjectID()
+	}
+
+	tests := []struct {
+		name   string
+		k      int
+		scores []float64 // Added in order, for ids[0], ids[1], ...
+		want   []int     // Indexes into ids, best first
+	}{
+		{"empty", 3, nil, nil},
+		{"fewer than k", 3, []float64{0.2, 0.9}, []int{1, 0}},
+		{"keeps the best", 3, []float64{0.1, 0.5, 0.3, 0.9, 0.2, 0.4}, []int{3, 1, 5}},
+		{"ties keep the earlier item", 2, []float64{0.5, 0.7, 0.5, 0.7}, []int{1, 3}},
+		{"negative scores", 2, []float64{-0.5, -0.1, -0.9}, []int{1, 0}},
+		{"zero k", 0, []float64{0.5}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			top := newTopScores(tt.k)
+			for i, score := range tt.scores {
+				top.Add(ids[i], score)
+			}
+
+			if len(top.Items) != len(tt.want) {
+				t.Fatalf("got %d items, want %d", len(top.Items), len(tt.want))
+			}
+			for i, idx := range tt.want {
+				if top.Items[i].ID != ids[idx] || top.Items[i].Score != tt.scores[idx] {
+					t.Errorf("item %d = %v, want id %d with score %v", i, top.Items[i], idx, tt.scores[idx])
+				}
+			}
+		})
+	}
+}
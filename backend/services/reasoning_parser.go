@@ -0,0 +1,128 @@
+// services/reasoning_parser.go
+
+package services
+
+import (
+	"strings"
+)
+
+// Kinds of text a ReasoningParser emits. They double as the SSE event names.
+const (
+	SegmentReasoning = "reasoning"
+	SegmentAnswer    = "answer"
+)
+
+const (
+	thinkOpenTag  = "<think>"
+	thinkCloseTag = "</think>"
+)
+
+// Segment is a run of streamed text that is either reasoning or answer.
+type Segment struct {
+	Kind string
+	Text string
+}
+
+// ReasoningParser splits a streamed reply from a reasoning model such as deepseek-r1
+// into the text inside <think>...</think> and the answer around it. Tags may be split
+// across chunks, so text that could be the start of a tag is held back until the next
+// chunk decides it.
+type ReasoningParser struct {
+	inThink bool
+	pending string
+	// trimAnswer drops the blank lines the model puts between </think> and the answer.
+	trimAnswer bool
+}
+
+// Feed consumes one streamed chunk and returns the segments that are now complete.
+func (p *ReasoningParser) Feed(chunk string) []Segment {
+	buf := p.pending + chunk
+	p.pending = ""
+
+	var segments []Segment
+	for buf != "" {
+		tag := thinkOpenTag
+		if p.inThink {
+			tag = thinkCloseTag
+		}
+
+		if idx := strings.Index(buf, tag); idx >= 0 {
+			segments = p.appendText(segments, buf[:idx])
+			buf = buf[idx+len(tag):]
+			p.inThink = !p.inThink
+			p.trimAnswer = !p.inThink
+			continue
+		}
+
+		// Hold back a trailing partial tag such as "<th" until more text arrives.
+		keep := partialTagSuffix(buf, tag)
+		segments = p.appendText(segments, buf[:len(buf)-keep])
+		p.pending = buf[len(buf)-keep:]
+		break
+	}
+	return segments
+}
+
+// Flush returns any text still held back once the stream has ended.
+func (p *ReasoningParser) Flush() []Segment {
+	text := p.pending
+	p.pending = ""
+	return p.appendText(nil, text)
+}
+
+func (p *ReasoningParser) appendText(segments []Segment, text string) []Segment {
+	kind := SegmentAnswer
+	if p.inThink {
+		kind = SegmentReasoning
+	} else if p.trimAnswer {
+		text = strings.TrimLeft(text, " \t\r\n")
+		if text != "" {
+			p.trimAnswer = false
+		}
+	}
+
+	if text == "" {
+		return segments
+	}
+	// Merge with the previous segment of the same kind to keep event counts low.
+	if n := len(segments); n > 0 && segments[n-1].Kind == kind {
+		segments[n-1].Text += text
+		return segments
+	}
+	return append(segments, Segment{Kind: kind, Text: text})
+}
+
+// partialTagSuffix returns the length of the longest suffix of s that is a proper
+// prefix of tag.
+func partialTagSuffix(s string, tag string) int {
+	max := len(tag) - 1
+	if len(s) < max {
+		max = len(s)
+	}
+	for n := max; n > 0; n-- {
+		if strings.HasSuffix(s, tag[:n]) {
+			return n
+		}
+	}
+	return 0
+}
+
+// SplitReasoning separates a complete reply into its reasoning and its answer.
+func SplitReasoning(text string) (reasoning string, answer string) {
+	var parser ReasoningParser
+	var r, a strings.Builder
+	for _, segment := range append(parser.Feed(text), parser.Flush()...) {
+		if segment.Kind == SegmentReasoning {
+			r.WriteString(segment.Text)
+		} else {
+			a.WriteString(segment.Text)
+		}
+	}
+	return strings.TrimSpace(r.String()), a.String()
+}
+
+// StripReasoning returns only the answer part of a complete reply.
+func StripReasoning(text string) string {
+	_, answer := SplitReasoning(text)
+	return answer
+}
@@ -0,0 +1,106 @@
+// services/reasoning_parser_test.go
+
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+// feedAll streams chunks through a parser and returns the merged segments.
+func feedAll(chunks []string) []Segment {
+	var parser ReasoningParser
+	var segments []Segment
+	for _, chunk := range chunks {
+		segments = append(segments, parser.Feed(chunk)...)
+	}
+	segments = append(segments, parser.Flush()...)
+
+	var merged []Segment
+	for _, segment := range segments {
+		if n := len(merged); n > 0 && merged[n-1].Kind == segment.Kind {
+			merged[n-1].Text += segment.Text
+			continue
+		}
+		merged = append(merged, segment)
+	}
+	return merged
+}
+
+func TestReasoningParser(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunks []string
+		want   []Segment
+	}{
+		{
+			name:   "no reasoning",
+			chunks: []string{"Hello ", "world"},
+			want:   []Segment{{SegmentAnswer, "Hello world"}},
+		},
+		{
+			name:   "reasoning then answer",
+			chunks: []string{"<think>", "Let me see", "</think>", "\n\nHi"},
+			want:   []Segment{{SegmentReasoning, "Let me see"}, {SegmentAnswer, "Hi"}},
+		},
+		{
+			name:   "tags split across chunks",
+			chunks: []string{"<th", "ink>plan", "</thi", "nk>", "\n", "answer"},
+			want:   []Segment{{SegmentReasoning, "plan"}, {SegmentAnswer, "answer"}},
+		},
+		{
+			name:   "one byte at a time",
+			chunks: []string{"<", "t", "h", "i", "n", "k", ">", "a", "<", "/", "t", "h", "i", "n", "k", ">", "b"},
+			want:   []Segment{{SegmentReasoning, "a"}, {SegmentAnswer, "b"}},
+		},
+		{
+			name:   "partial tag that is not a tag",
+			chunks: []string{"a <th", "ese> b"},
+			want:   []Segment{{SegmentAnswer, "a <these> b"}},
+		},
+		{
+			name:   "partial tag at the end of the stream",
+			chunks: []string{"5 <"},
+			want:   []Segment{{SegmentAnswer, "5 <"}},
+		},
+		{
+			name:   "unterminated reasoning",
+			chunks: []string{"<think>still thinking</th"},
+			want:   []Segment{{SegmentReasoning, "still thinking</th"}},
+		},
+		{
+			name:   "answer before reasoning keeps its whitespace",
+			chunks: []string{"  intro ", "<think>x</think>", "  ", "outro"},
+			want:   []Segment{{SegmentAnswer, "  intro "}, {SegmentReasoning, "x"}, {SegmentAnswer, "outro"}},
+		},
+		{
+			name:   "empty reasoning",
+			chunks: []string{"<think></think>\n\nanswer"},
+			want:   []Segment{{SegmentAnswer, "answer"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := feedAll(tt.chunks); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("segments = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitReasoning(t *testing.T) {
+	tests := []struct {
+		text, reasoning, answer string
+	}{
+		{"plain answer", "", "plain answer"},
+		{"<think>\n step one \n</think>\n\nThe answer", "step one", "The answer"},
+		{"<think>unfinished", "unfinished", ""},
+	}
+	for _, tt := range tests {
+		reasoning, answer := SplitReasoning(tt.text)
+		if reasoning != tt.reasoning || answer != tt.answer {
+			t.Errorf("SplitReasoning(%q) = %q, %q, want %q, %q", tt.text, reasoning, answer, tt.reasoning, tt.answer)
+		}
+	}
+}
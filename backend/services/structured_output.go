@@ -0,0 +1,84 @@
+// services/structured_output.go
+
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ashuthe1/localmind/models"
+)
+
+// StructuredOutputService asks the model for JSON matching a schema and sends
+// invalid replies back to it with the validation errors.
+type StructuredOutputService struct {
+	// MaxRetries is how many times an invalid reply is re-prompted before giving up.
+	MaxRetries int
+}
+
+func NewStructuredOutputService(maxRetries int) *StructuredOutputService {
+	if maxRetries < 0 {
+		maxRetries = 0
+	}
+	return &StructuredOutputService{MaxRetries: maxRetries}
+}
+
+// SchemaPrompt is the system message telling the model to answer with JSON only.
+// Providers that support it also constrain the output to the schema.
+func (s *StructuredOutputService) SchemaPrompt(schema models.RawJSON) ChatMessage {
+	return ChatMessage{
+		Role:    "system",
+		Content: "Respond only with a JSON value that matches this JSON schema, without any other text or code fences:\n" + string(schema),
+	}
+}
+
+// RepairPrompt is the user message asking the model to fix an invalid reply.
+func (s *StructuredOutputService) RepairPrompt(problems []string) ChatMessage {
+	return ChatMessage{
+		Role:    "user",
+		Content: "Your reply does not match the JSON schema:\n- " + strings.Join(problems, "\n- ") + "\nReply again with only the corrected JSON.",
+	}
+}
+
+// Parse extracts the JSON value from a reply and validates it against the schema. It
+// returns the compacted JSON, or the problems to report back to the model.
+func (s *StructuredOutputService) Parse(schema models.RawJSON, reply string) (models.RawJSON, []string) {
+	text := extractJSON(reply)
+
+	var value interface{}
+	if err := json.Unmarshal([]byte(text), &value); err != nil {
+		return nil, []string{"the reply is not valid JSON: " + err.Error()}
+	}
+	if problems := ValidateJSON(schema, value); len(problems) > 0 {
+		return nil, problems
+	}
+
+	var compacted bytes.Buffer
+	if err := json.Compact(&compacted, []byte(text)); err != nil {
+		return nil, []string{fmt.Sprintf("the reply is not valid JSON: %v", err)}
+	}
+	return models.RawJSON(compacted.Bytes()), nil
+}
+
+// extractJSON strips the code fence or surrounding prose models like to add around JSON.
+func extractJSON(reply string) string {
+	text := strings.TrimSpace(reply)
+	if strings.HasPrefix(text, "```") {
+		if newline := strings.IndexByte(text, '\n'); newline >= 0 {
+			text = text[newline+1:]
+		}
+		text = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(text), "```"))
+	}
+	if json.Valid([]byte(text)) {
+		return text
+	}
+
+	start := strings.IndexAny(text, "{[")
+	end := strings.LastIndexAny(text, "}]")
+	if start >= 0 && end > start {
+		return text[start : end+1]
+	}
+	return text
+}
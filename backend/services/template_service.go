@@ -0,0 +1,261 @@
+// services/template_service.go
+
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/ashuthe1/localmind/models"
+	"github.com/ashuthe1/localmind/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	// ErrTemplateExists is returned when another template already has the name.
+	ErrTemplateExists = errors.New("a template with this name already exists")
+	// ErrMissingVariables is returned when rendering without a required variable.
+	ErrMissingVariables = errors.New("missing required variables")
+)
+
+// TemplateExportVersion is the version of the JSON format written by ExportTemplates.
+const TemplateExportVersion = 1
+
+// placeholderPattern matches {{name}}, allowing spaces inside the braces.
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+var variableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// TemplateExport is the JSON document used to share templates between installations.
+// Database IDs and persona references are local, so they are not exported.
+type TemplateExport struct {
+	Version   int                `json:"version"`
+	Templates []ExportedTemplate `json:"templates"`
+}
+
+// ExportedTemplate is a PromptTemplate without its installation-specific fields.
+type ExportedTemplate struct {
+	Name        string                    `json:"name"`
+	Description string                    `json:"description,omitempty"`
+	Content     string                    `json:"content"`
+	Variables   []models.TemplateVariable `json:"variables,omitempty"`
+	Model       string                    `json:"model,omitempty"`
+}
+
+// TemplateImportResult lists what an import did, by template name.
+type TemplateImportResult struct {
+	Created []string `json:"created"`
+	Updated []string `json:"updated"`
+	Skipped []string `json:"skipped"` // Names that already existed, when not overwriting
+}
+
+type TemplateService struct {
+	TemplateRepo *repository.TemplateRepository
+}
+
+func NewTemplateService(templateRepo *repository.TemplateRepository) *TemplateService {
+	return &TemplateService{TemplateRepo: templateRepo}
+}
+
+func (s *TemplateService) GetTemplate(id primitive.ObjectID) (*models.PromptTemplate, error) {
+	return s.TemplateRepo.GetTemplateByID(id)
+}
+
+func (s *TemplateService) ListTemplates() ([]models.PromptTemplate, error) {
+	return s.TemplateRepo.GetAllTemplates()
+}
+
+// CreateTemplate stores a new template. Names are unique.
+func (s *TemplateService) CreateTemplate(template *models.PromptTemplate) error {
+	if err := s.checkNameFree(template.Name, primitive.NilObjectID); err != nil {
+		return err
+	}
+	return s.TemplateRepo.CreateTemplate(template)
+}
+
+// UpdateTemplate stores the changes to a template.
+func (s *TemplateService) UpdateTemplate(template *models.PromptTemplate) error {
+	if err := s.checkNameFree(template.Name, template.ID); err != nil {
+		return err
+	}
+	return s.TemplateRepo.UpdateTemplate(template)
+}
+
+func (s *TemplateService) DeleteTemplate(id primitive.ObjectID) error {
+	return s.TemplateRepo.DeleteTemplate(id)
+}
+
+// ExportTemplates returns every template in the sharing format.
+func (s *TemplateService) ExportTemplates() (*TemplateExport, error) {
+	templates, err := s.TemplateRepo.GetAllTemplates()
+	if err != nil {
+		return nil, err
+	}
+
+	export := &TemplateExport{Version: TemplateExportVersion, Templates: []ExportedTemplate{}}
+	for _, t := range templates {
+		export.Templates = append(export.Templates, ExportedTemplate{
+			Name:        t.Name,
+			Description: t.Description,
+			Content:     t.Content,
+			Variables:   t.Variables,
+			Model:       t.Model,
+		})
+	}
+	return export, nil
+}
+
+// ImportTemplates stores the templates of an export. Templates whose name already
+// exists are replaced when overwrite is set and skipped otherwise. Nothing is stored
+// unless every template is valid.
+func (s *TemplateService) ImportTemplates(export *TemplateExport, overwrite bool) (*TemplateImportResult, error) {
+	if export.Version > TemplateExportVersion {
+		return nil, fmt.Errorf("unsupported export version %d", export.Version)
+	}
+
+	imported := make([]models.PromptTemplate, 0, len(export.Templates))
+	seen := make(map[string]bool)
+	for i, t := range export.Templates {
+		template := models.PromptTemplate{
+			Name:        t.Name,
+			Description: t.Description,
+			Content:     t.Content,
+			Variables:   t.Variables,
+			Model:       t.Model,
+		}
+		if err := ValidateTemplate(&template); err != nil {
+			return nil, fmt.Errorf("template %d (%q): %w", i+1, t.Name, err)
+		}
+		if seen[template.Name] {
+			return nil, fmt.Errorf("template %q appears more than once", template.Name)
+		}
+		seen[template.Name] = true
+		imported = append(imported, template)
+	}
+
+	result := &TemplateImportResult{Created: []string{}, Updated: []string{}, Skipped: []string{}}
+	for i := range imported {
+		template := &imported[i]
+		existing, err := s.TemplateRepo.GetTemplateByName(template.Name)
+		switch {
+		case errors.Is(err, mongo.ErrNoDocuments):
+			if err := s.TemplateRepo.CreateTemplate(template); err != nil {
+				return result, err
+			}
+			result.Created = append(result.Created, template.Name)
+		case err != nil:
+			return result, err
+		case !overwrite:
+			result.Skipped = append(result.Skipped, template.Name)
+		default:
+			// Keep the local persona, which is not part of the export
+			template.ID = existing.ID
+			template.PersonaID = existing.PersonaID
+			if err := s.TemplateRepo.UpdateTemplate(template); err != nil {
+				return result, err
+			}
+			result.Updated = append(result.Updated, template.Name)
+		}
+	}
+	return result, nil
+}
+
+// checkNameFree returns ErrTemplateExists if a template other than self has the name.
+func (s *TemplateService) checkNameFree(name string, self primitive.ObjectID) error {
+	existing, err := s.TemplateRepo.GetTemplateByName(name)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if existing.ID != self {
+		return ErrTemplateExists
+	}
+	return nil
+}
+
+// ValidateTemplate checks a template and completes its variable list: placeholders
+// that are not declared are added as required variables. Errors are meant for the client.
+func ValidateTemplate(template *models.PromptTemplate) error {
+	template.Name = strings.TrimSpace(template.Name)
+	if template.Name == "" {
+		return errors.New("name is required")
+	}
+	if strings.TrimSpace(template.Content) == "" {
+		return errors.New("content is required")
+	}
+
+	used := TemplatePlaceholders(template.Content)
+	usedSet := make(map[string]bool, len(used))
+	for _, name := range used {
+		usedSet[name] = true
+	}
+
+	declared := make(map[string]bool, len(template.Variables))
+	for _, v := range template.Variables {
+		if !variableNamePattern.MatchString(v.Name) {
+			return fmt.Errorf("invalid variable name %q", v.Name)
+		}
+		if declared[v.Name] {
+			return fmt.Errorf("variable %q is declared twice", v.Name)
+		}
+		if !usedSet[v.Name] {
+			return fmt.Errorf("variable %q is not used in the content", v.Name)
+		}
+		declared[v.Name] = true
+	}
+
+	for _, name := range used {
+		if !declared[name] {
+			template.Variables = append(template.Variables, models.TemplateVariable{Name: name, Required: true})
+		}
+	}
+	if template.Variables == nil {
+		template.Variables = []models.TemplateVariable{}
+	}
+	return nil
+}
+
+// TemplatePlaceholders returns the variable names used in content, in order of first use.
+func TemplatePlaceholders(content string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, match := range placeholderPattern.FindAllStringSubmatch(content, -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			names = append(names, match[1])
+		}
+	}
+	return names
+}
+
+// RenderTemplate replaces the placeholders of a template with the given values.
+// Optional variables without a value use their default. Values are inserted as is,
+// so placeholders inside them are not expanded.
+func RenderTemplate(template *models.PromptTemplate, values map[string]string) (string, error) {
+	resolved := make(map[string]string, len(template.Variables))
+	var missing []string
+	for _, v := range template.Variables {
+		value, ok := values[v.Name]
+		if !ok || strings.TrimSpace(value) == "" {
+			if v.Required {
+				missing = append(missing, v.Name)
+				continue
+			}
+			value = v.Default
+		}
+		resolved[v.Name] = value
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("%w: %s", ErrMissingVariables, strings.Join(missing, ", "))
+	}
+
+	return placeholderPattern.ReplaceAllStringFunc(template.Content, func(placeholder string) string {
+		name := placeholderPattern.FindStringSubmatch(placeholder)[1]
+		return resolved[name]
+	}), nil
+}
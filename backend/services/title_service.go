@@ -0,0 +1,175 @@
+// services/title_service.go
+
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ashuthe1/localmind/logger"
+	"github.com/ashuthe1/localmind/models"
+	"github.com/ashuthe1/localmind/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DefaultChatTitle is the title of a chat until one has been generated.
+const DefaultChatTitle = "New Chat"
+
+const (
+	maxTitleLength  = 60
+	titleJobTimeout = 2 * time.Minute
+	// maxTitleInput caps how much of each message is sent to the model.
+	maxTitleInput = 1000
+)
+
+// TitleService generates chat titles from the first exchange of a chat.
+type TitleService struct {
+	ChatRepo  *repository.ChatRepository
+	LLM       LLMProvider
+	Events    *ChatEventBroker
+	Scheduler *GenerationScheduler
+}
+
+func NewTitleService(chatRepo *repository.ChatRepository, llm LLMProvider, events *ChatEventBroker, scheduler *GenerationScheduler) *TitleService {
+	return &TitleService{
+		ChatRepo:  chatRepo,
+		LLM:       llm,
+		Events:    events,
+		Scheduler: scheduler,
+	}
+}
+
+// NeedsTitle reports whether the chat still has the default title and has just had
+// its first exchange.
+func NeedsTitle(chat *models.Chat) bool {
+	if chat.Title != DefaultChatTitle {
+		return false
+	}
+	userMessages := 0
+	for _, msg := range chat.Messages {
+		if msg.Role == "user" {
+			userMessages++
+		}
+	}
+	return userMessages == 1
+}
+
+// GenerateTitleAsync generates and stores a title in the background, then publishes
+// a "title" event for the chat.
+func (s *TitleService) GenerateTitleAsync(chatID primitive.ObjectID) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), titleJobTimeout)
+		defer cancel()
+
+		if _, err := s.RegenerateTitle(ctx, chatID); err != nil {
+			logger.Log.Errorf("Error generating title for chat %s: %v", chatID.Hex(), err)
+		}
+	}()
+}
+
+// RegenerateTitle generates a title from the chat's first exchange, stores it and
+// publishes a "title" event.
+func (s *TitleService) RegenerateTitle(ctx context.Context, chatID primitive.ObjectID) (string, error) {
+	chat, err := s.ChatRepo.GetChatByID(chatID)
+	if err != nil {
+		return "", err
+	}
+
+	prompt, ok := titlePrompt(chat)
+	if !ok {
+		return "", fmt.Errorf("chat has no user message to title")
+	}
+
+	model := chat.Model
+	if model == "" {
+		model = s.LLM.DefaultModel()
+	}
+	// Titles wait behind the replies users are watching
+	release, err := s.Scheduler.Acquire(ctx, GenerationRequest{Model: model, Priority: PriorityBackground}, nil)
+	if err != nil {
+		return "", err
+	}
+	raw, err := s.LLM.Generate(ctx, model, prompt)
+	release()
+	if err != nil {
+		return "", err
+	}
+
+	title := CleanTitle(raw)
+	if err := s.ChatRepo.UpdateChatTitle(chatID, title); err != nil {
+		return "", err
+	}
+
+	s.Events.Publish(chatID, ChatEvent{
+		Type: "title",
+		Data: map[string]string{"chatId": chatID.Hex(), "title": title},
+	})
+	return title, nil
+}
+
+// titlePrompt builds the title prompt from the first user message and the reply to it.
+func titlePrompt(chat *models.Chat) (string, bool) {
+	var user, assistant string
+	for _, msg := range chat.Messages {
+		if msg.Role == "user" && user == "" {
+			user = msg.Content
+		} else if msg.Role == "assistant" && user != "" {
+			assistant = StripReasoning(msg.Content)
+			break
+		}
+	}
+	if user == "" {
+		return "", false
+	}
+
+	var prompt strings.Builder
+	prompt.WriteString("Write a short title of at most five words for a chat that starts with the exchange below. ")
+	prompt.WriteString("Reply with the title only, without quotes or punctuation at the end.\n\n")
+	fmt.Fprintf(&prompt, "User: %s\n", truncateRunes(user, maxTitleInput))
+	if assistant != "" {
+		fmt.Fprintf(&prompt, "Assistant: %s\n", truncateRunes(assistant, maxTitleInput))
+	}
+	return prompt.String(), true
+}
+
+// CleanTitle turns raw model output into a one-line title: reasoning, quotes,
+// "Title:" prefixes and trailing punctuation are removed and the length is capped.
+func CleanTitle(raw string) string {
+	text := StripReasoning(raw)
+
+	title := ""
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			title = line
+			break
+		}
+	}
+
+	for _, prefix := range []string{"Title:", "title:", "**Title:**"} {
+		title = strings.TrimPrefix(title, prefix)
+	}
+	title = strings.Trim(title, " \t*#_`\"'“”‘’")
+	title = strings.TrimRight(title, ".!:;,")
+	title = strings.Join(strings.Fields(title), " ")
+
+	title = truncateRunes(title, maxTitleLength)
+	if title == "" {
+		return DefaultChatTitle
+	}
+	return title
+}
+
+// truncateRunes cuts s to at most n runes, on a word boundary where possible.
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	cut := string([]rune(s)[:n])
+	if idx := strings.LastIndex(cut, " "); idx > n/2 {
+		cut = cut[:idx]
+	}
+	return strings.TrimSpace(cut)
+}
@@ -0,0 +1,214 @@
+// services/tool_calling.go
+
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ashuthe1/localmind/logger"
+	"github.com/ashuthe1/localmind/models"
+)
+
+// ToolMode is how tools are offered to the model.
+type ToolMode string
+
+const (
+	// ToolModeAuto uses native tool calling when the model supports it and the prompt otherwise.
+	ToolModeAuto ToolMode = "auto"
+	// ToolModeNative passes the tools to the provider's tool calling API.
+	ToolModeNative ToolMode = "native"
+	// ToolModePrompt describes the tools in a system message and parses <tool_call> blocks from the reply.
+	ToolModePrompt ToolMode = "prompt"
+	// ToolModeOff disables tools.
+	ToolModeOff ToolMode = "off"
+)
+
+const (
+	toolCallOpenTag  = "<tool_call>"
+	toolCallCloseTag = "</tool_call>"
+)
+
+// ToolService decides how a model is offered the registered tools.
+type ToolService struct {
+	Registry *ToolRegistry
+	LLM      LLMProvider
+	Mode     ToolMode
+	// MaxRounds bounds how many times one reply may call tools before it must answer.
+	MaxRounds int
+}
+
+func NewToolService(registry *ToolRegistry, llm LLMProvider, mode string, maxRounds int) *ToolService {
+	m := ToolMode(mode)
+	switch m {
+	case ToolModeAuto, ToolModeNative, ToolModePrompt, ToolModeOff:
+	default:
+		logger.Log.Warnf("Unknown tool calling mode %q, using %q", mode, ToolModeAuto)
+		m = ToolModeAuto
+	}
+	if maxRounds < 1 {
+		maxRounds = 1
+	}
+
+	return &ToolService{
+		Registry:  registry,
+		LLM:       llm,
+		Mode:      m,
+		MaxRounds: maxRounds,
+	}
+}
+
+// ModeFor returns ToolModeNative, ToolModePrompt or ToolModeOff for a model.
+func (s *ToolService) ModeFor(ctx context.Context, model string) ToolMode {
+	if s == nil || len(s.Registry.Definitions()) == 0 {
+		return ToolModeOff
+	}
+
+	caller, native := s.LLM.(ToolCaller)
+	switch s.Mode {
+	case ToolModeOff, ToolModePrompt:
+		return s.Mode
+	case ToolModeNative:
+		if native {
+			return ToolModeNative
+		}
+		return ToolModePrompt
+	}
+	if native && caller.SupportsTools(ctx, model) {
+		return ToolModeNative
+	}
+	return ToolModePrompt
+}
+
+// ToolPrompt is the system message that teaches a model without native tool calling
+// how to call the registered tools.
+func (s *ToolService) ToolPrompt() ChatMessage {
+	var b strings.Builder
+	b.WriteString("You can call tools to help answer. To call one, reply with only a block like\n")
+	b.WriteString(toolCallOpenTag + `{"name": "tool_name", "arguments": {...}}` + toolCallCloseTag + "\n")
+	b.WriteString("and stop. The result is sent back in a <tool_result> block, after which you continue. ")
+	b.WriteString("Only call a tool when it is needed; never invent results.\n\nAvailable tools:\n")
+	for _, tool := range s.Registry.Definitions() {
+		parameters, _ := json.Marshal(tool.Parameters)
+		fmt.Fprintf(&b, "- %s: %s\n  Parameters: %s\n", tool.Name, tool.Description, parameters)
+	}
+	return ChatMessage{Role: "system", Content: b.String()}
+}
+
+// ToolCallParser removes <tool_call>...</tool_call> blocks from streamed answer text
+// and collects the calls they contain. Like ReasoningParser, it holds back text that
+// could be the start of a tag until the next chunk decides it.
+type ToolCallParser struct {
+	inCall  bool
+	pending string
+	call    strings.Builder
+	calls   []models.ToolCall
+}
+
+// Feed consumes one chunk of answer text and returns the text outside tool calls.
+func (p *ToolCallParser) Feed(chunk string) string {
+	buf := p.pending + chunk
+	p.pending = ""
+
+	var out strings.Builder
+	for buf != "" {
+		tag := toolCallOpenTag
+		if p.inCall {
+			tag = toolCallCloseTag
+		}
+
+		if idx := strings.Index(buf, tag); idx >= 0 {
+			p.write(&out, buf[:idx])
+			buf = buf[idx+len(tag):]
+			if p.inCall {
+				p.finishCall()
+			}
+			p.inCall = !p.inCall
+			continue
+		}
+
+		keep := partialTagSuffix(buf, tag)
+		p.write(&out, buf[:len(buf)-keep])
+		p.pending = buf[len(buf)-keep:]
+		break
+	}
+	return out.String()
+}
+
+// Flush returns any text still held back once the stream has ended. A call whose
+// closing tag the model forgot is still parsed.
+func (p *ToolCallParser) Flush() string {
+	text := p.pending
+	p.pending = ""
+	if !p.inCall {
+		return text
+	}
+
+	p.call.WriteString(text)
+	p.inCall = false
+	raw := p.call.String()
+	if !p.finishCall() {
+		return toolCallOpenTag + raw
+	}
+	return ""
+}
+
+// Calls returns the tool calls parsed so far.
+func (p *ToolCallParser) Calls() []models.ToolCall {
+	return p.calls
+}
+
+func (p *ToolCallParser) write(out *strings.Builder, text string) {
+	if p.inCall {
+		p.call.WriteString(text)
+	} else {
+		out.WriteString(text)
+	}
+}
+
+// finishCall parses the collected block and reports whether it was a valid call.
+func (p *ToolCallParser) finishCall() bool {
+	raw := strings.TrimSpace(p.call.String())
+	p.call.Reset()
+
+	// Models like to wrap the JSON in a code fence
+	raw = strings.TrimPrefix(raw, "```json")
+	raw = strings.Trim(raw, "`\n ")
+
+	var call struct {
+		Name      string                 `json:"name"`
+		Arguments map[string]interface{} `json:"arguments"`
+	}
+	if err := json.Unmarshal([]byte(raw), &call); err != nil || call.Name == "" {
+		logger.Log.Warnf("Ignoring malformed tool call %q", raw)
+		return false
+	}
+	p.calls = append(p.calls, models.ToolCall{Name: call.Name, Arguments: call.Arguments})
+	return true
+}
+
+// PromptToolMessages rewrites tool calls and results for a model without native tool
+// calling: calls become <tool_call> blocks in assistant messages, results become user
+// messages with a <tool_result> block.
+func PromptToolMessages(messages []ChatMessage) []ChatMessage {
+	out := make([]ChatMessage, 0, len(messages))
+	for _, msg := range messages {
+		switch {
+		case len(msg.ToolCalls) > 0:
+			var b strings.Builder
+			b.WriteString(msg.Content)
+			for _, call := range msg.ToolCalls {
+				data, _ := json.Marshal(map[string]interface{}{"name": call.Name, "arguments": call.Arguments})
+				b.WriteString(toolCallOpenTag + string(data) + toolCallCloseTag)
+			}
+			out = append(out, ChatMessage{Role: "assistant", Content: b.String()})
+		case msg.Role == "tool":
+			out = append(out, ChatMessage{Role: "user", Content: fmt.Sprintf("<tool_result name=%q>\n%s\n</tool_result>", msg.ToolName, msg.Content)})
+		default:
+			out = append(out, msg)
+		}
+	}
+	return out
+}
@@ -0,0 +1,116 @@
+// services/tool_registry.go
+
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// ErrUnknownTool is returned when the model calls a tool that is not registered.
+var ErrUnknownTool = errors.New("unknown tool")
+
+// Tool is a function the model can call while answering.
+type Tool struct {
+	Name        string
+	Description string
+	// Parameters is the JSON schema of the arguments object.
+	Parameters map[string]interface{}
+	// Execute runs the tool. Its result and error text are shown to the model.
+	Execute func(ctx context.Context, args map[string]interface{}) (string, error)
+}
+
+// ToolDefinition is the part of a Tool sent to the model.
+type ToolDefinition struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Parameters  map[string]interface{} `json:"parameters"`
+}
+
+// ToolRegistry holds the tools available to the model keyed by name.
+type ToolRegistry struct {
+	mu    sync.RWMutex
+	tools map[string]Tool
+}
+
+func NewToolRegistry() *ToolRegistry {
+	return &ToolRegistry{
+		tools: make(map[string]Tool),
+	}
+}
+
+// Register adds a tool under its Name, replacing any previous one.
+func (r *ToolRegistry) Register(tool Tool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tools[tool.Name] = tool
+}
+
+// Get returns the tool registered under name.
+func (r *ToolRegistry) Get(name string) (Tool, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tool, ok := r.tools[name]
+	return tool, ok
+}
+
+// Definitions returns the definitions of all tools sorted by name.
+func (r *ToolRegistry) Definitions() []ToolDefinition {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	definitions := make([]ToolDefinition, 0, len(r.tools))
+	for _, tool := range r.tools {
+		definitions = append(definitions, ToolDefinition{Name: tool.Name, Description: tool.Description, Parameters: tool.Parameters})
+	}
+	sort.Slice(definitions, func(i, j int) bool { return definitions[i].Name < definitions[j].Name })
+	return definitions
+}
+
+// Execute runs the tool a call names. A panicking tool is reported as an error.
+func (r *ToolRegistry) Execute(ctx context.Context, name string, args map[string]interface{}) (result string, err error) {
+	tool, ok := r.Get(name)
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownTool, name)
+	}
+	if args == nil {
+		args = map[string]interface{}{}
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("tool %s failed: %v", name, p)
+		}
+	}()
+	return tool.Execute(ctx, args)
+}
+
+// stringArg reads a required string argument.
+func stringArg(args map[string]interface{}, name string) (string, error) {
+	value, ok := args[name].(string)
+	if !ok || value == "" {
+		return "", fmt.Errorf("argument %q must be a non-empty string", name)
+	}
+	return value, nil
+}
+
+// numberArg reads a required number argument. Numbers sent as strings are accepted,
+// since small models often quote them.
+func numberArg(args map[string]interface{}, name string) (float64, error) {
+	switch value := args[name].(type) {
+	case float64:
+		return value, nil
+	case json.Number:
+		return value.Float64()
+	case string:
+		var f float64
+		if _, err := fmt.Sscan(value, &f); err == nil {
+			return f, nil
+		}
+	}
+	return 0, fmt.Errorf("argument %q must be a number", name)
+}
@@ -20,8 +20,9 @@ func (s *UserService) GetUserByID(id primitive.ObjectID) (*models.User, error) {
 	return s.UserRepo.GetUserByID(id)
 }
 
-// UpdateUserSettings overwrites AboutMe and Preferences fields.
-func (s *UserService) UpdateUserSettings(username string, aboutMe, preferences string) error {
+// UpdateUserSettings overwrites AboutMe and Preferences fields. The default
+// generation options are only replaced when options is non-nil.
+func (s *UserService) UpdateUserSettings(username string, aboutMe, preferences string, options *models.GenerationOptions) error {
 	user, err := s.UserRepo.GetUserByUsername(username)
 	if err != nil {
 		return err
@@ -30,6 +31,9 @@ func (s *UserService) UpdateUserSettings(username string, aboutMe, preferences s
 	// Overwrite fields instead of appending
 	user.AboutMe = aboutMe
 	user.Preferences = preferences
+	if options != nil {
+		user.Options = options
+	}
 
 	return s.UserRepo.UpdateUser(user)
 }
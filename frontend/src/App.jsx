@@ -85,22 +85,34 @@ function App() {
       })
     );
 
-    try {
-      await api.sendMessageSSE(message, selectedChatId, (chunk) => {
-        setChats((prevChats) =>
-          prevChats.map((chat) => {
-            if (chat.id === selectedChatId) {
-              const updatedMessages = [...chat.messages];
-              const lastIndex = updatedMessages.length - 1;
-              if (updatedMessages[lastIndex].role === 'assistant') {
-                updatedMessages[lastIndex].content += chunk;
-              }
-              return { ...chat, messages: updatedMessages };
+    // Appends to the streamed assistant message, or replaces its content when reset
+    const updateAnswer = (chunk, reset = false) => {
+      setChats((prevChats) =>
+        prevChats.map((chat) => {
+          if (chat.id === selectedChatId) {
+            const updatedMessages = [...chat.messages];
+            const lastIndex = updatedMessages.length - 1;
+            if (updatedMessages[lastIndex].role === 'assistant') {
+              updatedMessages[lastIndex] = {
+                ...updatedMessages[lastIndex],
+                content: reset ? chunk : updatedMessages[lastIndex].content + chunk,
+              };
             }
-            return chat;
-          })
-        );
-      });
+            return { ...chat, messages: updatedMessages };
+          }
+          return chat;
+        })
+      );
+    };
+
+    try {
+      await api.sendMessageSSE(
+        message,
+        selectedChatId,
+        (chunk) => updateAnswer(chunk),
+        // A rejected attempt is replaced by the next one
+        () => updateAnswer('', true)
+      );
       await fetchChats();
     } catch (error) {
       console.error('Error sending message:', error);
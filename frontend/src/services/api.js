@@ -3,6 +3,29 @@ import axios from 'axios';
 
 const API_BASE_URL = '/api';
 
+// Parses one SSE event block. Data lines are joined with newlines and their
+// content is kept verbatim, since model tokens carry meaningful whitespace.
+// Comment lines (heartbeats) are ignored.
+function parseSSEEvent(block) {
+  let event = "message";
+  const dataLines = [];
+  block.split("\n").forEach((line) => {
+    if (line.startsWith("event:")) {
+      event = line.slice("event:".length).trim();
+    } else if (line.startsWith("data:")) {
+      let value = line.slice("data:".length);
+      if (value.startsWith(" ")) value = value.slice(1);
+      dataLines.push(value);
+    }
+  });
+  return { event, data: dataLines.join("\n") };
+}
+
+// Answer text arrives as "answer" events; reasoning and control events are skipped.
+function isAnswerEvent(event) {
+  return event === "answer" || event === "message";
+}
+
 export const api = {
   async sendMessage(message, chatId) {
     const requestBody = { message, model: "deepseek" };
@@ -27,7 +50,10 @@ export const api = {
     return response.data;
   },
 
-  async sendMessageSSE(message, chatId, onChunk, retryCount = 0) {
+  // onRetry is called when the server rejects the streamed answer (it did not match
+  // the requested JSON schema) and streams a new attempt; the partial answer must be
+  // discarded.
+  async sendMessageSSE(message, chatId, onChunk, onRetry, retryCount = 0) {
     const requestBody = { message, model: "deepseek" };
     if (chatId) requestBody.chatId = chatId;
   
@@ -59,20 +85,18 @@ export const api = {
         const parts = buffer.split("\n\n");
         buffer = parts.pop(); // Save the partial event for later.
         parts.forEach((part) => {
-          if (part.startsWith("data: ")) {
-            const data = part.slice("data: ".length).trim();
-            // console.log("Parsed SSE data:", data); // Debug log
-            if (data) {
-              onChunk(data);
-            }
+          const { event, data } = parseSSEEvent(part);
+          // console.log("Parsed SSE event:", event, data); // Debug log
+          if (isAnswerEvent(event) && data) {
+            onChunk(data);
+          } else if (event === "retry" && onRetry) {
+            onRetry(data);
           }
         });
       }
-  
-      if (buffer.startsWith("data: ")) {
-        const data = buffer.slice("data: ".length).trim();
-        if (data) onChunk(data);
-      }
+
+      const { event, data } = parseSSEEvent(buffer);
+      if (isAnswerEvent(event) && data) onChunk(data);
     } catch (error) {
       console.error("SSE Connection error:", error);
   